@@ -0,0 +1,665 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sporule/grater/models"
+	"github.com/sporule/grater/modules/config"
+	"github.com/sporule/grater/modules/exporter"
+	"github.com/sporule/grater/modules/rulesync"
+	"github.com/sporule/grater/modules/scraper"
+	"github.com/sporule/grater/modules/utility"
+	"github.com/sporule/grater/modules/warc"
+)
+
+//command is a subcommand of grater, the database is connected before it runs if database is true
+type command struct {
+	name     string
+	usage    string
+	database bool
+	//invalidConfig allows the command to run when the config is invalid
+	invalidConfig bool
+	run           func(cfg *config.Config, args []string) error
+	commands      []command
+}
+
+var commands = []command{
+	{name: "serve", usage: "run the distributor and the scraper, -mode overrides the config", database: true, run: serve},
+	{name: "rule", commands: []command{
+		{name: "add", usage: "add or update the rule in -f rule.yaml", database: true, run: addRule},
+		{name: "list", usage: "list the rules, -status filters them", database: true, run: listRules},
+		{name: "get", usage: "print the rule -id as json", database: true, run: getRule},
+		{name: "cancel", usage: "cancel the rule -id", database: true, run: cancelRule},
+		{name: "versions", usage: "list the versions of the rule -id", database: true, run: listRuleVersions},
+		{name: "rollback", usage: "restore the definition of -version as a new version of the rule -id", database: true, run: rollbackRule},
+		{name: "test", usage: "validate the rule in -f rule.yaml, print the links it generates and run its fixture in testdata or -fixture, -dir runs the fixtures of every rule in the directory", run: testRule},
+		{name: "schema", usage: "print the JSON Schema of the records of the rule in -f rule.yaml", run: printRuleSchema},
+		{name: "sync", usage: "create, update and cancel the rules by the yaml or json files in -dir, -dry-run prints the changes only", database: true, run: syncRules},
+	}},
+	{name: "links", commands: []command{
+		{name: "generate", usage: "cancel the incomplete links of the rule -rule and generate them again", database: true, run: generateLinks},
+		{name: "list", usage: "list the links, -rule, -status and -scraper filter them", database: true, run: listLinks},
+		{name: "requeue", usage: "set the links of -rule or -ids back to active, -status filters them", database: true, run: requeueLinks},
+	}},
+	{name: "results", commands: []command{
+		{name: "export", usage: "export the results to csv, jsonl or parquet", database: true, run: exportResults},
+		{name: "reextract", usage: "extract the stored pages of the rule -rule again with its current pattern and replace their results, -from and -to filter the pages", database: true, run: reextractResults},
+	}},
+	{name: "db", commands: []command{
+		{name: "migrate", usage: "create the missing indexes", database: true, run: migrate},
+	}},
+	{name: "scrape-once", usage: "scrape -url with the rule in -rule rule.yaml locally and print the records, no distributor or database is needed", run: scrapeOnce},
+	{name: "replay", usage: "run the rule in -rule rule.yaml over the archived pages in -warc or the archive and print the records, nothing is requested", run: replay},
+	{name: "config", commands: []command{
+		{name: "print", usage: "print the effective config with the secrets masked", invalidConfig: true, run: printConfig},
+	}},
+}
+
+//modes are the arguments that used to be the only argument, e.g. grater dist
+var modes = []string{"both", "dist", "api", "scraper"}
+
+//legacyArgs converts the arguments before the subcommands, grater <mode> runs serve and grater export runs results export
+func legacyArgs(args []string) []string {
+	if len(args) == 0 {
+		return []string{"serve"}
+	}
+	for _, mode := range modes {
+		if args[0] == mode {
+			return append([]string{"serve", "-mode", mode}, args[1:]...)
+		}
+	}
+	if args[0] == "export" {
+		return append([]string{"results", "export"}, args[1:]...)
+	}
+	return args
+}
+
+//findCommand returns the command of the arguments and the arguments after it, the name of the returned command is the full path such as rule add
+func findCommand(commands []command, args []string) (command, []string, error) {
+	if len(args) == 0 {
+		return command{}, nil, errors.New("A command is required")
+	}
+	for _, cmd := range commands {
+		if cmd.name != args[0] {
+			continue
+		}
+		if len(cmd.commands) == 0 {
+			return cmd, args[1:], nil
+		}
+		sub, subArgs, err := findCommand(cmd.commands, args[1:])
+		if err != nil {
+			return command{}, nil, fmt.Errorf("%s: %v", cmd.name, err)
+		}
+		sub.name = cmd.name + " " + sub.name
+		return sub, subArgs, nil
+	}
+	return command{}, nil, errors.New("Unknown command " + args[0])
+}
+
+//printUsage lists the commands, the global flags are listed by grater -help
+func printUsage(w io.Writer, commands []command, prefix string) {
+	if prefix == "" {
+		fmt.Fprintln(w, "Usage: grater [flags] <command> [command flags], run grater -help for the flags and grater <command> -help for the command flags")
+	}
+	for _, cmd := range commands {
+		if len(cmd.commands) > 0 {
+			printUsage(w, cmd.commands, prefix+cmd.name+" ")
+			continue
+		}
+		fmt.Fprintf(w, "  %-22s %s\n", prefix+cmd.name, cmd.usage)
+	}
+}
+
+//printJSON writes the value as indented json to stdout
+func printJSON(value interface{}) error {
+	content, err := json.MarshalIndent(value, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = os.Stdout.Write(append(content, '\n'))
+	return err
+}
+
+//readRule reads and validates the yaml or json rule file
+func readRule(path string) (*models.Rule, error) {
+	if path == "" {
+		return nil, utility.ValidationError(utility.Enums().ErrorMessages.LackOfInfo, map[string]string{"f": "the rule file is required"})
+	}
+	return rulesync.ReadFile(path)
+}
+
+//addRule saves the rule, e.g. grater rule add -f ps5.yaml
+func addRule(cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("rule add", flag.ExitOnError)
+	file := flags.String("f", "", "yaml or json rule file")
+	flags.Parse(args)
+	rule, err := readRule(*file)
+	if err != nil {
+		return err
+	}
+	if utility.IsNil(rule.Status) {
+		rule.Status = utility.Enums().Status.Active
+	}
+	if err := rule.Upsert(); err != nil {
+		return err
+	}
+	log.Info("Rule saved, the distributor schedules the link generation of new rules on restart", "rule", rule.ID)
+	fmt.Println(rule.ID)
+	return nil
+}
+
+//listRules prints the rules as a table, e.g. grater rule list -status Active
+func listRules(cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("rule list", flag.ExitOnError)
+	status := flags.String("status", "", "only list the rules in the status")
+	flags.Parse(args)
+	var filters map[string]interface{}
+	if *status != "" {
+		filters = map[string]interface{}{"status": *status}
+	}
+	rules, err := models.GetRules(filters, 0)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tSTATUS\tTARGET\tFREQUENCY\tLAST UPDATE")
+	for _, rule := range rules {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\n", rule.ID, rule.Name, rule.Status, rule.TargetLocation, rule.Frequency, rule.LastUpdate.Format("2006-01-02 15:04:05"))
+	}
+	return w.Flush()
+}
+
+//syncRules applies the rule files in the directory, e.g. grater rule sync -dir rules -dry-run
+func syncRules(cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("rule sync", flag.ExitOnError)
+	dir := flags.String("dir", cfg.Rules.Dir, "directory of the rule files")
+	dryRun := flags.Bool("dry-run", false, "print the changes without applying them")
+	flags.Parse(args)
+	if *dir == "" {
+		return utility.ValidationError(utility.Enums().ErrorMessages.LackOfInfo, map[string]string{"dir": "required"})
+	}
+	changes, err := rulesync.Sync(*dir, *dryRun)
+	for _, change := range changes {
+		fmt.Printf("%s %s (%s) %s\n", change.Action, change.Name, change.Source, change.RuleID)
+		for _, field := range change.Fields {
+			fmt.Printf("    %s: %s -> %s\n", field.Field, field.Old, field.New)
+		}
+	}
+	if err == nil && len(changes) == 0 {
+		log.Info("The rules are up to date", "dir", *dir)
+	}
+	return err
+}
+
+//ruleIDFlag parses the -id flag of the rule commands
+func ruleIDFlag(name string, args []string) (string, error) {
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	id := flags.String("id", "", "rule id")
+	flags.Parse(args)
+	if *id == "" {
+		return "", utility.ValidationError(utility.Enums().ErrorMessages.LackOfInfo, map[string]string{"id": "required"})
+	}
+	return *id, nil
+}
+
+//getRule prints the rule, e.g. grater rule get -id <id>
+func getRule(cfg *config.Config, args []string) error {
+	id, err := ruleIDFlag("rule get", args)
+	if err != nil {
+		return err
+	}
+	rule, err := models.GetRule(id)
+	if err != nil {
+		return err
+	}
+	return printJSON(rule)
+}
+
+//cancelRule cancels the rule, e.g. grater rule cancel -id <id>
+func cancelRule(cfg *config.Config, args []string) error {
+	id, err := ruleIDFlag("rule cancel", args)
+	if err != nil {
+		return err
+	}
+	if err := models.CancelRule(id); err != nil {
+		return err
+	}
+	log.Info("Rule cancelled", "rule", id)
+	return nil
+}
+
+//listRuleVersions prints the version history of the rule as a table, e.g. grater rule versions -id <id>
+func listRuleVersions(cfg *config.Config, args []string) error {
+	id, err := ruleIDFlag("rule versions", args)
+	if err != nil {
+		return err
+	}
+	versions, err := models.GetRuleVersions(id, 0)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tROLLED BACK FROM\tCREATED")
+	for _, version := range versions {
+		rolledBackFrom := ""
+		if version.RolledBackFrom > 0 {
+			rolledBackFrom = strconv.Itoa(version.RolledBackFrom)
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\n", version.Version, rolledBackFrom, version.LastUpdate.Format("2006-01-02 15:04:05"))
+	}
+	return w.Flush()
+}
+
+//rollbackRule restores an earlier version of the rule, e.g. grater rule rollback -id <id> -version 2
+func rollbackRule(cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("rule rollback", flag.ExitOnError)
+	id := flags.String("id", "", "rule id")
+	version := flags.Int("version", 0, "the version to restore")
+	flags.Parse(args)
+	invalid := make(map[string]string)
+	if *id == "" {
+		invalid["id"] = "required"
+	}
+	if *version <= 0 {
+		invalid["version"] = "required"
+	}
+	if len(invalid) > 0 {
+		return utility.ValidationError(utility.Enums().ErrorMessages.LackOfInfo, invalid)
+	}
+	rule, err := models.GetRule(*id)
+	if err != nil {
+		return err
+	}
+	if err := rule.Rollback(*version); err != nil {
+		return err
+	}
+	log.Info("Rule rolled back", "rule", rule.ID, "from", *version, "version", rule.Version)
+	return nil
+}
+
+//testRule validates the rule file without the database and runs its fixture if it has one, e.g. grater rule test -f ps5.yaml
+func testRule(cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("rule test", flag.ExitOnError)
+	file := flags.String("f", "", "yaml or json rule file")
+	fixture := flags.String("fixture", "", "fixture file, default testdata/<rule>/fixture.yaml next to the rule file")
+	dir := flags.String("dir", "", "run the fixtures of every rule file in the directory and its sub directories instead")
+	flags.Parse(args)
+	if *dir != "" {
+		return testFixtures(*dir)
+	}
+	rule, err := readRule(*file)
+	if err != nil {
+		return err
+	}
+	links, err := rule.GenerateLinks()
+	if err != nil {
+		return err
+	}
+	for _, link := range links {
+		fmt.Println(link)
+	}
+	log.Info("The rule is valid", "links", len(links))
+	if *fixture == "" {
+		*fixture = scraper.FixturePath(*file)
+		if _, err := os.Stat(*fixture); os.IsNotExist(err) {
+			return nil
+		}
+	}
+	report, err := scraper.RunFixture(*file, *fixture)
+	if err != nil {
+		return err
+	}
+	if !logFixture(report) {
+		return fmt.Errorf("the fixture %s failed with %d mismatches", *fixture, len(report.Mismatches))
+	}
+	return nil
+}
+
+//testFixtures runs the fixtures of the rules in the directory, the rules without a fixture are logged but don't fail
+func testFixtures(dir string) error {
+	reports, err := scraper.RunFixtures(dir)
+	if err != nil {
+		return err
+	}
+	failed := 0
+	for _, report := range reports {
+		if report.Missing {
+			log.Warn("The rule has no fixture", "rule", report.Rule, "fixture", report.Fixture)
+			continue
+		}
+		if !logFixture(report) {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d fixtures failed", failed, len(reports))
+	}
+	return nil
+}
+
+//logFixture logs the mismatches of the fixture and returns true if it passed
+func logFixture(report *scraper.FixtureReport) bool {
+	for _, mismatch := range report.Mismatches {
+		log.Error("The fixture doesn't match", "fixture", report.Fixture, "mismatch", mismatch)
+	}
+	if report.Passed() {
+		log.Info("The fixture passed", "fixture", report.Fixture, "records", report.Records)
+	}
+	return report.Passed()
+}
+
+//printRuleSchema prints the JSON Schema of the records of the rule file, e.g. grater rule schema -f ps5.yaml
+func printRuleSchema(cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("rule schema", flag.ExitOnError)
+	file := flags.String("f", "", "yaml or json rule file")
+	flags.Parse(args)
+	rule, err := readRule(*file)
+	if err != nil {
+		return err
+	}
+	schema, err := rule.OutputSchema()
+	if err != nil {
+		return err
+	}
+	return printJSON(schema)
+}
+
+//generateLinks regenerates the links of the rule, e.g. grater links generate -rule <id>
+func generateLinks(cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("links generate", flag.ExitOnError)
+	ruleID := flags.String("rule", "", "rule id")
+	flags.Parse(args)
+	if *ruleID == "" {
+		return utility.ValidationError(utility.Enums().ErrorMessages.LackOfInfo, map[string]string{"rule": "required"})
+	}
+	rule, err := models.GetRule(*ruleID)
+	if err != nil {
+		return err
+	}
+	links, err := rule.GenerateAndInsertLinks()
+	if err != nil {
+		return err
+	}
+	log.Info("Generated links for rule", "rule", rule.ID, "links", links)
+	return nil
+}
+
+//listLinks prints the links as a table, the latest updated links come first, e.g. grater links list -rule <id> -status Failed
+func listLinks(cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("links list", flag.ExitOnError)
+	filter := models.LinkFilter{}
+	flags.StringVar(&filter.RuleID, "rule", "", "rule id")
+	flags.StringVar(&filter.Status, "status", "", "Active, Running, Completed, Cancelled or Failed")
+	flags.StringVar(&filter.Scraper, "scraper", "", "the scraper the links are allocated to")
+	page := flags.Int("page", 1, "page of the links, 0 lists all of them")
+	flags.Parse(args)
+	links, err := models.FindLinks(filter, *page)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tRULE\tSTATUS\tSCRAPER\tATTEMPTS\tLAST UPDATE\tLINK")
+	for _, link := range links {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n", link.ID, link.RuleID, link.Status, link.Scraper, link.Attempts, link.LastUpdate.Format("2006-01-02 15:04:05"), link.Link)
+	}
+	return w.Flush()
+}
+
+//requeueLinks sets the links back to active, e.g. grater links requeue -rule <id> -status Failed
+func requeueLinks(cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("links requeue", flag.ExitOnError)
+	filter := models.LinkFilter{}
+	flags.StringVar(&filter.RuleID, "rule", "", "rule id")
+	flags.StringVar(&filter.Status, "status", "", "only requeue the links in the status")
+	ids := flags.String("ids", "", "comma separated link ids")
+	flags.Parse(args)
+	if *ids != "" {
+		filter.LinkIDs = strings.Split(*ids, ",")
+	}
+	if err := models.RequeueLinks(filter); err != nil {
+		return err
+	}
+	log.Info("Links requeued", "rule", filter.RuleID, "status", filter.Status, "links", len(filter.LinkIDs))
+	return nil
+}
+
+//exportResults writes the results to a file or stdout, e.g. grater results export -tablename PS5 -format parquet -out ps5.parquet
+func exportResults(cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("results export", flag.ExitOnError)
+	tableName := flags.String("tablename", "", "table to export, it is the targetLocation of the rule")
+	ruleID := flags.String("ruleid", "", "only export results of this rule, the table of the rule will be used if tablename is empty")
+	from := flags.String("from", "", "only export results updated at or after this time, RFC3339")
+	to := flags.String("to", "", "only export results updated before this time, RFC3339")
+	fields := flags.String("fields", "", "comma separated flattened columns to export, all columns by default")
+	format := flags.String("format", exporter.CSV, "csv, jsonl or parquet")
+	out := flags.String("out", "", "output file, stdout by default")
+	flags.Parse(args)
+
+	opts, err := exporter.ParseOptions(*tableName, *ruleID, *from, *to, *fields, *format)
+	if err != nil {
+		return err
+	}
+	export, err := exporter.Prepare(opts)
+	if err != nil {
+		return err
+	}
+	output := os.Stdout
+	if *out != "" {
+		file, err := os.Create(*out)
+		if err != nil {
+			export.Close()
+			return err
+		}
+		defer file.Close()
+		output = file
+	}
+	return export.Stream(output)
+}
+
+//reextractResults runs the current pattern over the stored pages, e.g. grater results reextract -rule <id> -from 2021-02-01T00:00:00Z
+func reextractResults(cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("results reextract", flag.ExitOnError)
+	ruleID := flags.String("rule", "", "id of the rule")
+	from := flags.String("from", "", "only extract the pages fetched at or after this time, RFC3339")
+	to := flags.String("to", "", "only extract the pages fetched before this time, RFC3339")
+	flags.Parse(args)
+	if *ruleID == "" {
+		return utility.ValidationError(utility.Enums().ErrorMessages.LackOfInfo, map[string]string{"rule": "required"})
+	}
+	var fromTime, toTime time.Time
+	for _, value := range []struct {
+		name string
+		raw  string
+		time *time.Time
+	}{{"from", *from, &fromTime}, {"to", *to, &toTime}} {
+		if value.raw == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, value.raw)
+		if err != nil {
+			return utility.ValidationError("Invalid "+value.name+" time, it should be in RFC3339 format", map[string]string{value.name: value.raw})
+		}
+		*value.time = parsed
+	}
+	rule, err := models.GetRule(*ruleID)
+	if err != nil {
+		return err
+	}
+	job, err := models.NewReextractJob(rule, fromTime, toTime)
+	if err != nil {
+		return err
+	}
+	if err := job.Upsert(); err != nil {
+		return err
+	}
+	if err := scraper.Reextract(job); err != nil {
+		return err
+	}
+	return printJSON(job)
+}
+
+//migrate creates the indexes, e.g. grater db migrate
+func migrate(cfg *config.Config, args []string) error {
+	indexes, err := models.Migrate()
+	for _, index := range indexes {
+		fmt.Println(index)
+	}
+	return err
+}
+
+//scrapeOnce runs the rule locally, e.g. grater scrape-once -rule ps5.yaml -url https://www.ebay.co.uk/sch/i.html?_nkw=ps5
+func scrapeOnce(cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("scrape-once", flag.ExitOnError)
+	file := flags.String("rule", "", "yaml or json rule file")
+	urls := flags.String("url", "", "comma separated links to scrape, the first pages of the linkPattern by default")
+	pages := flags.Int("pages", 1, "pages of the linkPattern to scrape if -url is empty")
+	report := flags.Bool("report", false, "print the layout errors and the failed links with the records as json")
+	flags.Parse(args)
+	rule, err := readRule(*file)
+	if err != nil {
+		return err
+	}
+	var links []string
+	if *urls != "" {
+		links = strings.Split(*urls, ",")
+	} else {
+		if *pages < rule.TotalPages {
+			rule.TotalPages = *pages
+		}
+		if links, err = rule.GenerateLinks(); err != nil {
+			return err
+		}
+	}
+	result, err := scraper.ScrapeOnce(*rule, links)
+	if err != nil {
+		return err
+	}
+	if *report {
+		return printJSON(result)
+	}
+	printReport(result)
+	log.Info("Scraped", "links", len(links), "records", len(result.Records), "layoutErrors", len(result.LayoutErrors), "failed", len(result.Failed))
+	return nil
+}
+
+//printReport writes the records and logs the layout errors and the failed links of a local run
+func printReport(result *scraper.OnceReport) {
+	for _, record := range result.Records {
+		fmt.Println(string(record))
+	}
+	for _, layoutError := range result.LayoutErrors {
+		log.Warn("Page layout not as expected", "link", layoutError.Link, "missing", layoutError.Missing)
+	}
+	for link, reason := range result.Failed {
+		log.Warn("Request failed", "link", link, "error", reason)
+	}
+}
+
+//replay runs the rule over archived pages, e.g. grater replay -rule ps5.yaml -warc archive/ps5
+//the files of the rule id in the configured archive are used if -warc is empty
+func replay(cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("replay", flag.ExitOnError)
+	file := flags.String("rule", "", "yaml or json rule file")
+	paths := flags.String("warc", "", "comma separated WARC files or directories")
+	prefix := flags.String("prefix", "", "prefix of the files in the archive, the id of the rule followed by / by default")
+	report := flags.Bool("report", false, "print the layout errors and the failed links with the records as json")
+	flags.Parse(args)
+	rule, err := readRule(*file)
+	if err != nil {
+		return err
+	}
+	var files [][]byte
+	if *paths != "" {
+		files, err = readWARCFiles(strings.Split(*paths, ","))
+	} else {
+		files, err = readArchive(cfg.Archive, *prefix, rule.ID)
+	}
+	if err != nil {
+		return err
+	}
+	result, err := scraper.Replay(*rule, files)
+	if err != nil {
+		return err
+	}
+	if *report {
+		return printJSON(result)
+	}
+	printReport(result)
+	log.Info("Replayed", "files", len(files), "records", len(result.Records), "layoutErrors", len(result.LayoutErrors), "failed", len(result.Failed))
+	return nil
+}
+
+//readWARCFiles reads the files and the .warc and .warc.gz files in the directories
+func readWARCFiles(paths []string) ([][]byte, error) {
+	var files [][]byte
+	for _, path := range paths {
+		err := filepath.Walk(path, func(name string, info os.FileInfo, err error) error {
+			if err != nil || info.IsDir() {
+				return err
+			}
+			if name != path && !strings.HasSuffix(name, ".warc") && !strings.HasSuffix(name, ".warc.gz") {
+				return nil
+			}
+			content, err := ioutil.ReadFile(name)
+			files = append(files, content)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+//readArchive reads the files of the prefix from the configured archive
+func readArchive(archive config.Archive, prefix, ruleID string) ([][]byte, error) {
+	store, err := warc.NewStore(archive)
+	if err != nil {
+		return nil, err
+	}
+	if store == nil {
+		return nil, errors.New("-warc is required when the archive is off")
+	}
+	if prefix == "" {
+		if ruleID == "" {
+			return nil, errors.New("-prefix is required when the rule file has no id")
+		}
+		prefix = ruleID + "/"
+	}
+	list, err := store.List(prefix)
+	if err != nil {
+		return nil, err
+	}
+	var files [][]byte
+	for _, file := range list {
+		content, err := store.Get(file.Name)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, content)
+	}
+	return files, nil
+}
+
+//printConfig writes the effective config with the secrets masked, e.g. grater -config grater.yaml config print -format json
+func printConfig(cfg *config.Config, args []string) error {
+	flags := flag.NewFlagSet("config print", flag.ExitOnError)
+	format := flags.String("format", "yaml", "yaml or json")
+	flags.Parse(args)
+	if err := cfg.Print(os.Stdout, *format); err != nil {
+		return err
+	}
+	//the config is printed even if it is invalid so the invalid fields can be found
+	return cfg.Validate()
+}
@@ -0,0 +1,31 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFindCommand(t *testing.T) {
+	cmd, args, err := findCommand(commands, []string{"rule", "add", "-f", "rule.json"})
+	assert.Nil(t, err)
+	assert.Equal(t, "rule add", cmd.name)
+	assert.Equal(t, true, cmd.database)
+	assert.Equal(t, []string{"-f", "rule.json"}, args)
+
+	cmd, _, err = findCommand(commands, []string{"scrape-once"})
+	assert.Nil(t, err)
+	assert.Equal(t, false, cmd.database, "scrape-once should not need the database")
+
+	_, _, err = findCommand(commands, []string{"rule"})
+	assert.Equal(t, "rule: A command is required", err.Error())
+	_, _, err = findCommand(commands, []string{"rules"})
+	assert.Equal(t, "Unknown command rules", err.Error())
+}
+
+func TestLegacyArgs(t *testing.T) {
+	assert.Equal(t, []string{"serve"}, legacyArgs(nil))
+	assert.Equal(t, []string{"serve", "-mode", "dist"}, legacyArgs([]string{"dist"}))
+	assert.Equal(t, []string{"results", "export", "-tablename", "PS5"}, legacyArgs([]string{"export", "-tablename", "PS5"}))
+	assert.Equal(t, []string{"db", "migrate"}, legacyArgs([]string{"db", "migrate"}))
+}
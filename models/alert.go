@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"github.com/sporule/grater/modules/database"
+)
+
+//Alert is a firing alert of the notifier, the key of the alert is the id so every alert is stored once,
+//the alerts are kept so they are not sent again and can still be resolved after the distributor restarts
+type Alert struct {
+	Key      string            `bson:"_id" json:"key"`
+	Title    string            `json:"title"`
+	Message  string            `json:"message"`
+	Severity string            `json:"severity"`
+	Fields   map[string]string `json:"fields,omitempty"`
+	Time     time.Time         `json:"time"`
+}
+
+const alertTable = "alert"
+
+//Upsert saves the alert by its key
+func (alert *Alert) Upsert() error {
+	return database.Client.UpsertOne(alertTable, map[string]interface{}{"_id": alert.Key}, alert)
+}
+
+//GetAlerts returns every firing alert
+func GetAlerts() ([]Alert, error) {
+	var alerts []Alert
+	err := database.Client.GetAll(alertTable, &alerts, nil, nil, 0)
+	return alerts, err
+}
+
+//DeleteAlert deletes the alert after it is resolved
+func DeleteAlert(key string) error {
+	return database.Client.DeleteMany(alertTable, map[string]interface{}{"_id": key})
+}
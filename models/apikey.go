@@ -0,0 +1,88 @@
+package models
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/sporule/grater/modules/database"
+	"github.com/sporule/grater/modules/utility"
+)
+
+//APIKey is the key used by scraper nodes and other machines to access the api, only the hash of the key is stored
+type APIKey struct {
+	ID         string    `bson:"_id" json:"id,omitempty"`
+	Name       string    `json:"name,omitempty"`
+	Prefix     string    `json:"prefix,omitempty"`
+	Hash       string    `json:"-"`
+	Role       string    `json:"role,omitempty"`
+	Status     string    `json:"status,omitempty"`
+	LastUpdate time.Time `json:"lastUpdate,omitempty"`
+}
+
+const apiKeyTable = "apikey"
+
+//NewAPIKey is the constructor of APIKey, it returns the key object and the plain key which is only visible once
+func NewAPIKey(name, role string) (*APIKey, string, error) {
+	if utility.IsNil(name, role) {
+		return nil, "", utility.ValidationError(utility.Enums().ErrorMessages.LackOfInfo, nil)
+	}
+	if !IsValidRole(role) {
+		return nil, "", utility.ValidationError("Invalid role: "+role, map[string]string{"role": role})
+	}
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, "", err
+	}
+	key := "grater_" + hex.EncodeToString(secret)
+	id, _ := uuid.NewRandom()
+	return &APIKey{
+		ID:     id.String(),
+		Name:   name,
+		Prefix: key[:15],
+		Hash:   hashAPIKey(key),
+		Role:   role,
+		Status: utility.Enums().Status.Active,
+	}, key, nil
+}
+
+func hashAPIKey(key string) string {
+	hash := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(hash[:])
+}
+
+//Insert inserts the api key to the database
+func (apiKey *APIKey) Insert() error {
+	apiKey.LastUpdate = time.Now()
+	return database.Client.InsertOne(apiKeyTable, apiKey)
+}
+
+//GetAPIKeys returns api keys by page
+func GetAPIKeys(page int) ([]APIKey, error) {
+	var apiKeys []APIKey
+	err := database.Client.GetAll(apiKeyTable, &apiKeys, nil, nil, page)
+	return apiKeys, err
+}
+
+//AuthenticateAPIKey returns the active api key that matches the plain key
+func AuthenticateAPIKey(key string) (*APIKey, error) {
+	var apiKey APIKey
+	filters := map[string]interface{}{"hash": hashAPIKey(key), "status": utility.Enums().Status.Active}
+	if err := database.Client.GetOne(apiKeyTable, &apiKey, filters); err != nil {
+		return nil, utility.UnauthorizedError()
+	}
+	return &apiKey, nil
+}
+
+//RevokeAPIKey sets the api key status to cancelled by ID
+func RevokeAPIKey(id string) error {
+	var apiKey APIKey
+	if err := database.Client.GetOne(apiKeyTable, &apiKey, map[string]interface{}{"_id": id}); err != nil {
+		return err
+	}
+	updatesFields := map[string]interface{}{"status": utility.Enums().Status.Cancelled, "lastupdate": time.Now()}
+	return database.Client.UpdateMany(apiKeyTable, map[string]interface{}{"_id": id}, updatesFields)
+}
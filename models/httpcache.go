@@ -0,0 +1,72 @@
+package models
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"time"
+
+	"github.com/sporule/grater/modules/database"
+	"github.com/sporule/grater/modules/utility"
+)
+
+//HTTPCacheEntry is the last full response of a link, the ETag and Last-Modified are sent back so an unchanged page is answered with 304,
+//the ID is the link and the content is the gzip compressed body
+type HTTPCacheEntry struct {
+	ID           string    `bson:"_id" json:"id"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"lastModified,omitempty"`
+	ContentType  string    `json:"contentType,omitempty"`
+	Content      []byte    `json:"content,omitempty"`
+	LastUpdate   time.Time `json:"lastUpdate,omitempty"`
+}
+
+const httpCacheTable = "httpcache"
+
+//NewHTTPCacheEntry is the constructor of HTTPCacheEntry, the body is compressed
+func NewHTTPCacheEntry(link, etag, lastModified, contentType string, body []byte) (*HTTPCacheEntry, error) {
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write(body); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return &HTTPCacheEntry{
+		ID:           link,
+		ETag:         etag,
+		LastModified: lastModified,
+		ContentType:  contentType,
+		Content:      buf.Bytes(),
+		LastUpdate:   time.Now(),
+	}, nil
+}
+
+//Body returns the decompressed body
+func (entry *HTTPCacheEntry) Body() ([]byte, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(entry.Content))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return ioutil.ReadAll(reader)
+}
+
+//GetHTTPCacheEntry returns the cache entry of the link, it is nil if the link is not cached
+func GetHTTPCacheEntry(link string) (*HTTPCacheEntry, error) {
+	var entry HTTPCacheEntry
+	err := database.Client.GetOne(httpCacheTable, &entry, map[string]interface{}{"_id": link})
+	if utility.IsErrorCode(err, utility.Enums().ErrorCodes.NotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+//Upsert saves the entry by its link
+func (entry *HTTPCacheEntry) Upsert() error {
+	return database.Client.UpsertOne(httpCacheTable, map[string]interface{}{"_id": entry.ID}, entry)
+}
@@ -0,0 +1,186 @@
+package models
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/sporule/grater/modules/database"
+	"github.com/sporule/grater/modules/utility"
+)
+
+//LayoutError is a page that didn't match the pattern of the rule, the html is stored once per hash in LayoutSnapshot
+type LayoutError struct {
+	ID           string           `bson:"_id" json:"id,omitempty"`
+	RuleID       string           `json:"ruleID,omitempty"`
+	Link         string           `json:"link,omitempty"`
+	Proxy        string           `json:"proxy,omitempty"`
+	StatusCode   int              `json:"statusCode,omitempty"`
+	Missing      []MissingPattern `json:"missing,omitempty"`
+	SnapshotHash string           `json:"snapshotHash,omitempty"`
+	LastUpdate   time.Time        `json:"lastUpdate,omitempty"`
+}
+
+//MissingPattern is the pattern path and the selector that matched nothing
+type MissingPattern struct {
+	Path     string `json:"path"`
+	Selector string `json:"selector"`
+}
+
+//LayoutSnapshot is the gzip compressed html of pages with layout errors, the ID is the sha256 of the html
+type LayoutSnapshot struct {
+	ID         string    `bson:"_id" json:"id,omitempty"`
+	Content    []byte    `json:"-"`
+	Size       int       `json:"size,omitempty"`
+	LastUpdate time.Time `json:"lastUpdate,omitempty"`
+}
+
+//LayoutErrorGroup is the number of layout errors of the rule where the selector matched nothing
+type LayoutErrorGroup struct {
+	RuleID       string    `json:"ruleID"`
+	Path         string    `json:"path"`
+	Selector     string    `json:"selector"`
+	Count        int64     `json:"count"`
+	FirstSeen    time.Time `json:"firstSeen"`
+	LastSeen     time.Time `json:"lastSeen"`
+	LatestLink   string    `json:"latestLink"`
+	SnapshotHash string    `json:"snapshotHash"`
+}
+
+const layoutErrorTable = "layouterror"
+const layoutSnapshotTable = "layoutsnapshot"
+
+//NewLayoutError is the constructor of LayoutError, the returned snapshot should be saved with the error
+func NewLayoutError(ruleID, link, proxy string, statusCode int, missing []MissingPattern, html string) (*LayoutError, *LayoutSnapshot, error) {
+	snapshot, err := NewLayoutSnapshot(html)
+	if err != nil {
+		return nil, nil, err
+	}
+	id, _ := uuid.NewRandom()
+	return &LayoutError{
+		ID:           id.String(),
+		RuleID:       ruleID,
+		Link:         link,
+		Proxy:        proxy,
+		StatusCode:   statusCode,
+		Missing:      missing,
+		SnapshotHash: snapshot.ID,
+		LastUpdate:   time.Now(),
+	}, snapshot, nil
+}
+
+//NewLayoutSnapshot compresses the html, identical pages get the same ID
+func NewLayoutSnapshot(html string) (*LayoutSnapshot, error) {
+	hash := sha256.Sum256([]byte(html))
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write([]byte(html)); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return &LayoutSnapshot{
+		ID:         hex.EncodeToString(hash[:]),
+		Content:    buf.Bytes(),
+		Size:       len(html),
+		LastUpdate: time.Now(),
+	}, nil
+}
+
+//HTML decompresses the html of the snapshot
+func (snapshot *LayoutSnapshot) HTML() (string, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(snapshot.Content))
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+	html, err := ioutil.ReadAll(reader)
+	return string(html), err
+}
+
+//InsertLayoutErrors saves the layout errors and the snapshots, snapshots are upserted by hash so each html is stored once
+func InsertLayoutErrors(layoutErrors []LayoutError, snapshots []LayoutSnapshot) error {
+	for _, snapshot := range snapshots {
+		filters := map[string]interface{}{"_id": snapshot.ID}
+		if err := database.Client.UpsertOne(layoutSnapshotTable, filters, snapshot); err != nil {
+			return err
+		}
+	}
+	if len(layoutErrors) <= 0 {
+		return nil
+	}
+	layoutErrorsAsInterface := make([]interface{}, len(layoutErrors))
+	for i, layoutError := range layoutErrors {
+		layoutErrorsAsInterface[i] = layoutError
+	}
+	return database.Client.InsertMany(layoutErrorTable, layoutErrorsAsInterface)
+}
+
+//layoutErrorFilters returns the filters by rule and time range, the empty values are ignored
+func layoutErrorFilters(ruleID string, from, to time.Time) map[string]interface{} {
+	filters := make(map[string]interface{})
+	if !utility.IsNil(ruleID) {
+		filters["ruleid"] = ruleID
+	}
+	if !from.IsZero() || !to.IsZero() {
+		var fromValue, toValue interface{}
+		if !from.IsZero() {
+			fromValue = from
+		}
+		if !to.IsZero() {
+			toValue = to
+		}
+		filters["lastupdate"] = database.Client.BetweenQry(fromValue, toValue)
+	}
+	return filters
+}
+
+//GetLayoutErrors returns layout errors by rule, time range and page, the latest come first
+func GetLayoutErrors(ruleID string, from, to time.Time, page int) ([]LayoutError, error) {
+	layoutErrors := []LayoutError{}
+	sortByMap := map[string]interface{}{"lastupdate": -1}
+	return layoutErrors, database.Client.GetAll(layoutErrorTable, &layoutErrors, layoutErrorFilters(ruleID, from, to), sortByMap, page)
+}
+
+//GroupLayoutErrors counts the layout errors by rule and failing selector in the database, the groups with the most errors come first
+func GroupLayoutErrors(ruleID string, from, to time.Time) ([]LayoutErrorGroup, error) {
+	//a page that matched every selector but still failed the pattern has no missing selector and is grouped with an empty path
+	rows, err := database.Client.UnwindGroups(layoutErrorTable, layoutErrorFilters(ruleID, from, to), "missing",
+		[]string{"ruleid", "missing.path", "missing.selector"}, "lastupdate", []string{"link", "snapshothash"})
+	if err != nil {
+		return nil, err
+	}
+	groups := make([]LayoutErrorGroup, len(rows))
+	for i, row := range rows {
+		groups[i].RuleID, _ = row["ruleid"].(string)
+		groups[i].Path, _ = row["path"].(string)
+		groups[i].Selector, _ = row["selector"].(string)
+		groups[i].Count, _ = row["count"].(int64)
+		groups[i].FirstSeen, _ = row["first"].(time.Time)
+		groups[i].LastSeen, _ = row["last"].(time.Time)
+		groups[i].LatestLink, _ = row["link"].(string)
+		groups[i].SnapshotHash, _ = row["snapshothash"].(string)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].Count != groups[j].Count {
+			return groups[i].Count > groups[j].Count
+		}
+		return groups[i].LastSeen.After(groups[j].LastSeen)
+	})
+	return groups, nil
+}
+
+//GetLayoutSnapshot returns the snapshot by hash
+func GetLayoutSnapshot(hash string) (*LayoutSnapshot, error) {
+	var snapshot LayoutSnapshot
+	filters := map[string]interface{}{"_id": hash}
+	err := database.Client.GetOne(layoutSnapshotTable, &snapshot, filters)
+	return &snapshot, err
+}
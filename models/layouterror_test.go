@@ -0,0 +1,63 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sporule/grater/modules/database"
+)
+
+func TestLayoutSnapshot(t *testing.T) {
+	html := "<html><body>" + string(make([]byte, 4096)) + "</body></html>"
+	snapshot, err := NewLayoutSnapshot(html)
+	assert.Nil(t, err)
+	assert.Equal(t, len(html), snapshot.Size)
+	assert.Less(t, len(snapshot.Content), len(html), "html should be compressed")
+	decompressed, err := snapshot.HTML()
+	assert.Nil(t, err)
+	assert.Equal(t, html, decompressed)
+
+	same, _ := NewLayoutSnapshot(html)
+	other, _ := NewLayoutSnapshot("<html></html>")
+	assert.Equal(t, snapshot.ID, same.ID, "identical pages should share the snapshot")
+	assert.NotEqual(t, snapshot.ID, other.ID)
+}
+
+//fakeGroupDatabase returns the groups of the layout errors like the database aggregation and keeps the query
+type fakeGroupDatabase struct {
+	database.Database
+	filters       map[string]interface{}
+	unwindField   string
+	groupByFields []string
+}
+
+func (db *fakeGroupDatabase) UnwindGroups(table string, filtersMap map[string]interface{}, unwindField string, groupByFields []string, timeField string, latestFields []string) ([]map[string]interface{}, error) {
+	db.filters, db.unwindField, db.groupByFields = filtersMap, unwindField, groupByFields
+	first := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	return []map[string]interface{}{
+		{"ruleid": "rule1", "count": int64(1), "first": first, "last": first, "link": "https://example.com/1"},
+		{"ruleid": "rule1", "path": "price", "selector": "span.price", "count": int64(3), "first": first, "last": first.Add(time.Hour), "link": "https://example.com/2", "snapshothash": "abc"},
+	}, nil
+}
+
+func TestGroupLayoutErrors(t *testing.T) {
+	db := &fakeGroupDatabase{}
+	previous := database.Client
+	database.Client = db
+	defer func() { database.Client = previous }()
+
+	groups, err := GroupLayoutErrors("rule1", time.Time{}, time.Time{})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]interface{}{"ruleid": "rule1"}, db.filters)
+	assert.Equal(t, "missing", db.unwindField, "every missing selector should be counted")
+	assert.Equal(t, []string{"ruleid", "missing.path", "missing.selector"}, db.groupByFields)
+	assert.Equal(t, 2, len(groups))
+	assert.Equal(t, LayoutErrorGroup{
+		RuleID: "rule1", Path: "price", Selector: "span.price", Count: 3,
+		FirstSeen: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC), LastSeen: time.Date(2021, 1, 1, 1, 0, 0, 0, time.UTC),
+		LatestLink: "https://example.com/2", SnapshotHash: "abc",
+	}, groups[0], "the group with the most errors should come first")
+	assert.Equal(t, "", groups[1].Path, "a page without a missing selector should have an empty path")
+}
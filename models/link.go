@@ -1,38 +1,44 @@
 package models
 
 import (
-	"errors"
 	"time"
 
 	"github.com/google/uuid"
 
 	"github.com/sporule/grater/modules/database"
+	"github.com/sporule/grater/modules/logger"
 	"github.com/sporule/grater/modules/utility"
 )
 
-//Link are the links/tasks that are waiting to be scraped
+//Link are the links/tasks that are waiting to be scraped, attempts is how many times the link was reset after its scraper stopped responding
+//and rule version is the version of the rule the link is generated with
 type Link struct {
-	ID         string `bson:"_id" json:"id,omitempty"`
-	Link       string `json:"link,omitempty"`
-	Status     string `json:"status,omitempty"`
-	Scraper    string `json:"scraper,omitempty"`
-	RuleID     string `json:"ruleID,omitempty"`
-	LastUpdate time.Time
+	ID          string `bson:"_id" json:"id,omitempty"`
+	Link        string `json:"link,omitempty"`
+	Status      string `json:"status,omitempty"`
+	Scraper     string `json:"scraper,omitempty"`
+	RuleID      string `json:"ruleID,omitempty"`
+	RuleVersion int    `json:"ruleVersion,omitempty"`
+	Attempts    int    `json:"attempts,omitempty"`
+	LastUpdate  time.Time
 }
 
 const linkTable = "link"
 
+var modelLogger = logger.New("component", "models")
+
 //NewLink is the constructor of Rule
-func NewLink(link, ruleID string) (*Link, error) {
+func NewLink(link, ruleID string, ruleVersion int) (*Link, error) {
 	if utility.IsNil(link, ruleID) {
-		return nil, errors.New(utility.Enums().ErrorMessages.LackOfInfo)
+		return nil, utility.ValidationError(utility.Enums().ErrorMessages.LackOfInfo, nil)
 	}
 	id, _ := uuid.NewRandom()
 	return &Link{
-		ID:     id.String(),
-		Link:   link,
-		Status: utility.Enums().Status.Active,
-		RuleID: ruleID,
+		ID:          id.String(),
+		Link:        link,
+		Status:      utility.Enums().Status.Active,
+		RuleID:      ruleID,
+		RuleVersion: ruleVersion,
 	}, nil
 }
 
@@ -46,6 +52,124 @@ func GetLinks(ruleID, status string, page int) ([]Link, error) {
 	return links, database.Client.GetAll(linkTable, &links, filters, nil, page)
 }
 
+//LinkFilter selects links for the admin api, ages are durations such as 30m or 2h and they are compared with the last update
+type LinkFilter struct {
+	LinkIDs   []string `json:"linkids"`
+	RuleID    string   `json:"ruleid"`
+	Status    string   `json:"status"`
+	Scraper   string   `json:"scraper"`
+	OlderThan string   `json:"olderthan"`
+	NewerThan string   `json:"newerthan"`
+}
+
+//Filters converts the link filter to database filters
+func (filter LinkFilter) Filters() (map[string]interface{}, error) {
+	filters := make(map[string]interface{})
+	if len(filter.LinkIDs) > 0 {
+		filters["_id"] = database.Client.InQry(filter.LinkIDs)
+	}
+	if !utility.IsNil(filter.RuleID) {
+		filters["ruleid"] = filter.RuleID
+	}
+	if !utility.IsNil(filter.Status) {
+		filters["status"] = filter.Status
+	}
+	if !utility.IsNil(filter.Scraper) {
+		filters["scraper"] = filter.Scraper
+	}
+	if !utility.IsNil(filter.OlderThan) || !utility.IsNil(filter.NewerThan) {
+		var from, to interface{}
+		if !utility.IsNil(filter.OlderThan) {
+			age, err := time.ParseDuration(filter.OlderThan)
+			if err != nil {
+				return nil, utility.ValidationError("Invalid age, it should be a duration such as 30m or 2h", map[string]string{"olderthan": filter.OlderThan})
+			}
+			to = time.Now().Add(-age)
+		}
+		if !utility.IsNil(filter.NewerThan) {
+			age, err := time.ParseDuration(filter.NewerThan)
+			if err != nil {
+				return nil, utility.ValidationError("Invalid age, it should be a duration such as 30m or 2h", map[string]string{"newerthan": filter.NewerThan})
+			}
+			from = time.Now().Add(-age)
+		}
+		filters["lastupdate"] = database.Client.BetweenQry(from, to)
+	}
+	return filters, nil
+}
+
+//bulkFilters converts the link filter to database filters for bulk changes, it requires link ids or rule id so a typo can't change every link
+func (filter LinkFilter) bulkFilters() (map[string]interface{}, error) {
+	if len(filter.LinkIDs) <= 0 && utility.IsNil(filter.RuleID) {
+		return nil, utility.ValidationError(utility.Enums().ErrorMessages.LackOfInfo, map[string]string{"linkids": "either linkids or ruleid is required"})
+	}
+	return filter.Filters()
+}
+
+//FindLinks returns links by filter and page, the latest updated links come first
+func FindLinks(filter LinkFilter, page int) ([]Link, error) {
+	filters, err := filter.Filters()
+	if err != nil {
+		return nil, err
+	}
+	links := []Link{}
+	return links, database.Client.GetAll(linkTable, &links, filters, map[string]interface{}{"lastupdate": -1}, page)
+}
+
+//RequeueLinks sets the links back to active with empty scraper so they will be allocated again
+func RequeueLinks(filter LinkFilter) error {
+	filters, err := filter.bulkFilters()
+	if err != nil {
+		return err
+	}
+	updatesFields := map[string]interface{}{"status": utility.Enums().Status.Active, "scraper": "", "attempts": 0}
+	return UpdateManyLinks(filters, updatesFields)
+}
+
+//CancelLinks sets the links to cancelled, completed links are kept unless the status filter is set
+func CancelLinks(filter LinkFilter) error {
+	filters, err := filter.bulkFilters()
+	if err != nil {
+		return err
+	}
+	if utility.IsNil(filter.Status) {
+		filters["status"] = database.Client.NotEqualQry(utility.Enums().Status.Completed)
+	}
+	updatesFields := map[string]interface{}{"status": utility.Enums().Status.Cancelled, "scraper": ""}
+	return UpdateManyLinks(filters, updatesFields)
+}
+
+//DeleteLinks deletes the links
+func DeleteLinks(filter LinkFilter) error {
+	filters, err := filter.bulkFilters()
+	if err != nil {
+		return err
+	}
+	return database.Client.DeleteMany(linkTable, filters)
+}
+
+//LinkCount is the number of links of the rule in the status
+type LinkCount struct {
+	RuleID string `json:"ruleID"`
+	Status string `json:"status"`
+	Count  int64  `json:"count"`
+}
+
+//CountLinks returns the number of links by rule and status, filters can narrow down the links
+func CountLinks(filters map[string]interface{}) ([]LinkCount, error) {
+	groups, err := database.Client.CountGroups(linkTable, filters, []string{"ruleid", "status"})
+	if err != nil {
+		return nil, err
+	}
+	counts := make([]LinkCount, len(groups))
+	for i, group := range groups {
+		counts[i].RuleID, _ = group["ruleid"].(string)
+		counts[i].Status, _ = group["status"].(string)
+		counts[i].Count, _ = group["count"].(int64)
+	}
+	return counts, nil
+}
+
 //AddLinks inserts a list of links to the database
 func AddLinks(links []Link) error {
 	linkAsInterface := make([]interface{}, len(links))
@@ -57,10 +181,10 @@ func AddLinks(links []Link) error {
 }
 
 //AddLinksRaw inserts a list of link strings to the database
-func AddLinksRaw(linkStrs []string, ruleID string) error {
+func AddLinksRaw(linkStrs []string, ruleID string, ruleVersion int) error {
 	var links []Link
 	for _, linkStr := range linkStrs {
-		link, err := NewLink(linkStr, ruleID)
+		link, err := NewLink(linkStr, ruleID, ruleVersion)
 		if err != nil {
 			return err
 		}
@@ -89,7 +213,7 @@ func AllocateLinks(ruleID, scraper string) ([]Link, error) {
 		return nil, err
 	}
 	if links == nil {
-		return nil, errors.New(utility.Enums().ErrorMessages.RecordNotFound)
+		return nil, utility.NotFoundError(utility.Enums().ErrorMessages.RecordNotFound)
 	}
 	//update links status to running
 	var ids []string
@@ -98,16 +222,42 @@ func AllocateLinks(ruleID, scraper string) ([]Link, error) {
 	}
 	filters := map[string]interface{}{"_id": database.Client.InQry(ids)}
 	updatesFields := map[string]interface{}{"status": utility.Enums().Status.Running, "scraper": scraper}
+	modelLogger.Debug("Links allocated", "rule", ruleID, "scraper", scraper, "links", len(ids))
 	return links, UpdateManyLinks(filters, updatesFields)
 }
 
-//ResetInactiveLinks sets tasks that are running for more than 1 hour back to Active with empty scraper
-func ResetInactiveLinks() error {
+//ResetInactiveLinks sets tasks that are running for more than 1 hour back to Active with empty scraper, links that reached maxAttempts are moved to Failed as dead letters
+func ResetInactiveLinks(maxAttempts int) error {
 	//default time is 60 minutes
 	timeLimit := time.Now().Add(-time.Minute * 60)
-	filters := map[string]interface{}{"lastupdate": database.Client.LessThanQry(timeLimit), "status": utility.Enums().Status.Running}
-	updatesFields := map[string]interface{}{"status": utility.Enums().Status.Active, "scraper": ""}
-	return UpdateManyLinks(filters, updatesFields)
+	filters := map[string]interface{}{
+		"lastupdate": database.Client.LessThanQry(timeLimit),
+		"status":     utility.Enums().Status.Running,
+		"attempts":   database.Client.GreaterThanQry(maxAttempts - 1),
+	}
+	updatesFields := map[string]interface{}{"status": utility.Enums().Status.Failed, "scraper": "", "lastupdate": time.Now()}
+	if err := database.Client.UpdateMany(linkTable, filters, updatesFields); err != nil {
+		return err
+	}
+	filters = map[string]interface{}{"lastupdate": database.Client.LessThanQry(timeLimit), "status": utility.Enums().Status.Running}
+	updatesFields = map[string]interface{}{"status": utility.Enums().Status.Active, "scraper": "", "lastupdate": time.Now()}
+	return database.Client.IncrementMany(linkTable, filters, map[string]interface{}{"attempts": 1}, updatesFields)
+}
+
+//ActiveScrapers returns the scrapers that allocated or completed links since the time
+func ActiveScrapers(since time.Time) ([]string, error) {
+	filters := map[string]interface{}{"lastupdate": database.Client.GreaterThanQry(since), "scraper": database.Client.NotEqualQry("")}
+	groups, err := database.Client.CountGroups(linkTable, filters, []string{"scraper"})
+	if err != nil {
+		return nil, err
+	}
+	scrapers := make([]string, 0, len(groups))
+	for _, group := range groups {
+		if scraper, ok := group["scraper"].(string); ok {
+			scrapers = append(scrapers, scraper)
+		}
+	}
+	return scrapers, nil
 }
 
 //CancelInactiveLinks sets the incompleted links status to cancelled for given rule id
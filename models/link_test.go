@@ -0,0 +1,101 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sporule/grater/modules/database"
+	"github.com/sporule/grater/modules/utility"
+)
+
+type greaterThan struct{ value interface{} }
+type lessThan struct{ value interface{} }
+
+//fakeLinkDatabase keeps one link in memory and applies the update and increment queries of ResetInactiveLinks to it
+type fakeLinkDatabase struct {
+	database.Database
+	link *Link
+}
+
+func (db fakeLinkDatabase) matches(filtersMap map[string]interface{}) bool {
+	for key, filter := range filtersMap {
+		switch key {
+		case "status":
+			if db.link.Status != filter {
+				return false
+			}
+		case "lastupdate":
+			if !db.link.LastUpdate.Before(filter.(lessThan).value.(time.Time)) {
+				return false
+			}
+		case "attempts":
+			if db.link.Attempts <= filter.(greaterThan).value.(int) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (db fakeLinkDatabase) update(updatesFieldsMap map[string]interface{}) {
+	for key, value := range updatesFieldsMap {
+		switch key {
+		case "status":
+			db.link.Status = value.(string)
+		case "scraper":
+			db.link.Scraper = value.(string)
+		case "attempts":
+			db.link.Attempts = value.(int)
+		case "lastupdate":
+			db.link.LastUpdate = value.(time.Time)
+		}
+	}
+}
+
+func (db fakeLinkDatabase) UpdateMany(table string, filtersMap map[string]interface{}, updatesFieldsMap map[string]interface{}) error {
+	if db.matches(filtersMap) {
+		db.update(updatesFieldsMap)
+	}
+	return nil
+}
+
+func (db fakeLinkDatabase) IncrementMany(table string, filtersMap map[string]interface{}, incrementsMap map[string]interface{}, updatesFieldsMap map[string]interface{}) error {
+	if db.matches(filtersMap) {
+		db.link.Attempts += incrementsMap["attempts"].(int)
+		db.update(updatesFieldsMap)
+	}
+	return nil
+}
+
+func (db fakeLinkDatabase) GreaterThanQry(value interface{}) interface{} {
+	return greaterThan{value}
+}
+
+func (db fakeLinkDatabase) LessThanQry(value interface{}) interface{} {
+	return lessThan{value}
+}
+
+func TestResetInactiveLinks(t *testing.T) {
+	previous := database.Client
+	defer func() { database.Client = previous }()
+
+	for _, maxAttempts := range []int{1, 3} {
+		link := &Link{Status: utility.Enums().Status.Active}
+		database.Client = fakeLinkDatabase{link: link}
+		resets := 0
+		for stall := 1; stall <= maxAttempts+1; stall++ {
+			//the scraper allocated the link and stopped responding
+			link.Status, link.Scraper, link.LastUpdate = utility.Enums().Status.Running, "scraper1", time.Now().Add(-2*time.Hour)
+			assert.Nil(t, ResetInactiveLinks(maxAttempts))
+			assert.Empty(t, link.Scraper)
+			if link.Status == utility.Enums().Status.Active {
+				resets++
+			}
+		}
+		assert.Equal(t, maxAttempts, resets, "links should be reset maxAttempts times")
+		assert.Equal(t, utility.Enums().Status.Failed, link.Status, "links should be moved to Failed on the stall after the last reset")
+		assert.Equal(t, maxAttempts, link.Attempts, "attempts should only count the resets")
+	}
+}
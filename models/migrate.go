@@ -0,0 +1,52 @@
+package models
+
+import (
+	"strings"
+
+	"github.com/sporule/grater/modules/database"
+)
+
+//index is a database index the queries of the models rely on
+type index struct {
+	table  string
+	fields []string
+	unique bool
+}
+
+//indexes are created by Migrate, the result tables are added by rule because they are named by the targetLocation
+var indexes = []index{
+	{table: linkTable, fields: []string{"status", "ruleid"}},
+	{table: linkTable, fields: []string{"ruleid", "status"}},
+	{table: linkTable, fields: []string{"lastupdate"}},
+	{table: ruleTable, fields: []string{"status"}},
+	{table: ruleVersionTable, fields: []string{"ruleid", "version"}, unique: true},
+	{table: ruleStatTable, fields: []string{"lastupdate", "ruleid"}},
+	{table: layoutErrorTable, fields: []string{"ruleid", "lastupdate"}},
+	{table: userTable, fields: []string{"email"}, unique: true},
+	{table: apiKeyTable, fields: []string{"prefix"}},
+	{table: pageTable, fields: []string{"ruleid", "lastupdate"}},
+}
+
+//Migrate ensures the indexes and returns them as table.fields, existing indexes are kept so it is safe to run again
+func Migrate() ([]string, error) {
+	rules, err := GetRules(nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	all := append([]index{}, indexes...)
+	tables := make(map[string]bool)
+	for _, rule := range rules {
+		if rule.TargetLocation != "" && !tables[rule.TargetLocation] {
+			tables[rule.TargetLocation] = true
+			all = append(all, index{table: rule.TargetLocation, fields: []string{"ruleid", "lastupdate"}}, index{table: rule.TargetLocation, fields: []string{"pageid"}})
+		}
+	}
+	var migrated []string
+	for _, index := range all {
+		if err := database.Client.EnsureIndex(index.table, index.fields, index.unique); err != nil {
+			return migrated, err
+		}
+		migrated = append(migrated, index.table+"."+strings.Join(index.fields, "_"))
+	}
+	return migrated, nil
+}
@@ -0,0 +1,96 @@
+package models
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sporule/grater/modules/database"
+)
+
+//Page is the gzip compressed body of a page a scraper fetched, the results of the page are linked to it by page id
+//so they can be extracted again after the pattern is fixed, the parent value is the value of the parent page of a deep link
+type Page struct {
+	ID          string    `bson:"_id" json:"id,omitempty"`
+	RuleID      string    `json:"ruleID,omitempty"`
+	Link        string    `json:"link,omitempty"`
+	ParentValue string    `json:"parentValue,omitempty"`
+	ContentType string    `json:"contentType,omitempty"`
+	Content     []byte    `json:"-"`
+	LastUpdate  time.Time `json:"lastUpdate,omitempty"`
+}
+
+const pageTable = "page"
+
+//NewPage is the constructor of Page, the body is compressed
+func NewPage(ruleID, link, parentValue, contentType string, body []byte) (*Page, error) {
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write(body); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	id, _ := uuid.NewRandom()
+	return &Page{
+		ID:          id.String(),
+		RuleID:      ruleID,
+		Link:        link,
+		ParentValue: parentValue,
+		ContentType: contentType,
+		Content:     buf.Bytes(),
+		LastUpdate:  time.Now(),
+	}, nil
+}
+
+//Body returns the decompressed body
+func (page *Page) Body() ([]byte, error) {
+	reader, err := gzip.NewReader(bytes.NewReader(page.Content))
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return ioutil.ReadAll(reader)
+}
+
+//InsertPages inserts the pages
+func InsertPages(pages []*Page) error {
+	items := make([]interface{}, len(pages))
+	for i, page := range pages {
+		items[i] = page
+	}
+	return database.Client.InsertMany(pageTable, items)
+}
+
+//StreamPages calls the callback for every page of the rule in the time range in the order they were fetched, the zero times are ignored
+func StreamPages(ruleID string, from, to time.Time, callback func(page Page) error) error {
+	filters := map[string]interface{}{"ruleid": ruleID}
+	if !from.IsZero() || !to.IsZero() {
+		var fromValue, toValue interface{}
+		if !from.IsZero() {
+			fromValue = from
+		}
+		if !to.IsZero() {
+			toValue = to
+		}
+		filters["lastupdate"] = database.Client.BetweenQry(fromValue, toValue)
+	}
+	cursor, err := database.Client.GetCursor(pageTable, filters, map[string]interface{}{"lastupdate": 1})
+	if err != nil {
+		return err
+	}
+	defer cursor.Close()
+	for cursor.Next() {
+		var page Page
+		if err := cursor.Decode(&page); err != nil {
+			return err
+		}
+		if err := callback(page); err != nil {
+			return err
+		}
+	}
+	return cursor.Err()
+}
@@ -0,0 +1,299 @@
+package models
+
+import (
+	"go/token"
+	"go/types"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/cascadia"
+	"github.com/antchfx/xpath"
+
+	"github.com/sporule/grater/modules/jsonpath"
+)
+
+//patternKeys are the keys of an item with a selector or a json path, the other items are groups of named items
+var patternKeys = map[string]bool{"pattern": true, "selector": true, "source": true, "type": true, "fallback": true, "value": true, "dataType": true, "currency": true, "format": true, "all": true, "postprocess": true, "validation": true, "children": true}
+
+//structuredSources are the structured data the items can read with a json path instead of a selector
+var structuredSources = map[string]bool{"jsonld": true, "microdata": true, "opengraph": true}
+
+//valueExtractors are the values of html items besides attr:name|fallback
+var valueExtractors = map[string]bool{"text": true, "html": true, "outerHtml": true, "ownText": true}
+
+//currencyCode is an ISO 4217 currency code
+var currencyCode = regexp.MustCompile(`^[A-Z]{3}$`)
+
+//validateSelector checks the css selector compiles the same way goquery compiles it, goquery matches nothing for an invalid selector
+func validateSelector(selector string) string {
+	if strings.TrimSpace(selector) == "" {
+		return "required"
+	}
+	if _, err := cascadia.Compile(selector); err != nil {
+		return "invalid css selector: " + err.Error()
+	}
+	return ""
+}
+
+//validateSelectorOrPath checks the css selector, the xpath or the regex of html rules or the json path of json rules
+func validateSelectorOrPath(selector, selectorType string, isJSON bool) string {
+	if isJSON {
+		if err := jsonpath.Validate(selector); err != nil {
+			return "invalid json path: " + err.Error()
+		}
+		return ""
+	}
+	switch selectorType {
+	case "xpath":
+		if _, err := xpath.Compile(selector); err != nil {
+			return "invalid xpath: " + err.Error()
+		}
+	case "regex":
+		if _, err := regexp.Compile(selector); err != nil {
+			return "invalid regex: " + err.Error()
+		}
+	default:
+		return validateSelector(selector)
+	}
+	return ""
+}
+
+//validatePattern walks the pattern tree the way the scraper parses it, the invalid fields are added with their path such as pattern.price.value
+func validatePattern(item map[string]interface{}, path string, isJSON bool, invalid map[string]string) {
+	if len(item) == 0 {
+		invalid[path] = "it should have at least one item"
+		return
+	}
+	if _, ok := item["pattern"]; !ok {
+		keys := make([]string, 0, len(item))
+		for key := range item {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			child, ok := item[key].(map[string]interface{})
+			if !ok {
+				invalid[path+"."+key] = "it should be an object with a pattern or a group of items"
+				continue
+			}
+			validatePattern(child, path+"."+key, isJSON, invalid)
+		}
+		return
+	}
+	for key := range item {
+		if !patternKeys[key] {
+			invalid[path+"."+key] = "unknown key, it should be one of all, children, currency, dataType, fallback, format, pattern, postprocess, selector, source, type, validation and value"
+		}
+	}
+	if _, ok := item["source"]; ok {
+		validateStructuredPattern(item, path, isJSON, invalid)
+		return
+	}
+	for _, key := range []string{"type", "fallback"} {
+		if _, ok := item[key]; ok {
+			invalid[path+"."+key] = "it is only supported by items with a source"
+		}
+	}
+	selectorType, ok := item["selector"].(string)
+	if _, hasSelector := item["selector"]; hasSelector && isJSON {
+		invalid[path+".selector"] = "it is not supported by json rules"
+	} else if hasSelector && (!ok || (selectorType != "css" && selectorType != "xpath" && selectorType != "regex")) {
+		invalid[path+".selector"] = "it should be css, xpath or regex"
+	}
+	if all, hasAll := item["all"]; hasAll && isJSON {
+		invalid[path+".all"] = "it is not supported by json rules"
+	} else if _, ok := all.(bool); hasAll && !ok {
+		invalid[path+".all"] = "it should be true or false"
+	}
+	if selector, ok := item["pattern"].(string); !ok {
+		invalid[path+".pattern"] = "it should be a css selector, an xpath, a regex or a json path"
+	} else if message := validateSelectorOrPath(selector, selectorType, isJSON); message != "" {
+		invalid[path+".pattern"] = message
+	}
+	_, hasValue := item["value"]
+	_, hasChildren := item["children"]
+	if !hasValue && !hasChildren {
+		invalid[path] = "value or children is required"
+	}
+	if hasChildren && selectorType == "regex" {
+		invalid[path+".children"] = "a regex has no children"
+	}
+	if hasValue {
+		validateValue(item["value"], path+".value", isJSON || selectorType == "regex", invalid)
+	}
+	validateDataType(item, path, invalid)
+	if postProcess, ok := item["postprocess"]; ok {
+		validatePostProcess(postProcess, path+".postprocess", invalid)
+	}
+	if validation, ok := item["validation"]; ok {
+		validateValidation(validation, path+".validation", invalid)
+	}
+	if hasChildren {
+		if children, ok := item["children"].(map[string]interface{}); ok {
+			validatePattern(children, path+".children", isJSON, invalid)
+		} else {
+			invalid[path+".children"] = "it should be an object"
+		}
+	}
+}
+
+//validateStructuredPattern checks the source of the item and its fallback item, the pattern and the children are json paths into the structured data
+func validateStructuredPattern(item map[string]interface{}, path string, isJSON bool, invalid map[string]string) {
+	if isJSON {
+		invalid[path+".source"] = "it is not supported by json rules"
+		return
+	}
+	source, _ := item["source"].(string)
+	if !structuredSources[source] {
+		invalid[path+".source"] = "it should be jsonld, microdata or opengraph"
+	}
+	for _, key := range []string{"selector", "all"} {
+		if _, ok := item[key]; ok {
+			invalid[path+"."+key] = "it is not supported by items with a source"
+		}
+	}
+	if typeName, ok := item["type"]; ok {
+		if text, ok := typeName.(string); !ok || strings.TrimSpace(text) == "" || source == "opengraph" {
+			invalid[path+".type"] = "it should be a schema.org type such as Product, opengraph has no type"
+		}
+	}
+	if fallback, ok := item["fallback"]; ok {
+		if fallbackItem, ok := fallback.(map[string]interface{}); !ok {
+			invalid[path+".fallback"] = "it should be an item with a pattern"
+		} else if _, ok := fallbackItem["pattern"]; !ok {
+			invalid[path+".fallback"] = "it should be an item with a pattern"
+		} else {
+			validatePattern(fallbackItem, path+".fallback", false, invalid)
+		}
+	}
+	withoutSource := make(map[string]interface{}, len(item))
+	for key, value := range item {
+		if key != "source" && key != "type" && key != "fallback" && key != "selector" && key != "all" {
+			withoutSource[key] = value
+		}
+	}
+	validatePattern(withoutSource, path, true, invalid)
+}
+
+//validateDataType checks the data type of the item, an array has children instead of a value, currency is the default currency of money and format is the layout of datetime
+func validateDataType(item map[string]interface{}, path string, invalid map[string]string) {
+	dataType, _ := item["dataType"].(string)
+	if _, ok := item["dataType"]; ok && !dataTypes[dataType] {
+		invalid[path+".dataType"] = "it should be string, int, float, decimal, money, bool, datetime, url or array"
+	}
+	_, hasValue := item["value"]
+	_, hasChildren := item["children"]
+	if dataType == "array" && (hasValue || !hasChildren) {
+		invalid[path+".dataType"] = "an array should have children and no value, use all for an array of values"
+	}
+	if currency, ok := item["currency"]; ok {
+		if text, ok := currency.(string); !ok || dataType != "money" || !currencyCode.MatchString(text) {
+			invalid[path+".currency"] = "it should be an ISO 4217 code such as GBP and the dataType should be money"
+		}
+	}
+	if format, ok := item["format"]; ok {
+		if text, ok := format.(string); !ok || dataType != "datetime" || strings.TrimSpace(text) == "" {
+			invalid[path+".format"] = "it should be a Go time layout such as 02/01/2006 and the dataType should be datetime"
+		}
+	}
+}
+
+//validateValue checks the value is text, html, outerHtml, ownText or attr:name with optional fallbacks such as attr:data-src|src,
+//the value of json items and regexes is text
+func validateValue(value interface{}, path string, textOnly bool, invalid map[string]string) {
+	text, _ := value.(string)
+	if textOnly {
+		if text != "text" {
+			invalid[path] = "it should be text"
+		}
+		return
+	}
+	if valueExtractors[text] {
+		return
+	}
+	if !strings.HasPrefix(text, "attr:") {
+		invalid[path] = "it should be text, html, outerHtml, ownText or attr:<name>"
+		return
+	}
+	for _, name := range strings.Split(strings.TrimPrefix(text, "attr:"), "|") {
+		if strings.TrimSpace(name) == "" {
+			invalid[path] = "the attribute names should not be empty, e.g. attr:data-src|src"
+			return
+		}
+	}
+}
+
+//validatePostProcess checks split has a separator and an index and replace has the old and new strings
+func validatePostProcess(value interface{}, path string, invalid map[string]string) {
+	postProcess, ok := value.(map[string]interface{})
+	if !ok {
+		invalid[path] = "it should be an object of split or replace"
+		return
+	}
+	for key, parameters := range postProcess {
+		parametersStr, ok := parameters.(string)
+		if !ok {
+			invalid[path+"."+key] = "it should be a comma separated string"
+			continue
+		}
+		parts := strings.Split(parametersStr, ",")
+		switch strings.ToLower(key) {
+		case "split":
+			if len(parts) < 2 {
+				invalid[path+"."+key] = "it should be the separator and the index, e.g. -,0"
+			} else if index, err := strconv.Atoi(parts[1]); err != nil || index < 0 {
+				invalid[path+"."+key] = "the index should be a number that is not negative"
+			}
+		case "replace":
+			if len(parts) < 2 {
+				invalid[path+"."+key] = "it should be the old and the new string, e.g. £,"
+			}
+		default:
+			invalid[path+"."+key] = "unknown post process, it should be split or replace"
+		}
+	}
+}
+
+//validateValidation type checks the equation with value and parentValue replaced by a number, the scraper replaces them with the scraped text
+func validateValidation(value interface{}, path string, invalid map[string]string) {
+	validation, ok := value.(map[string]interface{})
+	if !ok {
+		invalid[path] = "it should be an object with equation and targetValue"
+		return
+	}
+	equation, ok := validation["equation"].(string)
+	if !ok || strings.TrimSpace(equation) == "" {
+		invalid[path+".equation"] = "required"
+	} else {
+		expression := strings.Replace(strings.Replace(equation, "parentValue", "1", -1), "value", "1", -1)
+		result, err := types.Eval(token.NewFileSet(), nil, token.NoPos, expression)
+		if err != nil {
+			invalid[path+".equation"] = "invalid expression: " + err.Error()
+		} else if basic, ok := result.Type.Underlying().(*types.Basic); !ok || basic.Info()&types.IsBoolean == 0 {
+			invalid[path+".equation"] = "it should be a boolean expression, e.g. 300 <= value"
+		}
+	}
+	if targetValue, ok := validation["targetValue"].(string); !ok || (targetValue != "value" && targetValue != "parentValue") {
+		invalid[path+".targetValue"] = "it should be value or parentValue"
+	}
+}
+
+//validateDeepLinkPatterns checks the parent, parent value and link selectors or json paths and the optional removeQueryString flag
+func validateDeepLinkPatterns(deepLinkPatterns string, isJSON bool, invalid map[string]string) {
+	patterns := strings.Split(deepLinkPatterns, ",")
+	if len(patterns) < 3 {
+		invalid["deeplinkPatterns"] = "it should be the parent, parent value and link selectors separated by comma"
+		return
+	}
+	for i, name := range []string{"parent", "parent value", "link"} {
+		if message := validateSelectorOrPath(patterns[i], "css", isJSON); message != "" {
+			invalid["deeplinkPatterns"] = "the " + name + " selector: " + message
+			return
+		}
+	}
+	if len(patterns) >= 4 && patterns[3] != "" && patterns[3] != "removeQueryString" {
+		invalid["deeplinkPatterns"] = "the fourth item should be removeQueryString or empty"
+	}
+}
@@ -0,0 +1,60 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/sporule/grater/modules/database"
+	"github.com/sporule/grater/modules/utility"
+)
+
+//ReextractJob runs the current pattern of the rule over the stored pages in the time range and replaces their results,
+//the status is Running until every page is extracted and then Completed or Failed
+type ReextractJob struct {
+	ID           string    `bson:"_id" json:"id,omitempty"`
+	RuleID       string    `json:"ruleID,omitempty"`
+	RuleVersion  int       `json:"ruleVersion,omitempty"`
+	From         time.Time `json:"from,omitempty"`
+	To           time.Time `json:"to,omitempty"`
+	Status       string    `json:"status,omitempty"`
+	Pages        int64     `json:"pages"`
+	Records      int64     `json:"records"`
+	LayoutErrors int64     `json:"layoutErrors"`
+	Failed       int64     `json:"failed"`
+	Error        string    `json:"error,omitempty"`
+	StartTime    time.Time `json:"startTime,omitempty"`
+	LastUpdate   time.Time `json:"lastUpdate,omitempty"`
+}
+
+const reextractJobTable = "reextractjob"
+
+//NewReextractJob is the constructor of ReextractJob, the zero times are ignored
+func NewReextractJob(rule *Rule, from, to time.Time) (*ReextractJob, error) {
+	if !from.IsZero() && !to.IsZero() && to.Before(from) {
+		return nil, utility.ValidationError("The to time should be after the from time", map[string]string{"to": to.Format(time.RFC3339)})
+	}
+	id, _ := uuid.NewRandom()
+	return &ReextractJob{
+		ID:          id.String(),
+		RuleID:      rule.ID,
+		RuleVersion: rule.Version,
+		From:        from,
+		To:          to,
+		Status:      utility.Enums().Status.Running,
+		StartTime:   time.Now(),
+		LastUpdate:  time.Now(),
+	}, nil
+}
+
+//GetReextractJob returns the job by id
+func GetReextractJob(id string) (*ReextractJob, error) {
+	var job ReextractJob
+	err := database.Client.GetOne(reextractJobTable, &job, map[string]interface{}{"_id": id})
+	return &job, err
+}
+
+//Upsert saves the progress of the job
+func (job *ReextractJob) Upsert() error {
+	job.LastUpdate = time.Now()
+	return database.Client.UpsertOne(reextractJobTable, map[string]interface{}{"_id": job.ID}, job)
+}
@@ -7,36 +7,114 @@ import (
 	"github.com/sporule/grater/modules/database"
 )
 
-//Result is the scraping result
+//Result is the scraping result, rule version is the version of the rule that scraped it,
+//the WARC file and record are the archived response of the page if the archive is on and the page id is the stored page it is extracted from
 type Result struct {
-	ID         string    `bson:"_id" json:"id,omitempty"`
-	Content    string    `json:"content,omitempty"`
-	LastUpdate time.Time `json:"lastUpdate,omitempty"`
+	ID           string    `bson:"_id" json:"id,omitempty"`
+	RuleID       string    `json:"ruleID,omitempty"`
+	RuleVersion  int       `json:"ruleVersion,omitempty"`
+	Content      string    `json:"content,omitempty"`
+	WARCFile     string    `json:"warcFile,omitempty"`
+	WARCRecordID string    `json:"warcRecordID,omitempty"`
+	PageID       string    `json:"pageID,omitempty"`
+	LastUpdate   time.Time `json:"lastUpdate,omitempty"`
 }
 
 //NewResult is the constructor of Result
-func NewResult(content string) (*Result, error) {
+func NewResult(content, ruleID string, ruleVersion int) (*Result, error) {
 	id, _ := uuid.NewRandom()
 	return &Result{
-		ID:         id.String(),
-		Content:    content,
-		LastUpdate: time.Now(),
+		ID:          id.String(),
+		RuleID:      ruleID,
+		RuleVersion: ruleVersion,
+		Content:     content,
+		LastUpdate:  time.Now(),
 	}, nil
 }
 
 //InsertManyResults inserts results to the target table
-func InsertManyResults(tableName string, results []string) error {
+func InsertManyResults(tableName string, results []*Result) error {
 	resultsInterface := make([]interface{}, len(results))
 	for i, result := range results {
-		resultTemp, _ := NewResult(result)
-		resultsInterface[i] = resultTemp
+		resultsInterface[i] = result
 	}
 	return database.Client.InsertMany(tableName, resultsInterface)
 }
 
+//ReplaceResults inserts the new results of the pages and then deletes their old results, the old results are kept if the insert fails
+func ReplaceResults(tableName string, pageIDs []string, results []*Result) error {
+	ids := make([]string, len(results))
+	for i, result := range results {
+		ids[i] = result.ID
+	}
+	if len(results) > 0 {
+		if err := InsertManyResults(tableName, results); err != nil {
+			return err
+		}
+	}
+	if len(pageIDs) <= 0 {
+		return nil
+	}
+	return database.Client.DeleteMany(tableName, map[string]interface{}{"pageid": database.Client.InQry(pageIDs), "_id": database.Client.NotInQry(ids)})
+}
+
+//CountResults returns the number of results of the rule in the table since the time
+func CountResults(tableName, ruleID string, since time.Time) (int64, error) {
+	filters := map[string]interface{}{"ruleid": ruleID, "lastupdate": database.Client.GreaterThanQry(since)}
+	groups, err := database.Client.CountGroups(tableName, filters, []string{"ruleid"})
+	if err != nil || len(groups) <= 0 {
+		return 0, err
+	}
+	count, _ := groups[0]["count"].(int64)
+	return count, nil
+}
+
 //GetResults returns results by fitlers
 func GetResults(tableName string, filtersMap map[string]interface{}, sortByMap map[string]interface{}, page int) ([]Result, error) {
 	var results []Result
 	err := database.Client.GetAll(tableName, &results, filtersMap, sortByMap, page)
 	return results, err
 }
+
+//StreamResults calls the callback for every result that matches the filters, results are read one by one from the database
+func StreamResults(tableName string, filtersMap map[string]interface{}, callback func(result Result) error) error {
+	cursor, err := OpenResults(tableName, filtersMap)
+	if err != nil {
+		return err
+	}
+	return cursor.Each(callback)
+}
+
+//ResultCursor reads the results one by one in the order of their last update
+type ResultCursor struct {
+	cursor database.Cursor
+}
+
+//OpenResults opens a cursor over the results that match the filters, Each closes it
+func OpenResults(tableName string, filtersMap map[string]interface{}) (*ResultCursor, error) {
+	cursor, err := database.Client.GetCursor(tableName, filtersMap, map[string]interface{}{"lastupdate": 1})
+	if err != nil {
+		return nil, err
+	}
+	return &ResultCursor{cursor: cursor}, nil
+}
+
+//Each calls the callback for every result and closes the cursor
+func (cursor *ResultCursor) Each(callback func(result Result) error) error {
+	defer cursor.cursor.Close()
+	for cursor.cursor.Next() {
+		var result Result
+		if err := cursor.cursor.Decode(&result); err != nil {
+			return err
+		}
+		if err := callback(result); err != nil {
+			return err
+		}
+	}
+	return cursor.cursor.Err()
+}
+
+//Close closes the cursor if it is not read
+func (cursor *ResultCursor) Close() error {
+	return cursor.cursor.Close()
+}
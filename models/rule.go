@@ -1,8 +1,9 @@
 package models
 
 import (
-	"errors"
+	"encoding/json"
 	"math/rand"
+	"net/url"
 	"strconv"
 	"strings"
 	"time"
@@ -10,10 +11,12 @@ import (
 	"github.com/google/uuid"
 
 	"github.com/sporule/grater/modules/database"
+	"github.com/sporule/grater/modules/jsonpath"
 	"github.com/sporule/grater/modules/utility"
 )
 
-//Rule sets the scraper pattern for all links
+//Rule sets the scraper pattern for all links, source is the rule file the rule is synced from and rules without source are managed by the api,
+//response type is html or json and the cursor is the json path of the next page in json responses
 type Rule struct {
 	ID               string    `bson:"_id" json:"id,omitempty"`
 	Name             string    `json:"name,omitempty"`
@@ -27,6 +30,13 @@ type Rule struct {
 	LastUpdate       time.Time `json:"lastUpdate,omitempty"`
 	Headers          string    `json:"headers,omitempty"`
 	Frequency        int       `json:"frequency,omitempty"`
+	ResponseType     string    `json:"responseType,omitempty"`
+	Cursor           string    `json:"cursor,omitempty"`
+	LayoutDrift      bool      `json:"layoutDrift,omitempty"`
+	DriftReason      string    `json:"driftReason,omitempty"`
+	DriftDetectedAt  time.Time `json:"driftDetectedAt,omitempty"`
+	Source           string    `json:"source,omitempty"`
+	Version          int       `json:"version,omitempty"`
 }
 
 const ruleTable = "rule"
@@ -34,7 +44,7 @@ const ruleTable = "rule"
 //NewRule is the constructor of Rule
 func NewRule(name, targetLocation, pattern, linkPattern, deeplinkPatterns, headers string, totalPages int) (*Rule, error) {
 	if utility.IsNil(name, pattern, targetLocation) {
-		return nil, errors.New(utility.Enums().ErrorMessages.LackOfInfo)
+		return nil, utility.ValidationError(utility.Enums().ErrorMessages.LackOfInfo, nil)
 	}
 	id, _ := uuid.NewRandom()
 	return &Rule{
@@ -51,42 +61,160 @@ func NewRule(name, targetLocation, pattern, linkPattern, deeplinkPatterns, heade
 	}, nil
 }
 
+//Validate checks the fields the scraper needs, the pattern tree, the selectors, the validation equations, the headers and the link pattern,
+//the error has the invalid fields in its details and the fields in the pattern are named by their path such as pattern.price.value
+func (rule *Rule) Validate() error {
+	invalid := make(map[string]string)
+	if utility.IsNil(rule.Name) {
+		invalid["name"] = "required"
+	}
+	if utility.IsNil(rule.TargetLocation) {
+		invalid["targetLocation"] = "required"
+	}
+	switch rule.ResponseType {
+	case "", utility.Enums().ResponseTypes.HTML, utility.Enums().ResponseTypes.JSON:
+	default:
+		invalid["responseType"] = "it should be html or json"
+	}
+	if !utility.IsNil(rule.Cursor) {
+		if !rule.IsJSON() {
+			invalid["cursor"] = "it is only supported by json rules"
+		} else if err := jsonpath.Validate(rule.Cursor); err != nil {
+			invalid["cursor"] = "invalid json path: " + err.Error()
+		}
+	}
+	switch rule.Status {
+	case "", utility.Enums().Status.Active, utility.Enums().Status.Paused, utility.Enums().Status.Cancelled:
+	default:
+		invalid["status"] = "it should be Active, Paused or Cancelled"
+	}
+	var pattern map[string]interface{}
+	if utility.IsNil(rule.Pattern) {
+		invalid["pattern"] = "required"
+	} else if err := json.Unmarshal([]byte(rule.Pattern), &pattern); err != nil {
+		invalid["pattern"] = "it should be a json string: " + err.Error()
+	} else {
+		validatePattern(pattern, "pattern", rule.IsJSON(), invalid)
+	}
+	var headers map[string]string
+	if !utility.IsNil(rule.Headers) {
+		if json.Unmarshal([]byte(rule.Headers), &headers) != nil {
+			invalid["headers"] = "it should be a json string of header names and values"
+		}
+		for name, value := range headers {
+			if name == "" || strings.ContainsAny(name, " :\t\r\n") || strings.ContainsAny(value, "\r\n") {
+				invalid["headers."+name] = "invalid header name or value"
+			}
+		}
+	}
+	if rule.TotalPages < 0 {
+		invalid["totalPages"] = "it should not be negative"
+	}
+	if rule.Frequency < 0 {
+		invalid["frequency"] = "it should not be negative"
+	}
+	if rule.TotalPages > 0 && !strings.Contains(rule.LinkPattern, "{page}") && utility.IsNil(rule.Cursor) {
+		invalid["linkPattern"] = "it should contain {page}"
+	} else if !utility.IsNil(rule.LinkPattern) {
+		link := strings.ReplaceAll(strings.ReplaceAll(rule.LinkPattern, "{page}", "1"), "{cursor}", "")
+		if parsed, err := url.ParseRequestURI(link); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			invalid["linkPattern"] = "it should be an absolute http or https url"
+		}
+	}
+	if !utility.IsNil(rule.DeepLinkPatterns) {
+		validateDeepLinkPatterns(rule.DeepLinkPatterns, rule.IsJSON(), invalid)
+	}
+	if len(invalid) > 0 {
+		return utility.ValidationError("Invalid rule", invalid)
+	}
+	return nil
+}
+
+//IsJSON returns true if the responses of the rule are parsed as json, html is the default
+func (rule *Rule) IsJSON() bool {
+	return rule.ResponseType == utility.Enums().ResponseTypes.JSON
+}
+
 //Upsert updates or inserts rule object to database, it will attach the LastUpdate time stamp to time.now()
+//and a new version is added to the history if the definition of the rule is changed
 func (rule *Rule) Upsert() error {
+	return rule.upsert(0)
+}
+
+func (rule *Rule) upsert(rolledBackFrom int) error {
 	if utility.IsNil(rule.ID) {
 		id, _ := uuid.NewRandom()
 		rule.ID = id.String()
 	}
+	previousVersion := rule.Version
+	created, err := rule.saveVersion(rolledBackFrom)
+	if err != nil {
+		return err
+	}
 	filters := map[string]interface{}{"_id": rule.ID}
 	rule.LastUpdate = time.Now()
-	return database.Client.UpsertOne(ruleTable, filters, rule)
+	if err := database.Client.UpsertOne(ruleTable, filters, rule); err != nil {
+		if created {
+			//the version of a rule that wasn't saved is removed so it isn't the latest version and the next save reuses its number
+			if deleteErr := database.Client.DeleteMany(ruleVersionTable, map[string]interface{}{"_id": ruleVersionID(rule.ID, rule.Version)}); deleteErr != nil {
+				modelLogger.Error("Unable to remove the version of the rule that wasn't saved", "rule", rule.ID, "version", rule.Version, "error", deleteErr)
+			}
+			rule.Version = previousVersion
+		}
+		return err
+	}
+	return nil
 }
 
-//GenerateLinks generates links based on Link Pattern and Page
+//GenerateLinks generates links based on Link Pattern and Page, a rule with a cursor without {page} starts from the first page only
 func (rule *Rule) GenerateLinks() ([]string, error) {
 	//page pattern is {page}
 	var links []string
 	pagePattern := "{page}"
+	if !strings.Contains(rule.LinkPattern, pagePattern) && !utility.IsNil(rule.Cursor, rule.LinkPattern) {
+		return []string{strings.ReplaceAll(rule.LinkPattern, "{cursor}", "")}, nil
+	}
 	if !strings.Contains(rule.LinkPattern, pagePattern) {
-		return nil, errors.New(utility.Enums().ErrorMessages.LackOfInfo)
+		return nil, utility.ValidationError(utility.Enums().ErrorMessages.LackOfInfo, map[string]string{"linkPattern": "it should contain " + pagePattern})
 	}
 	page := 1
 	for page <= rule.TotalPages {
-		links = append(links, strings.ReplaceAll(rule.LinkPattern, pagePattern, strconv.Itoa(page)))
+		link := strings.ReplaceAll(rule.LinkPattern, pagePattern, strconv.Itoa(page))
+		//the first page of a cursor has no cursor, the next pages are found by the scraper
+		links = append(links, strings.ReplaceAll(link, "{cursor}", ""))
 		page++
 	}
 	return links, nil
 }
 
-//GenerateAndInsertLinks generates links and Add it to the database, it also resets the incompleted links
-func (rule *Rule) GenerateAndInsertLinks() error {
-	CancelInactiveLinks(rule.ID)
+//GenerateAndInsertLinks generates links and Add it to the database, it also resets the incompleted links and returns the number of generated links
+func (rule *Rule) GenerateAndInsertLinks() (int, error) {
+	if err := CancelInactiveLinks(rule.ID); err != nil {
+		modelLogger.Warn("Unable to cancel inactive links", "rule", rule.ID, "error", err)
+	}
 	links, err := rule.GenerateLinks()
 	if err != nil {
-		return err
+		return 0, err
+	}
+	err = AddLinksRaw(links, rule.ID, rule.Version)
+	return len(links), err
+}
+
+//AddLinks validates and adds ad-hoc links to the rule, they will be cancelled by the next link generation like the generated ones
+func (rule *Rule) AddLinks(links []string) error {
+	if len(links) <= 0 {
+		return utility.ValidationError(utility.Enums().ErrorMessages.LackOfInfo, map[string]string{"links": "required"})
+	}
+	invalidLinks := make(map[string]string)
+	for _, link := range links {
+		if parsed, err := url.ParseRequestURI(link); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			invalidLinks[link] = "it should be an absolute http or https url"
+		}
 	}
-	err = AddLinksRaw(links, rule.ID)
-	return err
+	if len(invalidLinks) > 0 {
+		return utility.ValidationError("Invalid links", invalidLinks)
+	}
+	return AddLinksRaw(links, rule.ID, rule.Version)
 }
 
 //GetRule returns rule by ID
@@ -104,6 +232,12 @@ func GetRules(filtersMap map[string]interface{}, page int) ([]Rule, error) {
 	return rules, err
 }
 
+//IsTargetLocation returns true if the table is the target location of a rule, only these tables hold results
+func IsTargetLocation(tableName string) (bool, error) {
+	rules, err := GetRules(map[string]interface{}{"targetlocation": tableName}, 1)
+	return len(rules) > 0, err
+}
+
 //GetRulesWithActiveLinks returns rules with active link, it returns a slice but with only 1 item
 func GetRulesWithActiveLinks() ([]Rule, error) {
 	var rules []Rule
@@ -112,7 +246,7 @@ func GetRulesWithActiveLinks() ([]Rule, error) {
 		return nil, err
 	}
 	if len(links) < 1 {
-		return nil, errors.New("We can't find any links")
+		return nil, utility.NotFoundError("We can't find any links")
 	}
 	ruleIds := []string{}
 	for _, link := range links {
@@ -120,6 +254,20 @@ func GetRulesWithActiveLinks() ([]Rule, error) {
 			ruleIds = append(ruleIds, link.RuleID)
 		}
 	}
+	//paused rules keep their links but they are not given to scrapers
+	if pausedRules, err := GetRules(map[string]interface{}{"status": utility.Enums().Status.Paused}, 0); err == nil {
+		for _, pausedRule := range pausedRules {
+			for i := 0; i < len(ruleIds); i++ {
+				if ruleIds[i] == pausedRule.ID {
+					ruleIds = append(ruleIds[:i], ruleIds[i+1:]...)
+					i--
+				}
+			}
+		}
+	}
+	if len(ruleIds) < 1 {
+		return nil, utility.NotFoundError("We can't find any rules that are not paused")
+	}
 	rand.Seed(time.Now().Unix())
 	index := rand.Intn(len(ruleIds))
 	//return a random rule with active links
@@ -128,18 +276,46 @@ func GetRulesWithActiveLinks() ([]Rule, error) {
 	return rules, err
 }
 
+//FlagLayoutDrift marks the rule as layout drift, the rule is paused if pause is true
+func (rule *Rule) FlagLayoutDrift(reason string, pause bool) error {
+	rule.LayoutDrift = true
+	rule.DriftReason = reason
+	rule.DriftDetectedAt = time.Now()
+	if pause {
+		rule.Status = utility.Enums().Status.Paused
+	}
+	return rule.Upsert()
+}
+
+//ClearLayoutDrift removes the layout drift flag, a paused rule stays paused until it is resumed
+func (rule *Rule) ClearLayoutDrift() error {
+	rule.LayoutDrift = false
+	rule.DriftReason = ""
+	rule.DriftDetectedAt = time.Time{}
+	return rule.Upsert()
+}
+
+//Resume sets the paused rule back to active and removes the layout drift flag
+func (rule *Rule) Resume() error {
+	if rule.Status != utility.Enums().Status.Paused && !rule.LayoutDrift {
+		return utility.ConflictError("The rule is not paused")
+	}
+	rule.Status = utility.Enums().Status.Active
+	return rule.ClearLayoutDrift()
+}
+
 //CancelRule Sets the rule status to cancel by ID
 func CancelRule(id string) error {
 	rule, err := GetRule(id)
 	if err != nil {
-		return errors.New(utility.Enums().ErrorMessages.RecordNotFound)
+		return err
 	}
 	if rule.Status == utility.Enums().Status.Cancelled {
-		return errors.New(utility.Enums().ErrorMessages.RecordNotFound)
+		return utility.NotFoundError(utility.Enums().ErrorMessages.RecordNotFound)
 	}
 	rule.Status = utility.Enums().Status.Cancelled
-	if rule.Upsert() != nil {
-		return errors.New(utility.Enums().ErrorMessages.SystemError)
+	if err := rule.Upsert(); err != nil {
+		return err
 	}
 	//TODO: Cancel All Links under the Rule
 	return nil
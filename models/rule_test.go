@@ -0,0 +1,85 @@
+package models
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sporule/grater/modules/utility"
+)
+
+func TestRuleValidate(t *testing.T) {
+	rule := Rule{
+		Name:             "PS5",
+		TargetLocation:   "PS5",
+		LinkPattern:      "https://example.com/?page={page}",
+		TotalPages:       2,
+		DeepLinkPatterns: "li.item,span.bids,a,removeQueryString",
+		Headers:          `{"referer":"https://example.com/"}`,
+		Pattern:          `{"name":{"pattern":"h1","value":"text"},"price":{"pattern":"span.price","value":"attr:content","postprocess":{"replace":"£,"},"validation":{"equation":"300 <= value","targetValue":"value"}},"tags":{"pattern":"ul","children":{"tag":{"pattern":"li","value":"ownText","all":true}}},"image":{"pattern":"//img[@alt='PS5']/following-sibling::img","selector":"xpath","value":"attr:data-src|src"},"sku":{"pattern":"sku: '(\\w+)'","selector":"regex","value":"text"}}`,
+	}
+	assert.Nil(t, rule.Validate())
+
+	rule.Pattern = `{"image":{"pattern":"//img[","selector":"xpath","value":"attr:|src"},"sku":{"pattern":"(","selector":"regex","value":"html","children":{}},"tags":{"pattern":"li","selector":"jquery","value":"text","all":"yes"}}`
+	details := rule.Validate().(*utility.AppError).Details.(map[string]string)
+	assert.Equal(t, []string{"pattern.image.pattern", "pattern.image.value", "pattern.sku.children", "pattern.sku.pattern", "pattern.sku.value", "pattern.tags.all", "pattern.tags.selector"}, keys(details))
+
+	rule.Pattern = `{"price":{"source":"jsonld","type":"Product","pattern":"offers.price","value":"text","fallback":{"pattern":"span.price","value":"text"}},"title":{"source":"opengraph","pattern":"og:title","value":"text"},"offers":{"source":"microdata","pattern":"offers","children":{"price":{"pattern":"price","value":"text"}}}}`
+	assert.Nil(t, rule.Validate(), "structured items should use json paths")
+
+	rule.Pattern = `{"price":{"source":"rdfa","pattern":"offers..price","value":"attr:content","all":true,"fallback":{"pattern":"span[","value":"text"}},"title":{"source":"opengraph","type":"Product","pattern":"og:title","value":"text"},"name":{"pattern":"h1","value":"text","type":"Product"}}`
+	details = rule.Validate().(*utility.AppError).Details.(map[string]string)
+	assert.Equal(t, []string{"pattern.name.type", "pattern.price.all", "pattern.price.fallback.pattern", "pattern.price.pattern", "pattern.price.source", "pattern.price.value", "pattern.title.type"}, keys(details))
+
+	rule.Pattern = `{"price":{"pattern":"span","value":"text","dataType":"money","currency":"GBP"},"date":{"pattern":"time","value":"attr:datetime","dataType":"datetime","format":"2006-01-02"},"tags":{"pattern":"li","dataType":"array","children":{"tag":{"pattern":"span","value":"text"}}}}`
+	assert.Nil(t, rule.Validate(), "typed items should be valid")
+
+	rule.Pattern = `{"price":{"pattern":"span","value":"text","dataType":"number","currency":"£"},"date":{"pattern":"time","value":"text","format":"2006-01-02"},"tags":{"pattern":"li","value":"text","dataType":"array"}}`
+	details = rule.Validate().(*utility.AppError).Details.(map[string]string)
+	assert.Equal(t, []string{"pattern.date.format", "pattern.price.currency", "pattern.price.dataType", "pattern.tags.dataType"}, keys(details))
+
+	rule.Pattern = `{"name":{"pattern":"h1[","value":"inner"},"price":{"pattern":"span","value":"text","postprocess":{"split":"-,x"},"validation":{"equation":"value +","targetValue":"other"}},"total":{"pattern":"b","value":"text","validation":{"equation":"value + 1","targetValue":"value"}},"group":{"item":"h2"},"empty":{"pattern":"p"}}`
+	rule.Headers = `{"bad name":"x"}`
+	rule.LinkPattern = "example.com/?page={page}"
+	rule.DeepLinkPatterns = "li.item,span.bids"
+	rule.Frequency = -1
+	err := rule.Validate()
+	details = err.(*utility.AppError).Details.(map[string]string)
+	for _, field := range []string{
+		"pattern.name.pattern", "pattern.name.value", "pattern.price.postprocess.split", "pattern.price.validation.equation",
+		"pattern.price.validation.targetValue", "pattern.total.validation.equation", "pattern.group.item", "pattern.empty",
+		"headers.bad name", "linkPattern", "deeplinkPatterns", "frequency",
+	} {
+		assert.Contains(t, details, field)
+	}
+	assert.Equal(t, "it should be a boolean expression, e.g. 300 <= value", details["pattern.total.validation.equation"])
+	assert.Equal(t, 12, len(details), "only the invalid fields should be reported")
+
+	rule = Rule{Name: "PS5", TargetLocation: "PS5", Pattern: `{"name":{"pattern":"h1","value":"text"}}`, LinkPattern: "https://example.com/", TotalPages: 1}
+	details = rule.Validate().(*utility.AppError).Details.(map[string]string)
+	assert.Equal(t, "it should contain {page}", details["linkPattern"])
+
+	rule = Rule{Name: "API", TargetLocation: "API", ResponseType: "json", LinkPattern: "https://example.com/api?after={cursor}", TotalPages: 1, Cursor: "data.after", DeepLinkPatterns: "data.children,data.score,data.url",
+		Pattern: `{"title":{"pattern":"data.children.0.data.title","value":"text"},"posts":{"pattern":"data.children","children":{"id":{"pattern":"data.id","value":"text"}}}}`}
+	assert.Nil(t, rule.Validate(), "json rules should use json paths")
+	links, err := rule.GenerateLinks()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"https://example.com/api?after="}, links, "the first page of a cursor should have no cursor")
+
+	rule.Pattern = `{"title":{"pattern":"data..title","value":"attr:href"}}`
+	rule.ResponseType = "xml"
+	details = rule.Validate().(*utility.AppError).Details.(map[string]string)
+	assert.Equal(t, "it should be html or json", details["responseType"])
+	assert.Equal(t, "it is only supported by json rules", details["cursor"])
+	assert.Contains(t, details["pattern.title.pattern"], "invalid css selector")
+}
+
+func keys(details map[string]string) []string {
+	fields := make([]string, 0, len(details))
+	for field := range details {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return fields
+}
@@ -0,0 +1,92 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/sporule/grater/modules/database"
+)
+
+//RuleStat is what a scraper saw for the rule since its previous stat, the distributor compares them over time to detect layout drift
+type RuleStat struct {
+	ID                 string    `bson:"_id" json:"id,omitempty"`
+	RuleID             string    `json:"ruleID,omitempty"`
+	Scraper            string    `json:"scraper,omitempty"`
+	Pages              int64     `json:"pages"`
+	Records            int64     `json:"records"`
+	LayoutErrors       int64     `json:"layoutErrors"`
+	ValidationFailures int64     `json:"validationFailures"`
+	CacheHits          int64     `json:"cacheHits"`
+	CacheMisses        int64     `json:"cacheMisses"`
+	LastUpdate         time.Time `json:"lastUpdate,omitempty"`
+}
+
+//RuleStatTotals is the sum of the stats of a rule in a time range, the pages that are unchanged and not parsed are cache hits but not pages
+type RuleStatTotals struct {
+	Pages              int64 `json:"pages"`
+	Records            int64 `json:"records"`
+	LayoutErrors       int64 `json:"layoutErrors"`
+	ValidationFailures int64 `json:"validationFailures"`
+	CacheHits          int64 `json:"cacheHits"`
+	CacheMisses        int64 `json:"cacheMisses"`
+}
+
+//DriftReport is the layout drift evaluation of a rule, the current stats of the window are compared with the baseline
+type DriftReport struct {
+	RuleID   string         `json:"ruleID"`
+	Name     string         `json:"name"`
+	Status   string         `json:"status"`
+	Current  RuleStatTotals `json:"current"`
+	Baseline RuleStatTotals `json:"baseline"`
+	//Evaluated is false if there are not enough pages in the window
+	Evaluated bool   `json:"evaluated"`
+	Drift     bool   `json:"drift"`
+	Reason    string `json:"reason,omitempty"`
+	//Flagged is whether the rule is currently flagged as layout drift
+	Flagged bool `json:"flagged"`
+}
+
+const ruleStatTable = "rulestat"
+
+//NewRuleStat is the constructor of RuleStat
+func NewRuleStat(ruleID, scraper string, pages, records, layoutErrors, validationFailures int64) *RuleStat {
+	id, _ := uuid.NewRandom()
+	return &RuleStat{
+		ID:                 id.String(),
+		RuleID:             ruleID,
+		Scraper:            scraper,
+		Pages:              pages,
+		Records:            records,
+		LayoutErrors:       layoutErrors,
+		ValidationFailures: validationFailures,
+		LastUpdate:         time.Now(),
+	}
+}
+
+//Insert saves the stat
+func (stat *RuleStat) Insert() error {
+	return database.Client.InsertOne(ruleStatTable, stat)
+}
+
+//SumRuleStats returns the totals of every rule that has stats updated in [from, to)
+func SumRuleStats(from, to time.Time) (map[string]RuleStatTotals, error) {
+	filters := map[string]interface{}{"lastupdate": database.Client.BetweenQry(from, to)}
+	groups, err := database.Client.SumGroups(ruleStatTable, filters, []string{"ruleid"}, []string{"pages", "records", "layouterrors", "validationfailures", "cachehits", "cachemisses"})
+	if err != nil {
+		return nil, err
+	}
+	totals := make(map[string]RuleStatTotals, len(groups))
+	for _, group := range groups {
+		ruleID, _ := group["ruleid"].(string)
+		var total RuleStatTotals
+		total.Pages, _ = group["pages"].(int64)
+		total.Records, _ = group["records"].(int64)
+		total.LayoutErrors, _ = group["layouterrors"].(int64)
+		total.ValidationFailures, _ = group["validationfailures"].(int64)
+		total.CacheHits, _ = group["cachehits"].(int64)
+		total.CacheMisses, _ = group["cachemisses"].(int64)
+		totals[ruleID] = total
+	}
+	return totals, nil
+}
@@ -0,0 +1,223 @@
+package models
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/sporule/grater/modules/database"
+	"github.com/sporule/grater/modules/utility"
+)
+
+//RuleDefinition is the part of the rule that decides what is scraped, a change of it creates a new version of the rule
+type RuleDefinition struct {
+	Name             string `json:"name,omitempty"`
+	Pattern          string `json:"pattern,omitempty"`
+	Priority         int    `json:"priorty,omitempty"`
+	TargetLocation   string `json:"targetLocation,omitempty"`
+	LinkPattern      string `json:"linkPattern,omitempty"`
+	DeepLinkPatterns string `json:"deeplinkPatterns,omitempty"`
+	TotalPages       int    `json:"totalPages,omitempty"`
+	Headers          string `json:"headers,omitempty"`
+	Frequency        int    `json:"frequency,omitempty"`
+	ResponseType     string `json:"responseType,omitempty"`
+	Cursor           string `json:"cursor,omitempty"`
+}
+
+//RuleVersion is the immutable definition of the rule at a version, the ID is the rule id and the version
+type RuleVersion struct {
+	ID         string         `bson:"_id" json:"id,omitempty"`
+	RuleID     string         `json:"ruleID,omitempty"`
+	Version    int            `json:"version"`
+	Definition RuleDefinition `json:"definition"`
+	//RolledBackFrom is the version that is restored by the rollback
+	RolledBackFrom int       `json:"rolledBackFrom,omitempty"`
+	LastUpdate     time.Time `json:"lastUpdate,omitempty"`
+}
+
+//FieldChange is the old and new value of a field
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+const ruleVersionTable = "ruleversion"
+
+//Definition returns the versioned fields of the rule
+func (rule *Rule) Definition() RuleDefinition {
+	return RuleDefinition{
+		Name:             rule.Name,
+		Pattern:          rule.Pattern,
+		Priority:         rule.Priority,
+		TargetLocation:   rule.TargetLocation,
+		LinkPattern:      rule.LinkPattern,
+		DeepLinkPatterns: rule.DeepLinkPatterns,
+		TotalPages:       rule.TotalPages,
+		Headers:          rule.Headers,
+		Frequency:        rule.Frequency,
+		ResponseType:     rule.ResponseType,
+		Cursor:           rule.Cursor,
+	}
+}
+
+//setDefinition replaces the versioned fields of the rule
+func (rule *Rule) setDefinition(definition RuleDefinition) {
+	rule.Name = definition.Name
+	rule.Pattern = definition.Pattern
+	rule.Priority = definition.Priority
+	rule.TargetLocation = definition.TargetLocation
+	rule.LinkPattern = definition.LinkPattern
+	rule.DeepLinkPatterns = definition.DeepLinkPatterns
+	rule.TotalPages = definition.TotalPages
+	rule.Headers = definition.Headers
+	rule.Frequency = definition.Frequency
+	rule.ResponseType = definition.ResponseType
+	rule.Cursor = definition.Cursor
+}
+
+//sameJSON compares two json strings by value so the order of the keys doesn't matter
+func sameJSON(a, b string) bool {
+	if a == b {
+		return true
+	}
+	var valueA, valueB interface{}
+	if json.Unmarshal([]byte(a), &valueA) != nil || json.Unmarshal([]byte(b), &valueB) != nil {
+		return false
+	}
+	return reflect.DeepEqual(valueA, valueB)
+}
+
+//Diff returns the fields that are different in the other definition, pattern and headers are compared as json
+func (definition RuleDefinition) Diff(other RuleDefinition) []FieldChange {
+	var fields []FieldChange
+	compare := func(field, old, new string, same bool) {
+		if !same {
+			fields = append(fields, FieldChange{Field: field, Old: old, New: new})
+		}
+	}
+	compareString := func(field, old, new string) {
+		compare(field, old, new, old == new)
+	}
+	compareInt := func(field string, old, new int) {
+		compare(field, strconv.Itoa(old), strconv.Itoa(new), old == new)
+	}
+	compareString("name", definition.Name, other.Name)
+	compareString("linkPattern", definition.LinkPattern, other.LinkPattern)
+	compareInt("totalPages", definition.TotalPages, other.TotalPages)
+	compareInt("frequency", definition.Frequency, other.Frequency)
+	compareInt("priority", definition.Priority, other.Priority)
+	compareString("targetLocation", definition.TargetLocation, other.TargetLocation)
+	compareString("deeplinkPatterns", definition.DeepLinkPatterns, other.DeepLinkPatterns)
+	compareString("responseType", definition.ResponseType, other.ResponseType)
+	compareString("cursor", definition.Cursor, other.Cursor)
+	compare("headers", definition.Headers, other.Headers, sameJSON(definition.Headers, other.Headers))
+	compare("pattern", definition.Pattern, other.Pattern, sameJSON(definition.Pattern, other.Pattern))
+	return fields
+}
+
+func ruleVersionID(ruleID string, version int) string {
+	return ruleID + ":" + strconv.Itoa(version)
+}
+
+//GetRuleVersions returns the versions of the rule by page, the latest version comes first
+func GetRuleVersions(ruleID string, page int) ([]RuleVersion, error) {
+	versions := []RuleVersion{}
+	filters := map[string]interface{}{"ruleid": ruleID}
+	return versions, database.Client.GetAll(ruleVersionTable, &versions, filters, map[string]interface{}{"version": -1}, page)
+}
+
+//GetRuleVersion returns the version of the rule
+func GetRuleVersion(ruleID string, version int) (*RuleVersion, error) {
+	var ruleVersion RuleVersion
+	err := database.Client.GetOne(ruleVersionTable, &ruleVersion, map[string]interface{}{"_id": ruleVersionID(ruleID, version)})
+	return &ruleVersion, err
+}
+
+//latestRuleVersion returns the latest version of the rule, it is nil if the rule has no version
+func latestRuleVersion(ruleID string) (*RuleVersion, error) {
+	versions, err := GetRuleVersions(ruleID, 1)
+	if err != nil || len(versions) == 0 {
+		return nil, err
+	}
+	return &versions[0], nil
+}
+
+//DiffRuleVersions returns the fields that changed from one version to the other
+func DiffRuleVersions(ruleID string, from, to int) ([]FieldChange, error) {
+	fromVersion, err := GetRuleVersion(ruleID, from)
+	if err != nil {
+		return nil, err
+	}
+	toVersion, err := GetRuleVersion(ruleID, to)
+	if err != nil {
+		return nil, err
+	}
+	changes := fromVersion.Definition.Diff(toVersion.Definition)
+	if changes == nil {
+		changes = []FieldChange{}
+	}
+	return changes, nil
+}
+
+//insertVersion saves the definition as the next version of the rule
+func (rule *Rule) insertVersion(version, rolledBackFrom int) error {
+	ruleVersion := RuleVersion{
+		ID:             ruleVersionID(rule.ID, version),
+		RuleID:         rule.ID,
+		Version:        version,
+		Definition:     rule.Definition(),
+		RolledBackFrom: rolledBackFrom,
+		LastUpdate:     time.Now(),
+	}
+	//the id is unique so a version saved at the same time by someone else is rejected
+	if err := database.Client.InsertOne(ruleVersionTable, ruleVersion); err != nil {
+		return err
+	}
+	rule.Version = version
+	return nil
+}
+
+//saveVersion creates a new version if the definition is different from the latest version and returns true if it did,
+//a rule that was saved before the versions were kept gets its stored definition as the first version
+func (rule *Rule) saveVersion(rolledBackFrom int) (bool, error) {
+	latest, err := latestRuleVersion(rule.ID)
+	if err != nil {
+		return false, err
+	}
+	if latest == nil {
+		stored, err := GetRule(rule.ID)
+		if err != nil && !utility.IsErrorCode(err, utility.Enums().ErrorCodes.NotFound) {
+			return false, err
+		}
+		if err == nil && len(stored.Definition().Diff(rule.Definition())) > 0 {
+			if err := stored.insertVersion(1, 0); err != nil {
+				return false, err
+			}
+			latest = &RuleVersion{Version: 1, Definition: stored.Definition()}
+		}
+	}
+	if latest != nil && len(latest.Definition.Diff(rule.Definition())) == 0 {
+		rule.Version = latest.Version
+		return false, nil
+	}
+	next := 1
+	if latest != nil {
+		next = latest.Version + 1
+	}
+	return true, rule.insertVersion(next, rolledBackFrom)
+}
+
+//Rollback restores the definition of the version as a new version, the history is kept
+func (rule *Rule) Rollback(version int) error {
+	ruleVersion, err := GetRuleVersion(rule.ID, version)
+	if err != nil {
+		return err
+	}
+	if len(ruleVersion.Definition.Diff(rule.Definition())) == 0 {
+		return utility.ConflictError("The rule is already the same as version " + strconv.Itoa(version))
+	}
+	rule.setDefinition(ruleVersion.Definition)
+	return rule.upsert(version)
+}
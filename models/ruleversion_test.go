@@ -0,0 +1,94 @@
+package models
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sporule/grater/modules/database"
+	"github.com/sporule/grater/modules/utility"
+)
+
+func TestRuleDefinitionDiff(t *testing.T) {
+	rule := Rule{Name: "PS5", Status: "Active", Pattern: `{"a":1,"b":2}`, Headers: `{"referer":"x"}`, Frequency: 60}
+	same := rule
+	same.Status = "Paused"
+	same.LayoutDrift = true
+	same.Pattern = `{"b":2, "a":1}`
+	assert.Empty(t, rule.Definition().Diff(same.Definition()), "status, drift and the order of json keys should not change the version")
+
+	changed := rule
+	changed.Frequency = 3600
+	changed.Headers = `{"referer":"y"}`
+	assert.Equal(t, []FieldChange{
+		{Field: "frequency", Old: "60", New: "3600"},
+		{Field: "headers", Old: `{"referer":"x"}`, New: `{"referer":"y"}`},
+	}, rule.Definition().Diff(changed.Definition()))
+
+	changed.setDefinition(rule.Definition())
+	assert.Equal(t, rule.Definition(), changed.Definition(), "rollback should restore every versioned field")
+}
+
+//fakeRuleDatabase keeps the versions of one rule in memory, the rule upsert fails if failUpsert is set
+type fakeRuleDatabase struct {
+	database.Database
+	versions   map[string]RuleVersion
+	failUpsert bool
+}
+
+func (db *fakeRuleDatabase) GetOne(table string, item interface{}, filtersMap map[string]interface{}) error {
+	return utility.NotFoundError(utility.Enums().ErrorMessages.RecordNotFound)
+}
+
+func (db *fakeRuleDatabase) GetAll(table string, items interface{}, filtersMap map[string]interface{}, sortByMap map[string]interface{}, page int) error {
+	var latest *RuleVersion
+	for _, version := range db.versions {
+		if latest == nil || version.Version > latest.Version {
+			latest = &RuleVersion{Version: version.Version, Definition: version.Definition}
+		}
+	}
+	if latest != nil {
+		*(items.(*[]RuleVersion)) = []RuleVersion{*latest}
+	}
+	return nil
+}
+
+func (db *fakeRuleDatabase) InsertOne(table string, item interface{}) error {
+	version := item.(RuleVersion)
+	db.versions[version.ID] = version
+	return nil
+}
+
+func (db *fakeRuleDatabase) UpsertOne(table string, filtersMap map[string]interface{}, updatedItem interface{}) error {
+	if db.failUpsert {
+		return errors.New("upsert failed")
+	}
+	return nil
+}
+
+func (db *fakeRuleDatabase) DeleteMany(table string, filtersMap map[string]interface{}) error {
+	delete(db.versions, filtersMap["_id"].(string))
+	return nil
+}
+
+func TestUpsertRemovesVersionOfFailedSave(t *testing.T) {
+	db := &fakeRuleDatabase{versions: map[string]RuleVersion{}}
+	previous := database.Client
+	database.Client = db
+	defer func() { database.Client = previous }()
+
+	rule := Rule{ID: "rule1", Name: "PS5", Pattern: `{"a":1}`}
+	assert.Nil(t, rule.Upsert())
+	assert.Equal(t, 1, rule.Version)
+
+	rule.Pattern = `{"a":2}`
+	db.failUpsert = true
+	assert.NotNil(t, rule.Upsert())
+	assert.Equal(t, 1, rule.Version, "the version of the rule should not change if it wasn't saved")
+	assert.Equal(t, 1, len(db.versions), "the version of the rule that wasn't saved should be removed")
+
+	db.failUpsert = false
+	assert.Nil(t, rule.Upsert())
+	assert.Equal(t, 2, rule.Version, "the next save should reuse the version number")
+}
@@ -0,0 +1,102 @@
+package models
+
+import (
+	"encoding/json"
+	"sort"
+
+	"github.com/sporule/grater/modules/utility"
+)
+
+//dataTypes are the types the values of the pattern items are converted to, string is the default
+var dataTypes = map[string]bool{"string": true, "int": true, "float": true, "decimal": true, "money": true, "bool": true, "datetime": true, "url": true, "array": true}
+
+//DecimalPattern is the format of decimal values and money amounts, they are strings so the cents are exact
+const DecimalPattern = `^-?[0-9]+(\.[0-9]+)?$`
+
+//OutputSchema returns the JSON Schema of the records the rule scrapes, it is derived from the pattern and the data types of its items
+func (rule *Rule) OutputSchema() (map[string]interface{}, error) {
+	var pattern map[string]interface{}
+	if err := json.Unmarshal([]byte(rule.Pattern), &pattern); err != nil {
+		return nil, utility.ValidationError("Invalid pattern", map[string]string{"pattern": err.Error()})
+	}
+	schema := itemSchema(pattern)
+	//every record has the link of the page and the name of the rule
+	properties := schema["properties"].(map[string]interface{})
+	properties["link"] = map[string]interface{}{"type": "string", "format": "uri"}
+	properties["rule"] = map[string]interface{}{"type": "string"}
+	schema["required"] = append(schema["required"].([]string), "link", "rule")
+	schema["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	schema["title"] = rule.Name
+	return schema, nil
+}
+
+//itemSchema returns the schema of the result of the item, a group has every named item because a missing item makes the page a wrong page
+//and the children of an item are an array
+func itemSchema(item map[string]interface{}) map[string]interface{} {
+	_, hasPattern := item["pattern"]
+	_, hasSource := item["source"]
+	if !hasPattern && !hasSource {
+		keys := make([]string, 0, len(item))
+		properties := make(map[string]interface{}, len(item))
+		for key, value := range item {
+			if child, ok := value.(map[string]interface{}); ok {
+				keys = append(keys, key)
+				properties[key] = itemSchema(child)
+			}
+		}
+		sort.Strings(keys)
+		return map[string]interface{}{"type": "object", "properties": properties, "required": keys}
+	}
+	dataType, _ := item["dataType"].(string)
+	children, hasChildren := item["children"].(map[string]interface{})
+	value, _ := item["value"].(string)
+	hasValue := value != ""
+	if hasChildren && !hasValue {
+		return map[string]interface{}{"type": "array", "items": itemSchema(children)}
+	}
+	schema := map[string]interface{}{"type": "object"}
+	properties := make(map[string]interface{})
+	if hasValue {
+		value := valueSchema(dataType)
+		if all, _ := item["all"].(bool); all {
+			value = map[string]interface{}{"type": "array", "items": value}
+		}
+		properties["value"] = value
+		schema["required"] = []string{"value"}
+	}
+	if hasChildren {
+		//an item with a value keeps the array of its children next to it
+		properties["children"] = map[string]interface{}{"type": "array", "items": itemSchema(children)}
+		schema["required"] = []string{"value", "children"}
+	}
+	schema["properties"] = properties
+	return schema
+}
+
+//valueSchema returns the schema of a value of the data type
+func valueSchema(dataType string) map[string]interface{} {
+	switch dataType {
+	case "int":
+		return map[string]interface{}{"type": "integer"}
+	case "float":
+		return map[string]interface{}{"type": "number"}
+	case "decimal":
+		return map[string]interface{}{"type": "string", "pattern": DecimalPattern}
+	case "money":
+		return map[string]interface{}{
+			"type": "object",
+			"properties": map[string]interface{}{
+				"amount":   map[string]interface{}{"type": "string", "pattern": DecimalPattern},
+				"currency": map[string]interface{}{"type": "string", "pattern": "^[A-Z]{3}$"},
+			},
+			"required": []string{"amount"},
+		}
+	case "bool":
+		return map[string]interface{}{"type": "boolean"}
+	case "datetime":
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case "url":
+		return map[string]interface{}{"type": "string", "format": "uri"}
+	}
+	return map[string]interface{}{"type": "string"}
+}
@@ -0,0 +1,29 @@
+package models
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOutputSchema(t *testing.T) {
+	rule := Rule{Name: "PS5", Pattern: `{"product":{"name":{"pattern":"h1","value":"text"},"price":{"pattern":"span.price","value":"text","dataType":"money"}},"photos":{"pattern":"img","value":"attr:src","all":true,"dataType":"url"},"tags":{"pattern":"li","dataType":"array","children":{"tag":{"pattern":"span","value":"text"}}},"rows":{"pattern":"tr","children":{"cell":{"pattern":"td","value":"text","dataType":"int"}}},"seller":{"pattern":"div.seller","value":"attr:id","children":{"badge":{"pattern":"img","value":"attr:alt"}}}}`}
+	schema, err := rule.OutputSchema()
+	assert.Nil(t, err)
+	assert.Equal(t, "PS5", schema["title"])
+	assert.Equal(t, []string{"photos", "product", "rows", "seller", "tags", "link", "rule"}, schema["required"])
+	properties := schema["properties"].(map[string]interface{})
+	product := properties["product"].(map[string]interface{})["properties"].(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"type": "string"}, product["name"].(map[string]interface{})["properties"].(map[string]interface{})["value"])
+	assert.Equal(t, "object", product["price"].(map[string]interface{})["properties"].(map[string]interface{})["value"].(map[string]interface{})["type"])
+	assert.Equal(t, map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "string", "format": "uri"}}, properties["photos"].(map[string]interface{})["properties"].(map[string]interface{})["value"])
+	assert.Equal(t, "array", properties["tags"].(map[string]interface{})["type"], "an array item should be an array of its children")
+	assert.Equal(t, "array", properties["rows"].(map[string]interface{})["type"], "children should be an array without the array data type")
+	seller := properties["seller"].(map[string]interface{})
+	assert.Equal(t, []string{"value", "children"}, seller["required"])
+	assert.Equal(t, "array", seller["properties"].(map[string]interface{})["children"].(map[string]interface{})["type"], "the children of an item with a value should be an array next to it")
+
+	rule.Pattern = "{"
+	_, err = rule.OutputSchema()
+	assert.NotNil(t, err)
+}
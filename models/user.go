@@ -0,0 +1,117 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/sporule/grater/modules/database"
+	"github.com/sporule/grater/modules/utility"
+)
+
+//User is the human account to access the api
+type User struct {
+	ID         string    `bson:"_id" json:"id,omitempty"`
+	Email      string    `json:"email,omitempty"`
+	Name       string    `json:"name,omitempty"`
+	Password   string    `json:"-"`
+	Role       string    `json:"role,omitempty"`
+	Status     string    `json:"status,omitempty"`
+	LastUpdate time.Time `json:"lastUpdate,omitempty"`
+}
+
+const userTable = "user"
+
+//NewUser is the constructor of User, the password will be hashed
+func NewUser(email, name, password, role string) (*User, error) {
+	if utility.IsNil(email, name, password) {
+		return nil, utility.ValidationError(utility.Enums().ErrorMessages.LackOfRegInfo, nil)
+	}
+	if !IsValidRole(role) {
+		return nil, utility.ValidationError("Invalid role: "+role, map[string]string{"role": role})
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+	id, _ := uuid.NewRandom()
+	return &User{
+		ID:       id.String(),
+		Email:    strings.ToLower(email),
+		Name:     name,
+		Password: string(hash),
+		Role:     role,
+		Status:   utility.Enums().Status.Active,
+	}, nil
+}
+
+//IsValidRole checks if the role is one of the predefined roles
+func IsValidRole(role string) bool {
+	roles := utility.Enums().Roles
+	for _, validRole := range []string{roles.Admin, roles.Member, roles.Test, roles.Scraper} {
+		if role == validRole {
+			return true
+		}
+	}
+	return false
+}
+
+//Insert inserts the user to the database, it fails if the email is already used
+func (user *User) Insert() error {
+	if _, err := GetUserByEmail(user.Email); err == nil {
+		return utility.ConflictError(utility.Enums().ErrorMessages.UserExist)
+	}
+	user.LastUpdate = time.Now()
+	return database.Client.InsertOne(userTable, user)
+}
+
+//GetUserByEmail returns user by email
+func GetUserByEmail(email string) (*User, error) {
+	var user User
+	filters := map[string]interface{}{"email": strings.ToLower(email)}
+	err := database.Client.GetOne(userTable, &user, filters)
+	return &user, err
+}
+
+//GetUsers returns users by page
+func GetUsers(page int) ([]User, error) {
+	var users []User
+	err := database.Client.GetAll(userTable, &users, nil, nil, page)
+	return users, err
+}
+
+//AuthenticateUser returns the active user if the email and password match
+func AuthenticateUser(email, password string) (*User, error) {
+	user, err := GetUserByEmail(email)
+	if err != nil || user.Status != utility.Enums().Status.Active {
+		return nil, utility.UnauthorizedError()
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)) != nil {
+		return nil, utility.UnauthorizedError()
+	}
+	return user, nil
+}
+
+//AuthenticateUserID returns the active user of a token, so a deleted, disabled or demoted user loses access before the token expires
+func AuthenticateUserID(id string) (*User, error) {
+	var user User
+	filters := map[string]interface{}{"_id": id, "status": utility.Enums().Status.Active}
+	if err := database.Client.GetOne(userTable, &user, filters); err != nil {
+		return nil, utility.UnauthorizedError()
+	}
+	return &user, nil
+}
+
+//EnsureAdminUser creates the admin user if the email is not registered yet
+func EnsureAdminUser(email, password string) error {
+	if _, err := GetUserByEmail(email); err == nil {
+		return nil
+	}
+	user, err := NewUser(email, "Admin", password, utility.Enums().Roles.Admin)
+	if err != nil {
+		return err
+	}
+	return user.Insert()
+}
@@ -0,0 +1,30 @@
+package adminpanel
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+//static is the single page admin panel, it only talks to the /api/v1 endpoints
+//
+//go:embed static
+var static embed.FS
+
+//Path is where the admin panel is served
+const Path = "/admin"
+
+//Register serves the admin panel, it should only be registered on distributor nodes
+func Register(router *gin.Engine) {
+	files, err := fs.Sub(static, "static")
+	if err != nil {
+		//the directory is embedded at build time so this will never happen
+		panic(err)
+	}
+	router.StaticFS(Path, http.FS(files))
+	router.GET("/", func(c *gin.Context) {
+		c.Redirect(http.StatusFound, Path+"/")
+	})
+}
@@ -0,0 +1,646 @@
+// Grater admin panel, it is a plain script so it can be embedded without a build step
+(function () {
+  "use strict";
+
+  var API = "/api/v1";
+  var STATUSES = ["Active", "Running", "Completed", "Cancelled", "Failed"];
+  var app = document.getElementById("app");
+
+  // h creates an element, text children are added as text nodes so api data is never parsed as html
+  function h(tag, attrs) {
+    var el = document.createElement(tag);
+    Object.keys(attrs || {}).forEach(function (key) {
+      var value = attrs[key];
+      if (key.indexOf("on") === 0) {
+        el.addEventListener(key.substring(2), value);
+      } else if (value !== undefined && value !== null && value !== false) {
+        el.setAttribute(key, value === true ? "" : value);
+      }
+    });
+    for (var i = 2; i < arguments.length; i++) {
+      append(el, arguments[i]);
+    }
+    return el;
+  }
+
+  function append(el, child) {
+    if (child === undefined || child === null || child === false) {
+      return;
+    }
+    if (Array.isArray(child)) {
+      child.forEach(function (c) { append(el, c); });
+      return;
+    }
+    el.appendChild(child instanceof Node ? child : document.createTextNode(String(child)));
+  }
+
+  function render() {
+    app.textContent = "";
+    for (var i = 0; i < arguments.length; i++) {
+      append(app, arguments[i]);
+    }
+  }
+
+  function formatTime(value) {
+    if (!value || value.indexOf("0001-") === 0) {
+      return "";
+    }
+    return new Date(value).toLocaleString();
+  }
+
+  function field(label, input) {
+    return h("label", null, label, input);
+  }
+
+  function authHeaders() {
+    var headers = { "Content-Type": "application/json" };
+    if (localStorage.getItem("grater.token")) {
+      headers.Authorization = "Bearer " + localStorage.getItem("grater.token");
+    }
+    if (localStorage.getItem("grater.apikey")) {
+      headers["X-API-Key"] = localStorage.getItem("grater.apikey");
+    }
+    return headers;
+  }
+
+  // api calls the grater api with the stored credentials and rejects with the error of the envelope
+  function api(method, path, body, query) {
+    var headers = authHeaders();
+    var url = API + path;
+    if (query) {
+      var params = new URLSearchParams();
+      Object.keys(query).forEach(function (key) {
+        if (query[key] !== "" && query[key] !== undefined && query[key] !== null) {
+          params.set(key, query[key]);
+        }
+      });
+      if (params.toString()) {
+        url += "?" + params.toString();
+      }
+    }
+    return fetch(url, { method: method, headers: headers, body: body === undefined ? undefined : JSON.stringify(body) })
+      .then(function (res) {
+        return res.text().then(function (text) {
+          var data = text ? JSON.parse(text) : null;
+          if (!res.ok) {
+            var error = (data && data.error) || { code: "internal_error", message: "Request failed with status " + res.status };
+            if (res.status === 401 && path !== "/auth/login") {
+              showLogin();
+            }
+            throw error;
+          }
+          return data;
+        });
+      });
+  }
+
+  function showError(error) {
+    var message = error && error.message ? error.message : String(error);
+    if (error && error.details) {
+      message += " " + JSON.stringify(error.details);
+    }
+    var box = h("div", { "class": "error" }, message);
+    app.insertBefore(box, app.firstChild);
+  }
+
+  // ----- login -----
+
+  function showLogin() {
+    var email = h("input", { type: "email", required: true });
+    var password = h("input", { type: "password", required: true });
+    var apiKey = h("input", { type: "password" });
+    render(
+      h("h2", null, "Log in"),
+      h("form", {
+        onsubmit: function (e) {
+          e.preventDefault();
+          localStorage.removeItem("grater.token");
+          localStorage.removeItem("grater.apikey");
+          api("POST", "/auth/login", { email: email.value, password: password.value })
+            .then(function (data) {
+              localStorage.setItem("grater.token", data.token);
+              route();
+            })
+            .catch(showError);
+        }
+      }, field("Email", email), field("Password", password), h("button", { type: "submit" }, "Log in")),
+      h("form", {
+        onsubmit: function (e) {
+          e.preventDefault();
+          localStorage.removeItem("grater.token");
+          localStorage.setItem("grater.apikey", apiKey.value);
+          route();
+        }
+      }, field("Or use an admin api key", apiKey), h("button", { type: "submit" }, "Use api key"))
+    );
+  }
+
+  document.getElementById("logout").addEventListener("click", function () {
+    localStorage.removeItem("grater.token");
+    localStorage.removeItem("grater.apikey");
+    showLogin();
+  });
+
+  // ----- rules -----
+
+  function getAllRules() {
+    // page 0 returns every rule
+    return api("GET", "/dist/rules", undefined, { page: 0 }).then(function (rules) { return rules || []; });
+  }
+
+  function rulesView() {
+    getAllRules().then(function (rules) {
+      render(
+        h("h2", null, "Rules"),
+        h("div", { "class": "toolbar" }, h("button", { onclick: function () { location.hash = "#/rules/new"; } }, "New rule")),
+        h("table", null,
+          h("tr", null, h("th", null, "Name"), h("th", null, "Status"), h("th", null, "Target"), h("th", null, "Pages"), h("th", null, "Frequency"), h("th", null, "Last update"), h("th")),
+          rules.map(function (rule) {
+            return h("tr", null,
+              h("td", null, rule.name),
+              h("td", { "class": "status-" + rule.status },
+                rule.status,
+                rule.layoutDrift ? h("span", { "class": "drift", title: rule.driftReason + " (" + formatTime(rule.driftDetectedAt) + ")" }, "layout drift") : null),
+              h("td", null, rule.targetLocation),
+              h("td", { "class": "number" }, rule.totalPages || 0),
+              h("td", { "class": "number" }, rule.frequency ? rule.frequency + "s" : ""),
+              h("td", null, formatTime(rule.lastUpdate)),
+              h("td", null,
+                h("button", { onclick: function () { location.hash = "#/rules/" + encodeURIComponent(rule.id); } }, "Edit"),
+                h("button", { onclick: function () { location.hash = "#/links?ruleid=" + encodeURIComponent(rule.id); } }, "Links"),
+                rule.status === "Paused" || rule.layoutDrift ? h("button", {
+                  onclick: function () {
+                    if (confirm("Resume the rule and clear the layout drift flag?")) {
+                      api("POST", "/admin/rules/" + encodeURIComponent(rule.id) + "/resume").then(function () { route(); }).catch(showError);
+                    }
+                  }
+                }, "Resume") : null)
+            );
+          }))
+      );
+    }).catch(showError);
+  }
+
+  function ruleEditorView(id) {
+    var load = id === "new" ? Promise.resolve({ status: "Active" }) : getAllRules().then(function (rules) {
+      var rule = rules.filter(function (r) { return r.id === id; })[0];
+      if (!rule) {
+        throw { message: "Rule not found" };
+      }
+      return rule;
+    });
+    load.then(function (rule) {
+      var inputs = {
+        name: h("input", { value: rule.name || "", required: true }),
+        targetLocation: h("input", { value: rule.targetLocation || "", required: true }),
+        linkPattern: h("input", { value: rule.linkPattern || "" }),
+        totalPages: h("input", { type: "number", min: 0, value: rule.totalPages || 0 }),
+        frequency: h("input", { type: "number", min: 0, value: rule.frequency || 0 }),
+        deeplinkPatterns: h("input", { value: rule.deeplinkPatterns || "" }),
+        headers: h("textarea", null, rule.headers || ""),
+        pattern: h("textarea", { required: true }, prettyJSON(rule.pattern || "{}"))
+      };
+      var sample = h("textarea", { placeholder: "Paste the html of a page here to preview the pattern" });
+      var preview = h("pre", { "class": "muted" }, "The preview runs in the browser, validations are not evaluated.");
+      var runPreview = function () {
+        preview.className = "";
+        try {
+          var pattern = JSON.parse(inputs.pattern.value);
+          var doc = new DOMParser().parseFromString(sample.value, "text/html");
+          var result = parsePattern([doc.body], pattern);
+          preview.textContent = result.wrongPage ? "Page layout not as expected, the scraper will retry with another proxy and cookie" : JSON.stringify(result.value, null, 2);
+        } catch (e) {
+          preview.className = "error";
+          preview.textContent = e.message;
+        }
+      };
+      render(
+        h("h2", null, id === "new" ? "New rule" : "Edit " + rule.name),
+        h("form", {
+          onsubmit: function (e) {
+            e.preventDefault();
+            var pattern;
+            try {
+              pattern = JSON.stringify(JSON.parse(inputs.pattern.value));
+            } catch (err) {
+              showError({ message: "Pattern is not valid json: " + err.message });
+              return;
+            }
+            var updated = Object.assign({}, rule, {
+              name: inputs.name.value,
+              targetLocation: inputs.targetLocation.value,
+              linkPattern: inputs.linkPattern.value,
+              totalPages: parseInt(inputs.totalPages.value, 10) || 0,
+              frequency: parseInt(inputs.frequency.value, 10) || 0,
+              deeplinkPatterns: inputs.deeplinkPatterns.value,
+              headers: inputs.headers.value,
+              pattern: pattern
+            });
+            api("POST", "/dist/rules", updated).then(function () {
+              location.hash = "#/rules";
+            }).catch(showError);
+          }
+        },
+          h("div", { "class": "columns" },
+            h("div", null,
+              field("Name", inputs.name),
+              field("Target location", inputs.targetLocation),
+              field("Link pattern, {page} is replaced by the page number", inputs.linkPattern),
+              field("Total pages", inputs.totalPages),
+              field("Frequency in seconds", inputs.frequency),
+              field("Deep link patterns", inputs.deeplinkPatterns),
+              field("Headers", inputs.headers)),
+            h("div", null,
+              field("Pattern", inputs.pattern),
+              field("Sample html", sample),
+              h("button", { type: "button", onclick: runPreview }, "Preview"),
+              preview)),
+          h("button", { type: "submit" }, "Save"),
+          h("button", { type: "button", onclick: function () { location.hash = "#/rules"; } }, "Cancel"))
+      );
+    }).catch(showError);
+  }
+
+  function prettyJSON(value) {
+    try {
+      return JSON.stringify(JSON.parse(value), null, 2);
+    } catch (e) {
+      return value;
+    }
+  }
+
+  // parsePattern mirrors parsePattern in the scraper so the preview matches the scraped record
+  function parsePattern(elements, item) {
+    var result = {};
+    var wrongPage = false;
+    var invalid = false;
+    if (typeof item.pattern === "string") {
+      var matched = [];
+      elements.forEach(function (el) {
+        matched = matched.concat(Array.prototype.slice.call(el.querySelectorAll(item.pattern)));
+      });
+      if (matched.length <= 0) {
+        return { wrongPage: true, invalid: false, value: result };
+      }
+      if (item.value) {
+        var value = "";
+        if (item.value === "text") {
+          value = matched[0].textContent.trim();
+        } else if (item.value.split(":")[0] === "attr") {
+          value = (matched[0].getAttribute(item.value.split(":")[1]) || "").trim();
+        }
+        Object.keys(item.postprocess || {}).forEach(function (key) {
+          var parameters = String(item.postprocess[key]).split(",");
+          if (parameters.length < 2) {
+            return;
+          }
+          if (key.toLowerCase() === "split") {
+            var parts = value.split(parameters[0]);
+            var index = parseInt(parameters[1], 10);
+            if (!isNaN(index) && parts.length > index) {
+              value = parts[index];
+            }
+          } else if (key.toLowerCase() === "replace") {
+            value = value.split(parameters[0]).join(parameters[1]);
+          }
+        });
+        if (value) {
+          result.value = value;
+        } else {
+          invalid = true;
+        }
+      }
+      if (item.children) {
+        matched.forEach(function (el, index) {
+          if (wrongPage || invalid) {
+            return;
+          }
+          var child = parsePattern([el], item.children);
+          if (child.wrongPage) {
+            wrongPage = true;
+          } else if (!child.invalid) {
+            result[index] = child.value;
+          } else {
+            invalid = true;
+            result = {};
+          }
+        });
+      }
+    } else {
+      Object.keys(item).forEach(function (key) {
+        if (wrongPage || invalid) {
+          return;
+        }
+        var child = parsePattern(elements, item[key]);
+        if (child.wrongPage) {
+          wrongPage = true;
+        } else if (!child.invalid) {
+          result[key] = child.value;
+        } else {
+          invalid = true;
+          result = {};
+        }
+      });
+    }
+    if (Object.keys(result).length <= 0) {
+      invalid = true;
+    }
+    return { wrongPage: wrongPage, invalid: invalid, value: result };
+  }
+
+  // ----- links -----
+
+  function linksView(query) {
+    var filters = {
+      ruleid: query.get("ruleid") || "",
+      status: query.get("status") || "",
+      scraper: query.get("scraper") || "",
+      olderthan: query.get("olderthan") || "",
+      newerthan: query.get("newerthan") || ""
+    };
+    var page = parseInt(query.get("page"), 10) || 1;
+    Promise.all([getAllRules(), api("GET", "/admin/links/counts", undefined, filters), api("GET", "/admin/links", undefined, Object.assign({ page: page }, filters))])
+      .then(function (data) {
+        var rules = data[0];
+        var names = {};
+        rules.forEach(function (rule) { names[rule.id] = rule.name; });
+        var counts = {};
+        (data[1] || []).forEach(function (count) {
+          counts[count.ruleID] = counts[count.ruleID] || {};
+          counts[count.ruleID][count.status] = count.count;
+        });
+        var links = data[2] || [];
+
+        var ruleSelect = h("select", null, h("option", { value: "" }, "All rules"), rules.map(function (rule) {
+          return h("option", { value: rule.id, selected: rule.id === filters.ruleid }, rule.name);
+        }));
+        var statusSelect = h("select", null, h("option", { value: "" }, "All"), STATUSES.map(function (status) {
+          return h("option", { value: status, selected: status === filters.status }, status);
+        }));
+        var scraperInput = h("input", { value: filters.scraper });
+        var olderInput = h("input", { value: filters.olderthan, placeholder: "e.g. 2h" });
+        var newerInput = h("input", { value: filters.newerthan, placeholder: "e.g. 30m" });
+        var go = function (nextPage) {
+          var params = new URLSearchParams();
+          [["ruleid", ruleSelect.value], ["status", statusSelect.value], ["scraper", scraperInput.value], ["olderthan", olderInput.value], ["newerthan", newerInput.value], ["page", nextPage]].forEach(function (pair) {
+            if (pair[1]) {
+              params.set(pair[0], pair[1]);
+            }
+          });
+          location.hash = "#/links?" + params.toString();
+        };
+        var bulk = function (action, filter, message) {
+          if (!confirm(message)) {
+            return;
+          }
+          api("POST", "/admin/links/" + action, filter).then(function () {
+            route();
+          }).catch(showError);
+        };
+
+        render(
+          h("h2", null, "Links"),
+          h("table", null,
+            h("tr", null, h("th", null, "Rule"), STATUSES.map(function (status) { return h("th", null, status); }), h("th")),
+            Object.keys(counts).map(function (ruleID) {
+              return h("tr", null,
+                h("td", null, names[ruleID] || ruleID),
+                STATUSES.map(function (status) { return h("td", { "class": "number status-" + status }, counts[ruleID][status] || 0); }),
+                h("td", null,
+                  h("button", { onclick: function () { bulk("requeue", { ruleid: ruleID, status: "Running", olderthan: "1h" }, "Requeue links running for more than an hour?"); } }, "Requeue stale"),
+                  h("button", { onclick: function () { bulk("cancel", { ruleid: ruleID }, "Cancel every incomplete link of the rule?"); } }, "Cancel"),
+                  h("button", {
+                    onclick: function () {
+                      if (confirm("Generate the links now? Incomplete links of the rule will be cancelled.")) {
+                        api("POST", "/admin/rules/" + encodeURIComponent(ruleID) + "/generate").then(function () { route(); }).catch(showError);
+                      }
+                    }
+                  }, "Generate")));
+            })),
+          h("form", { onsubmit: function (e) { e.preventDefault(); go(1); } },
+            h("div", { "class": "toolbar" },
+              field("Rule", ruleSelect), field("Status", statusSelect), field("Scraper", scraperInput),
+              field("Older than", olderInput), field("Newer than", newerInput),
+              h("button", { type: "submit" }, "Filter"))),
+          h("table", null,
+            h("tr", null, h("th", null, "Link"), h("th", null, "Rule"), h("th", null, "Status"), h("th", null, "Scraper"), h("th", null, "Last update"), h("th")),
+            links.map(function (link) {
+              return h("tr", null,
+                h("td", null, h("a", { href: link.link, target: "_blank", rel: "noopener noreferrer" }, link.link)),
+                h("td", null, names[link.ruleID] || link.ruleID),
+                h("td", { "class": "status-" + link.status }, link.status),
+                h("td", null, link.scraper),
+                h("td", null, formatTime(link.LastUpdate)),
+                h("td", null,
+                  h("button", { onclick: function () { bulk("requeue", { linkids: [link.id] }, "Requeue the link?"); } }, "Requeue"),
+                  h("button", { onclick: function () { bulk("delete", { linkids: [link.id] }, "Delete the link?"); } }, "Delete")));
+            })),
+          pager(page, links.length, go),
+          filters.ruleid ? addLinksForm(filters.ruleid) : null
+        );
+      }).catch(showError);
+  }
+
+  function addLinksForm(ruleID) {
+    var links = h("textarea", { placeholder: "One url per line" });
+    return h("form", {
+      onsubmit: function (e) {
+        e.preventDefault();
+        var urls = links.value.split("\n").map(function (l) { return l.trim(); }).filter(Boolean);
+        api("POST", "/admin/rules/" + encodeURIComponent(ruleID) + "/links", { links: urls }).then(function () {
+          route();
+        }).catch(showError);
+      }
+    }, field("Add links to the rule", links), h("button", { type: "submit" }, "Add links"));
+  }
+
+  function pager(page, size, go) {
+    return h("div", { "class": "toolbar" },
+      h("button", { disabled: page <= 1, onclick: function () { go(page - 1); } }, "Previous"),
+      h("span", null, "Page " + page),
+      h("button", { disabled: size <= 0, onclick: function () { go(page + 1); } }, "Next"));
+  }
+
+  // ----- results -----
+
+  function resultsView(query) {
+    var tableName = query.get("tablename") || "";
+    var page = parseInt(query.get("page"), 10) || 1;
+    getAllRules().then(function (rules) {
+      var tables = {};
+      rules.forEach(function (rule) { tables[rule.targetLocation] = true; });
+      var tableSelect = h("select", null, h("option", { value: "" }, "Choose a table"), Object.keys(tables).sort().map(function (table) {
+        return h("option", { value: table, selected: table === tableName }, table);
+      }));
+      var go = function (nextPage) {
+        location.hash = "#/results?tablename=" + encodeURIComponent(tableSelect.value) + "&page=" + nextPage;
+      };
+      var header = [
+        h("h2", null, "Results"),
+        h("form", { onsubmit: function (e) { e.preventDefault(); go(1); } },
+          h("div", { "class": "toolbar" }, field("Table", tableSelect), h("button", { type: "submit" }, "Show"),
+            tableName ? h("button", { type: "button", onclick: function () { download("/admin/results/export?format=csv&tablename=" + encodeURIComponent(tableName), tableName + ".csv"); } }, "Export csv") : null))
+      ];
+      if (!tableName) {
+        render(header);
+        return;
+      }
+      api("GET", "/admin/results", undefined, { tablename: tableName, page: page }).then(function (results) {
+        results = results || [];
+        render(header,
+          h("table", null,
+            h("tr", null, h("th", null, "Last update"), h("th", null, "Content")),
+            results.map(function (result) {
+              return h("tr", null, h("td", null, formatTime(result.lastUpdate)), h("td", null, h("pre", null, prettyJSON(result.content))));
+            })),
+          pager(page, results.length, go));
+      }).catch(showError);
+    }).catch(showError);
+  }
+
+  // download saves the response as a file, a plain link can't send the credentials
+  function download(path, fileName) {
+    fetch(API + path, { headers: authHeaders() }).then(function (res) {
+      if (!res.ok) {
+        return res.json().then(function (data) { throw data.error; });
+      }
+      return res.blob();
+    }).then(function (blob) {
+      var a = h("a", { href: URL.createObjectURL(blob), download: fileName });
+      document.body.appendChild(a);
+      a.click();
+      document.body.removeChild(a);
+      URL.revokeObjectURL(a.href);
+    }).catch(showError);
+  }
+
+  // ----- page layout errors -----
+
+  function layoutErrorsView(query) {
+    var page = parseInt(query.get("page"), 10) || 1;
+    var ruleID = query.get("ruleid") || "";
+    // groups cover the last 7 days so old redesigns don't hide the current one
+    var from = new Date(Date.now() - 7 * 24 * 60 * 60 * 1000).toISOString().replace(/\.\d+Z$/, "Z");
+    Promise.all([
+      getAllRules(),
+      api("GET", "/admin/layout-errors/groups", undefined, { ruleid: ruleID, from: from }),
+      api("GET", "/admin/layout-errors", undefined, { ruleid: ruleID, page: page })
+    ]).then(function (data) {
+      var names = {};
+      data[0].forEach(function (rule) { names[rule.id] = rule.name; });
+      var groups = data[1] || [];
+      var errors = data[2] || [];
+      var frame = h("iframe", { sandbox: "", title: "Page snapshot" });
+      var show = function (hash) {
+        api("GET", "/admin/layout-errors/snapshots/" + encodeURIComponent(hash)).then(function (snapshot) {
+          frame.srcdoc = snapshot.html;
+          frame.scrollIntoView();
+        }).catch(showError);
+      };
+      var missing = function (patterns) {
+        return (patterns || []).map(function (pattern) { return pattern.path + " (" + pattern.selector + ")"; }).join(", ");
+      };
+      render(
+        h("h2", null, "Page Layout Errors"),
+        h("p", { "class": "muted" }, "Pages that did not match the pattern, they are only captured when WRITEPAGELAYOUTERROR is set."),
+        h("h3", null, "Failing selectors in the last 7 days"),
+        h("table", null,
+          h("tr", null, h("th", null, "Rule"), h("th", null, "Path"), h("th", null, "Selector"), h("th", null, "Errors"), h("th", null, "Last seen"), h("th")),
+          groups.map(function (group) {
+            return h("tr", null,
+              h("td", null, h("a", { href: "#/layout-errors?ruleid=" + encodeURIComponent(group.ruleID) }, names[group.ruleID] || group.ruleID)),
+              h("td", null, group.path),
+              h("td", null, h("code", null, group.selector)),
+              h("td", { "class": "number" }, group.count),
+              h("td", null, formatTime(group.lastSeen)),
+              h("td", null, h("button", { onclick: function () { show(group.snapshotHash); } }, "Latest page")));
+          })),
+        h("h3", null, "Latest errors"),
+        h("table", null,
+          h("tr", null, h("th", null, "Time"), h("th", null, "Rule"), h("th", null, "Link"), h("th", null, "Status"), h("th", null, "Proxy"), h("th", null, "Missing"), h("th")),
+          errors.map(function (error) {
+            return h("tr", null,
+              h("td", null, formatTime(error.lastUpdate)),
+              h("td", null, names[error.ruleID] || error.ruleID),
+              h("td", null, error.link),
+              h("td", { "class": "number" }, error.statusCode),
+              h("td", null, error.proxy),
+              h("td", null, missing(error.missing)),
+              h("td", null, h("button", { onclick: function () { show(error.snapshotHash); } }, "View")));
+          })),
+        pager(page, errors.length, function (nextPage) {
+          location.hash = "#/layout-errors?" + (ruleID ? "ruleid=" + encodeURIComponent(ruleID) + "&" : "") + "page=" + nextPage;
+        }),
+        frame
+      );
+    }).catch(showError);
+  }
+
+  // ----- scraper fleet -----
+
+  function fleetView() {
+    // page 0 returns every running link, scrapers are derived from the links they hold
+    Promise.all([getAllRules(), api("GET", "/admin/links", undefined, { status: "Running", page: 0 })])
+      .then(function (data) {
+        var names = {};
+        data[0].forEach(function (rule) { names[rule.id] = rule.name; });
+        var scrapers = {};
+        (data[1] || []).forEach(function (link) {
+          var scraper = scrapers[link.scraper] = scrapers[link.scraper] || { links: 0, rules: {}, last: "" };
+          scraper.links++;
+          scraper.rules[names[link.ruleID] || link.ruleID] = true;
+          if (link.LastUpdate > scraper.last) {
+            scraper.last = link.LastUpdate;
+          }
+        });
+        var staleBefore = new Date(Date.now() - 60 * 60 * 1000);
+        render(
+          h("h2", null, "Scrapers"),
+          h("p", { "class": "muted" }, "Scrapers holding running links. Links running for more than an hour are reset to active by the distributor."),
+          h("table", null,
+            h("tr", null, h("th", null, "Scraper"), h("th", null, "Running links"), h("th", null, "Rules"), h("th", null, "Last allocation"), h("th", null, "State")),
+            Object.keys(scrapers).sort().map(function (name) {
+              var scraper = scrapers[name];
+              var stale = new Date(scraper.last) < staleBefore;
+              return h("tr", null,
+                h("td", null, h("a", { href: "#/links?status=Running&scraper=" + encodeURIComponent(name) }, name)),
+                h("td", { "class": "number" }, scraper.links),
+                h("td", null, Object.keys(scraper.rules).join(", ")),
+                h("td", null, formatTime(scraper.last)),
+                h("td", { "class": stale ? "status-Cancelled" : "status-Completed" }, stale ? "Stale" : "Working"));
+            })),
+          Object.keys(scrapers).length <= 0 ? h("p", null, "No scraper is running.") : null
+        );
+      }).catch(showError);
+  }
+
+  // ----- router -----
+
+  function route() {
+    var hash = location.hash.replace(/^#/, "") || "/rules";
+    var path = hash.split("?")[0];
+    var query = new URLSearchParams(hash.split("?")[1] || "");
+    Array.prototype.forEach.call(document.querySelectorAll("#nav a"), function (a) {
+      a.className = path.indexOf(a.getAttribute("href").substring(1)) === 0 ? "active" : "";
+    });
+    render(h("p", { "class": "muted" }, "Loading..."));
+    if (path === "/rules") {
+      rulesView();
+    } else if (path.indexOf("/rules/") === 0) {
+      ruleEditorView(decodeURIComponent(path.substring("/rules/".length)));
+    } else if (path === "/links") {
+      linksView(query);
+    } else if (path === "/results") {
+      resultsView(query);
+    } else if (path === "/layout-errors") {
+      layoutErrorsView(query);
+    } else if (path === "/fleet") {
+      fleetView();
+    } else {
+      location.hash = "#/rules";
+    }
+  }
+
+  window.addEventListener("hashchange", route);
+  route();
+})();
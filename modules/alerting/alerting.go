@@ -0,0 +1,177 @@
+package alerting
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/sporule/grater/models"
+	"github.com/sporule/grater/modules/config"
+	"github.com/sporule/grater/modules/database"
+	"github.com/sporule/grater/modules/logger"
+	"github.com/sporule/grater/modules/notifier"
+	"github.com/sporule/grater/modules/utility"
+)
+
+//Rule evaluates one kind of alert, the keys of the returned alerts start with the prefix so the alerts that stopped firing can be resolved
+type Rule struct {
+	Prefix   string
+	Evaluate func(config config.Alerts, counts []models.LinkCount) ([]notifier.Notification, error)
+}
+
+//Rules are the alert rules that are checked after the database is reachable
+var Rules = []Rule{
+	{Prefix: "no-results:", Evaluate: noResults},
+	{Prefix: "dead-links:", Evaluate: deadLinks},
+	{Prefix: "no-scrapers", Evaluate: noScrapers},
+}
+
+const databaseKey = "database"
+
+//Check evaluates every rule, the other rules are skipped while the database is down and a rule that fails keeps its alerts until the next check
+func Check(config config.Alerts, notify *notifier.Notifier) {
+	log := logger.New("component", "alerting")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := database.Client.Ping(ctx); err != nil {
+		log.Error("Database is not reachable", "error", err)
+		apply(notify, databaseKey, []notifier.Notification{{
+			Key:      databaseKey,
+			Title:    "Database is not reachable",
+			Message:  err.Error(),
+			Severity: notifier.Critical,
+		}})
+		return
+	}
+	apply(notify, databaseKey, nil)
+	counts, err := models.CountLinks(nil)
+	if err != nil {
+		log.Error("Unable to count the links", "error", err)
+		return
+	}
+	for _, rule := range Rules {
+		firing, err := rule.Evaluate(config, counts)
+		if err != nil {
+			log.Error("Unable to evaluate the alert rule", "rule", rule.Prefix, "error", err)
+			continue
+		}
+		apply(notify, rule.Prefix, firing)
+	}
+}
+
+//apply fires the alerts and resolves the firing alerts with the prefix that are not in the list
+func apply(notify *notifier.Notifier, prefix string, firing []notifier.Notification) {
+	keys := make(map[string]bool, len(firing))
+	for _, notification := range firing {
+		keys[notification.Key] = true
+		notify.Fire(notification)
+	}
+	for _, key := range notify.Active(prefix) {
+		if !keys[key] {
+			notify.Resolve(key, "The alert is no longer firing")
+		}
+	}
+}
+
+//pendingLinks returns the number of active and running links by rule
+func pendingLinks(counts []models.LinkCount) map[string]int64 {
+	pending := make(map[string]int64)
+	for _, count := range counts {
+		if count.Status == utility.Enums().Status.Active || count.Status == utility.Enums().Status.Running {
+			pending[count.RuleID] += count.Count
+		}
+	}
+	return pending
+}
+
+//noResults alerts on active rules that have pending links or a frequency but saved no results, rules updated within the window are skipped
+func noResults(config config.Alerts, counts []models.LinkCount) ([]notifier.Notification, error) {
+	if config.NoResultsHours <= 0 {
+		return nil, nil
+	}
+	window := time.Duration(config.NoResultsHours) * time.Hour
+	since := time.Now().Add(-window)
+	rules, err := models.GetRules(map[string]interface{}{"status": utility.Enums().Status.Active}, 0)
+	if err != nil {
+		return nil, err
+	}
+	pending := pendingLinks(counts)
+	var firing []notifier.Notification
+	for _, rule := range rules {
+		if rule.LastUpdate.After(since) || (rule.Frequency <= 0 && pending[rule.ID] <= 0) {
+			continue
+		}
+		count, err := models.CountResults(rule.TargetLocation, rule.ID, since)
+		if err != nil {
+			return nil, err
+		}
+		if count > 0 {
+			continue
+		}
+		firing = append(firing, notifier.Notification{
+			Key:      "no-results:" + rule.ID,
+			Title:    "No results for " + rule.Name,
+			Message:  "The rule saved no results in the last " + window.String(),
+			Severity: notifier.Warning,
+			Fields:   map[string]string{"rule": rule.Name, "ruleID": rule.ID, "pendingLinks": strconv.FormatInt(pending[rule.ID], 10)},
+		})
+	}
+	return firing, nil
+}
+
+//deadLinks alerts on rules with too many failed links
+func deadLinks(config config.Alerts, counts []models.LinkCount) ([]notifier.Notification, error) {
+	if config.DeadLinks <= 0 {
+		return nil, nil
+	}
+	var firing []notifier.Notification
+	for _, count := range counts {
+		if count.Status != utility.Enums().Status.Failed || count.Count < config.DeadLinks {
+			continue
+		}
+		firing = append(firing, notifier.Notification{
+			Key:      "dead-links:" + count.RuleID,
+			Title:    "Too many failed links",
+			Message:  strconv.FormatInt(count.Count, 10) + " links failed after LINK_MAX_ATTEMPTS, requeue them with the links admin api once the cause is fixed",
+			Severity: notifier.Warning,
+			Fields:   map[string]string{"ruleID": count.RuleID, "failedLinks": strconv.FormatInt(count.Count, 10)},
+		})
+	}
+	return firing, nil
+}
+
+//noScrapers alerts when there are pending links but no scraper updated a link within the window, links of paused rules are not given to scrapers so they are ignored
+func noScrapers(config config.Alerts, counts []models.LinkCount) ([]notifier.Notification, error) {
+	if config.ScraperMinutes <= 0 {
+		return nil, nil
+	}
+	pausedRules, err := models.GetRules(map[string]interface{}{"status": utility.Enums().Status.Paused}, 0)
+	if err != nil {
+		return nil, err
+	}
+	paused := make(map[string]bool, len(pausedRules))
+	for _, rule := range pausedRules {
+		paused[rule.ID] = true
+	}
+	var pending int64
+	for ruleID, count := range pendingLinks(counts) {
+		if !paused[ruleID] {
+			pending += count
+		}
+	}
+	if pending <= 0 {
+		return nil, nil
+	}
+	window := time.Duration(config.ScraperMinutes) * time.Minute
+	scrapers, err := models.ActiveScrapers(time.Now().Add(-window))
+	if err != nil || len(scrapers) > 0 {
+		return nil, err
+	}
+	return []notifier.Notification{{
+		Key:      "no-scrapers",
+		Title:    "No healthy scrapers",
+		Message:  "No scraper allocated or completed a link in the last " + window.String(),
+		Severity: notifier.Critical,
+		Fields:   map[string]string{"pendingLinks": strconv.FormatInt(pending, 10)},
+	}}, nil
+}
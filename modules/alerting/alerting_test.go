@@ -0,0 +1,53 @@
+package alerting
+
+import (
+	"testing"
+
+	"github.com/sporule/grater/models"
+	"github.com/sporule/grater/modules/config"
+	"github.com/sporule/grater/modules/notifier"
+	"github.com/stretchr/testify/assert"
+)
+
+type recorder struct {
+	sent []notifier.Notification
+}
+
+func (recorder *recorder) Name() string {
+	return "recorder"
+}
+
+func (recorder *recorder) Send(notification notifier.Notification) error {
+	recorder.sent = append(recorder.sent, notification)
+	return nil
+}
+
+func TestApply(t *testing.T) {
+	channel := &recorder{}
+	notify := notifier.New(channel)
+	notify.RepeatInterval = 0
+
+	apply(notify, "dead-links:", []notifier.Notification{{Key: "dead-links:1"}, {Key: "dead-links:2"}})
+	apply(notify, "dead-links:", []notifier.Notification{{Key: "dead-links:2"}})
+	assert.Equal(t, 3, len(channel.sent), "the alert that stopped firing should be resolved")
+	assert.Equal(t, "dead-links:1", channel.sent[2].Key)
+	assert.True(t, channel.sent[2].Resolved)
+
+	apply(notify, "database", nil)
+	assert.Equal(t, []string{"dead-links:2"}, notify.Active(""), "alerts of other rules should not be resolved")
+}
+
+func TestDeadLinks(t *testing.T) {
+	counts := []models.LinkCount{
+		{RuleID: "1", Status: "Failed", Count: 150},
+		{RuleID: "2", Status: "Failed", Count: 10},
+		{RuleID: "3", Status: "Active", Count: 500},
+	}
+	firing, err := deadLinks(config.Alerts{DeadLinks: 100}, counts)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(firing))
+	assert.Equal(t, "dead-links:1", firing[0].Key)
+
+	firing, _ = deadLinks(config.Alerts{}, counts)
+	assert.Empty(t, firing, "zero threshold turns the rule off")
+}
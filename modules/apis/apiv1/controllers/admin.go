@@ -1,19 +1,49 @@
 package controllers
 
 import (
+	"mime"
 	"net/http"
+	"net/url"
 	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/sporule/grater/models"
+	"github.com/sporule/grater/modules/auth"
+	"github.com/sporule/grater/modules/config"
+	"github.com/sporule/grater/modules/exporter"
+	"github.com/sporule/grater/modules/logger"
 	"github.com/sporule/grater/modules/utility"
 )
 
 //InitiateAdminRouters set up all distributor endpoints
-func InitiateAdminRouters(router *gin.RouterGroup) {
+func InitiateAdminRouters(router *gin.RouterGroup, driftConfig config.Drift) {
 
-	r := router.Group("/admin")
+	r := router.Group("/admin", auth.Authorize(utility.Enums().Roles.Admin))
 	r.GET("/results", getResultsController)
+	r.GET("/results/export", exportResultsController)
+	r.GET("/users", getUsersController)
+	r.POST("/users", addUserController)
+	r.GET("/keys", getAPIKeysController)
+	r.POST("/keys", issueAPIKeyController)
+	r.DELETE("/keys/:id", revokeAPIKeyController)
+	r.GET("/links", getLinksController)
+	r.GET("/links/counts", countLinksController)
+	r.POST("/links/requeue", bulkLinksController(models.RequeueLinks))
+	r.POST("/links/cancel", bulkLinksController(models.CancelLinks))
+	r.POST("/links/delete", bulkLinksController(models.DeleteLinks))
+	r.POST("/rules/:id/links", addRuleLinksController)
+	r.POST("/rules/:id/generate", generateRuleLinksController)
+	r.POST("/rules/:id/resume", resumeRuleController)
+	r.GET("/rules/:id/versions", getRuleVersionsController)
+	r.GET("/rules/:id/versions/diff", diffRuleVersionsController)
+	r.POST("/rules/:id/rollback", rollbackRuleController)
+	r.GET("/rules/:id/schema", getRuleSchemaController)
+	r.POST("/rules/:id/reextract", reextractRuleController)
+	r.GET("/reextract/:id", getReextractJobController)
+	r.GET("/drift", getDriftController(driftConfig))
+	r.GET("/layout-errors", getLayoutErrorsController)
+	r.GET("/layout-errors/groups", groupLayoutErrorsController)
+	r.GET("/layout-errors/snapshots/:hash", getLayoutSnapshotController)
 }
 
 func getResultsController(c *gin.Context) {
@@ -23,7 +53,17 @@ func getResultsController(c *gin.Context) {
 		tableName := cCp.DefaultQuery("tablename", "")
 		pageStr := cCp.DefaultQuery("page", "1")
 		if tableName == "" {
-			res <- utility.Result{Code: http.StatusOK, Obj: &utility.Error{Error: utility.Enums().ErrorMessages.LackOfInfo}}
+			res <- utility.ErrorResult(utility.ValidationError(utility.Enums().ErrorMessages.LackOfInfo, map[string]string{"tablename": "required"}))
+			return
+		}
+		//only the results can be read, not the other tables such as the users and the api keys
+		isTarget, err := models.IsTargetLocation(tableName)
+		if err != nil {
+			res <- errorResult(cCp, err)
+			return
+		}
+		if !isTarget {
+			res <- utility.ErrorResult(utility.ValidationError("Invalid table name", map[string]string{"tablename": "must be the targetLocation of a rule"}))
 			return
 		}
 		page, err := strconv.Atoi(pageStr)
@@ -36,7 +76,7 @@ func getResultsController(c *gin.Context) {
 		sortbyMap["lastupdate"] = -1
 		results, err := models.GetResults(tableName, nil, sortbyMap, page)
 		if err != nil {
-			res <- utility.Result{Code: http.StatusOK, Obj: &utility.Error{Error: utility.Enums().ErrorMessages.SystemError}}
+			res <- errorResult(cCp, err)
 			return
 		}
 		res <- utility.Result{Code: http.StatusOK, Obj: results}
@@ -45,3 +85,70 @@ func getResultsController(c *gin.Context) {
 	result := <-res
 	c.JSON(result.Expand())
 }
+
+//exportResultsController streams the results as csv, jsonl or parquet, it writes to the response directly so it can't run in a goroutine
+func exportResultsController(c *gin.Context) {
+	opts, err := exporter.ParseOptions(
+		c.DefaultQuery("tablename", ""),
+		c.DefaultQuery("ruleid", ""),
+		c.DefaultQuery("from", ""),
+		c.DefaultQuery("to", ""),
+		c.DefaultQuery("fields", ""),
+		c.DefaultQuery("format", exporter.CSV),
+	)
+	var export *exporter.Exporter
+	if err == nil {
+		//the columns and the cursor are ready before the status is sent so their errors are returned as json
+		export, err = exporter.Prepare(opts)
+	}
+	if err != nil {
+		c.JSON(errorResult(c, err).Expand())
+		return
+	}
+	opts = export.Options
+	if !utility.IsNil(opts.RuleID) {
+		//the records of the rule are described by the schema of the rule
+		c.Header("Link", "</api/v1/admin/rules/"+url.PathEscape(opts.RuleID)+"/schema>; rel=\"describedby\"")
+	}
+	c.Header("Content-Type", exporter.ContentType(opts.Format))
+	//the target location can have spaces, quotes or semicolons so the filename is quoted or encoded
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": opts.TableName + "." + opts.Format}))
+	c.Status(http.StatusOK)
+	if err := export.Stream(c.Writer); err != nil {
+		logger.FromContext(c).Error("Export failed, the connection is closed so the download fails", "table", opts.TableName, "rule", opts.RuleID, "error", err)
+		abortConnection(c)
+	}
+}
+
+//abortConnection closes the connection of a response whose status is already sent,
+//the body ends without its last chunk so the client sees a failed download instead of a short file
+func abortConnection(c *gin.Context) {
+	c.Abort()
+	conn, _, err := c.Writer.Hijack()
+	if err != nil {
+		return
+	}
+	conn.Close()
+}
+
+//getRuleSchemaController returns the JSON Schema of the records the rule scrapes
+func getRuleSchemaController(c *gin.Context) {
+	cCp := c.Copy()
+	res := make(chan utility.Result)
+	go func() {
+		rule, err := models.GetRule(cCp.Param("id"))
+		if err != nil {
+			res <- errorResult(cCp, err)
+			return
+		}
+		schema, err := rule.OutputSchema()
+		if err != nil {
+			res <- errorResult(cCp, err)
+			return
+		}
+		res <- utility.Result{Code: http.StatusOK, Obj: schema}
+		return
+	}()
+	result := <-res
+	c.JSON(result.Expand())
+}
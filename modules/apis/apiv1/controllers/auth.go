@@ -0,0 +1,163 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sporule/grater/models"
+	"github.com/sporule/grater/modules/auth"
+	"github.com/sporule/grater/modules/utility"
+)
+
+//InitiateAuthRouters set up the login endpoint
+func InitiateAuthRouters(router *gin.RouterGroup) {
+
+	r := router.Group("/auth")
+	r.POST("/login", loginController)
+}
+
+func loginController(c *gin.Context) {
+	cCp := c.Copy()
+	res := make(chan utility.Result)
+	go func() {
+		var credentials struct {
+			Email    string `json:"email"`
+			Password string `json:"password"`
+		}
+		err := cCp.ShouldBindJSON(&credentials)
+		if err != nil || utility.IsNil(credentials.Email, credentials.Password) {
+			res <- utility.ErrorResult(utility.ValidationError(utility.Enums().ErrorMessages.LackOfInfo, map[string]string{"email": "required", "password": "required"}))
+			return
+		}
+		user, err := models.AuthenticateUser(credentials.Email, credentials.Password)
+		if err != nil {
+			res <- errorResult(cCp, err)
+			return
+		}
+		token, err := auth.IssueToken(user.ID, user.Role)
+		if err != nil {
+			res <- errorResult(cCp, err)
+			return
+		}
+		res <- utility.Result{Code: http.StatusOK, Obj: gin.H{"token": token, "role": user.Role}}
+		return
+	}()
+	result := <-res
+	c.JSON(result.Expand())
+}
+
+func getUsersController(c *gin.Context) {
+	cCp := c.Copy()
+	res := make(chan utility.Result)
+	go func() {
+		page, err := strconv.Atoi(cCp.DefaultQuery("page", "1"))
+		if err != nil {
+			page = 1
+		}
+		users, err := models.GetUsers(page)
+		if err != nil {
+			res <- errorResult(cCp, err)
+			return
+		}
+		res <- utility.Result{Code: http.StatusOK, Obj: users}
+		return
+	}()
+	result := <-res
+	c.JSON(result.Expand())
+}
+
+func addUserController(c *gin.Context) {
+	cCp := c.Copy()
+	res := make(chan utility.Result)
+	go func() {
+		var userMap map[string]string
+		err := cCp.ShouldBindJSON(&userMap)
+		if err != nil {
+			res <- utility.ErrorResult(utility.ValidationError(utility.Enums().ErrorMessages.LackOfRegInfo, err.Error()))
+			return
+		}
+		user, err := models.NewUser(userMap["email"], userMap["name"], userMap["password"], userMap["role"])
+		if err != nil {
+			res <- errorResult(cCp, err)
+			return
+		}
+		err = user.Insert()
+		if err != nil {
+			res <- errorResult(cCp, err)
+			return
+		}
+		res <- utility.Result{Code: http.StatusCreated, Obj: user}
+		return
+	}()
+	result := <-res
+	c.JSON(result.Expand())
+}
+
+func getAPIKeysController(c *gin.Context) {
+	cCp := c.Copy()
+	res := make(chan utility.Result)
+	go func() {
+		page, err := strconv.Atoi(cCp.DefaultQuery("page", "1"))
+		if err != nil {
+			page = 1
+		}
+		apiKeys, err := models.GetAPIKeys(page)
+		if err != nil {
+			res <- errorResult(cCp, err)
+			return
+		}
+		res <- utility.Result{Code: http.StatusOK, Obj: apiKeys}
+		return
+	}()
+	result := <-res
+	c.JSON(result.Expand())
+}
+
+//issueAPIKeyController creates a new api key, the plain key is only returned in this response
+func issueAPIKeyController(c *gin.Context) {
+	cCp := c.Copy()
+	res := make(chan utility.Result)
+	go func() {
+		var keyMap map[string]string
+		err := cCp.ShouldBindJSON(&keyMap)
+		if err != nil {
+			res <- utility.ErrorResult(utility.ValidationError(utility.Enums().ErrorMessages.LackOfInfo, err.Error()))
+			return
+		}
+		role, ok := keyMap["role"]
+		if !ok {
+			role = utility.Enums().Roles.Scraper
+		}
+		apiKey, key, err := models.NewAPIKey(keyMap["name"], role)
+		if err != nil {
+			res <- errorResult(cCp, err)
+			return
+		}
+		err = apiKey.Insert()
+		if err != nil {
+			res <- errorResult(cCp, err)
+			return
+		}
+		res <- utility.Result{Code: http.StatusCreated, Obj: gin.H{"id": apiKey.ID, "name": apiKey.Name, "role": apiKey.Role, "key": key}}
+		return
+	}()
+	result := <-res
+	c.JSON(result.Expand())
+}
+
+func revokeAPIKeyController(c *gin.Context) {
+	cCp := c.Copy()
+	res := make(chan utility.Result)
+	go func() {
+		err := models.RevokeAPIKey(cCp.Param("id"))
+		if err != nil {
+			res <- errorResult(cCp, err)
+			return
+		}
+		res <- utility.Result{Code: http.StatusOK, Obj: nil}
+		return
+	}()
+	result := <-res
+	c.JSON(result.Expand())
+}
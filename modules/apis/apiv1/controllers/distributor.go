@@ -3,20 +3,24 @@ package controllers
 import (
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/sporule/grater/models"
+	"github.com/sporule/grater/modules/auth"
+	"github.com/sporule/grater/modules/metrics"
 	"github.com/sporule/grater/modules/utility"
 )
 
 //InitiateDistRouters set up all distributor endpoints
 func InitiateDistRouters(router *gin.RouterGroup) {
 
+	roles := utility.Enums().Roles
 	r := router.Group("/dist")
-	r.GET("/rules", getRulesController)
-	r.POST("/rules", AddRuleController)
-	r.GET("/links", allocateLinksController)
-	r.POST("/links", completeLinksController)
+	r.GET("/rules", auth.Authorize(roles.Admin, roles.Member, roles.Scraper), getRulesController)
+	r.POST("/rules", auth.Authorize(roles.Admin, roles.Member), AddRuleController)
+	r.GET("/links", auth.Authorize(roles.Admin, roles.Scraper), allocateLinksController)
+	r.POST("/links", auth.Authorize(roles.Admin, roles.Scraper), completeLinksController)
 }
 
 func getRulesController(c *gin.Context) {
@@ -29,8 +33,8 @@ func getRulesController(c *gin.Context) {
 		var err error
 		if isScraper == "0" {
 			pageStr := cCp.DefaultQuery("page", "1")
-			page, err := strconv.Atoi(pageStr)
-			if err != nil {
+			page, pageErr := strconv.Atoi(pageStr)
+			if pageErr != nil {
 				page = 1
 			}
 			rules, err = models.GetRules(nil, page)
@@ -38,7 +42,7 @@ func getRulesController(c *gin.Context) {
 			rules, err = models.GetRulesWithActiveLinks()
 		}
 		if err != nil {
-			res <- utility.Result{Code: http.StatusOK, Obj: &utility.Error{Error: utility.Enums().ErrorMessages.SystemError}}
+			res <- errorResult(cCp, err)
 			return
 		}
 		res <- utility.Result{Code: http.StatusOK, Obj: rules}
@@ -55,12 +59,14 @@ func allocateLinksController(c *gin.Context) {
 		ruleID := cCp.DefaultQuery("ruleid", "")
 		scraper := cCp.DefaultQuery("scraper", "")
 		if utility.IsNil(scraper, ruleID) {
-			res <- utility.Result{Code: http.StatusOK, Obj: &utility.Error{Error: utility.Enums().ErrorMessages.LackOfInfo}}
+			res <- utility.ErrorResult(utility.ValidationError(utility.Enums().ErrorMessages.LackOfInfo, map[string]string{"ruleid": ruleID, "scraper": scraper}))
 			return
 		}
+		start := time.Now()
 		links, err := models.AllocateLinks(ruleID, scraper)
+		metrics.LinkAllocationDuration.Observe(time.Since(start).Seconds())
 		if err != nil {
-			res <- utility.Result{Code: http.StatusOK, Obj: &utility.Error{Error: err.Error()}}
+			res <- errorResult(cCp, err)
 			return
 		}
 		res <- utility.Result{Code: http.StatusOK, Obj: links}
@@ -77,17 +83,17 @@ func completeLinksController(c *gin.Context) {
 		var linksMap map[string][]string
 		err := cCp.ShouldBindJSON(&linksMap)
 		if err != nil {
-			res <- utility.Result{Code: http.StatusOK, Obj: &utility.Error{Error: utility.Enums().ErrorMessages.LackOfInfo}}
+			res <- utility.ErrorResult(utility.ValidationError(utility.Enums().ErrorMessages.LackOfInfo, err.Error()))
 			return
 		}
 		linkIDs, ok := linksMap["linkids"]
 		if !ok {
-			res <- utility.Result{Code: http.StatusOK, Obj: &utility.Error{Error: utility.Enums().ErrorMessages.LackOfInfo}}
+			res <- utility.ErrorResult(utility.ValidationError(utility.Enums().ErrorMessages.LackOfInfo, map[string]string{"linkids": "required"}))
 			return
 		}
 		err = models.UpdateLinksStatusToComplete(linkIDs)
 		if err != nil {
-			res <- utility.Result{Code: http.StatusOK, Obj: &utility.Error{Error: utility.Enums().ErrorMessages.SystemError}}
+			res <- errorResult(cCp, err)
 			return
 		}
 		res <- utility.Result{Code: http.StatusOK, Obj: nil}
@@ -105,12 +111,17 @@ func AddRuleController(c *gin.Context) {
 		var rule models.Rule
 		err := cCp.ShouldBindJSON(&rule)
 		if err != nil {
-			res <- utility.Result{Code: http.StatusOK, Obj: &utility.Error{Error: utility.Enums().ErrorMessages.LackOfInfo}}
+			res <- utility.ErrorResult(utility.ValidationError(utility.Enums().ErrorMessages.LackOfInfo, err.Error()))
+			return
+		}
+		//the rule is validated before it is saved so a broken pattern doesn't reach the scrapers
+		if err := rule.Validate(); err != nil {
+			res <- errorResult(cCp, err)
 			return
 		}
 		err = rule.Upsert()
 		if err != nil {
-			res <- utility.Result{Code: http.StatusOK, Obj: &utility.Error{Error: utility.Enums().ErrorMessages.SystemError}}
+			res <- errorResult(cCp, err)
 			return
 		}
 		res <- utility.Result{Code: http.StatusOK, Obj: nil}
@@ -0,0 +1,51 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sporule/grater/models"
+	"github.com/sporule/grater/modules/config"
+	"github.com/sporule/grater/modules/drift"
+	"github.com/sporule/grater/modules/utility"
+)
+
+//getDriftController returns the layout drift report of every rule with the config
+func getDriftController(driftConfig config.Drift) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cCp := c.Copy()
+		res := make(chan utility.Result)
+		go func() {
+			reports, err := drift.Reports(driftConfig)
+			if err != nil {
+				res <- errorResult(cCp, err)
+				return
+			}
+			res <- utility.Result{Code: http.StatusOK, Obj: reports}
+			return
+		}()
+		result := <-res
+		c.JSON(result.Expand())
+	}
+}
+
+//resumeRuleController sets a paused rule back to active and clears the layout drift flag
+func resumeRuleController(c *gin.Context) {
+	cCp := c.Copy()
+	res := make(chan utility.Result)
+	go func() {
+		rule, err := models.GetRule(cCp.Param("id"))
+		if err != nil {
+			res <- errorResult(cCp, err)
+			return
+		}
+		if err := rule.Resume(); err != nil {
+			res <- errorResult(cCp, err)
+			return
+		}
+		res <- utility.Result{Code: http.StatusOK, Obj: rule}
+		return
+	}()
+	result := <-res
+	c.JSON(result.Expand())
+}
@@ -0,0 +1,16 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/sporule/grater/modules/logger"
+	"github.com/sporule/grater/modules/utility"
+)
+
+//errorResult converts the error to the result, internal errors are logged because they are hidden from the response
+func errorResult(c *gin.Context, err error) utility.Result {
+	result := utility.ErrorResult(err)
+	if result.Code >= 500 {
+		logger.FromContext(c).Error("Request failed", "error", err)
+	}
+	return result
+}
@@ -0,0 +1,92 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sporule/grater/models"
+	"github.com/sporule/grater/modules/utility"
+)
+
+//timeRangeFromQuery reads from and to in RFC3339 format from the query string, the missing side is zero
+func timeRangeFromQuery(c *gin.Context) (from, to time.Time, err error) {
+	for key, value := range map[string]*time.Time{"from": &from, "to": &to} {
+		if raw := c.DefaultQuery(key, ""); !utility.IsNil(raw) {
+			if *value, err = time.Parse(time.RFC3339, raw); err != nil {
+				return from, to, utility.ValidationError("Invalid "+key+" time, it should be in RFC3339 format", map[string]string{key: raw})
+			}
+		}
+	}
+	return from, to, nil
+}
+
+func getLayoutErrorsController(c *gin.Context) {
+	cCp := c.Copy()
+	res := make(chan utility.Result)
+	go func() {
+		from, to, err := timeRangeFromQuery(cCp)
+		if err != nil {
+			res <- errorResult(cCp, err)
+			return
+		}
+		page, err := strconv.Atoi(cCp.DefaultQuery("page", "1"))
+		if err != nil {
+			//default page is 1
+			page = 1
+		}
+		layoutErrors, err := models.GetLayoutErrors(cCp.DefaultQuery("ruleid", ""), from, to, page)
+		if err != nil {
+			res <- errorResult(cCp, err)
+			return
+		}
+		res <- utility.Result{Code: http.StatusOK, Obj: layoutErrors}
+		return
+	}()
+	result := <-res
+	c.JSON(result.Expand())
+}
+
+func groupLayoutErrorsController(c *gin.Context) {
+	cCp := c.Copy()
+	res := make(chan utility.Result)
+	go func() {
+		from, to, err := timeRangeFromQuery(cCp)
+		if err != nil {
+			res <- errorResult(cCp, err)
+			return
+		}
+		groups, err := models.GroupLayoutErrors(cCp.DefaultQuery("ruleid", ""), from, to)
+		if err != nil {
+			res <- errorResult(cCp, err)
+			return
+		}
+		res <- utility.Result{Code: http.StatusOK, Obj: groups}
+		return
+	}()
+	result := <-res
+	c.JSON(result.Expand())
+}
+
+//getLayoutSnapshotController returns the html in json so it is never rendered on the origin of the api
+func getLayoutSnapshotController(c *gin.Context) {
+	cCp := c.Copy()
+	res := make(chan utility.Result)
+	go func() {
+		snapshot, err := models.GetLayoutSnapshot(cCp.Param("hash"))
+		if err != nil {
+			res <- errorResult(cCp, err)
+			return
+		}
+		html, err := snapshot.HTML()
+		if err != nil {
+			res <- errorResult(cCp, err)
+			return
+		}
+		res <- utility.Result{Code: http.StatusOK, Obj: gin.H{"id": snapshot.ID, "size": snapshot.Size, "html": html}}
+		return
+	}()
+	result := <-res
+	c.JSON(result.Expand())
+}
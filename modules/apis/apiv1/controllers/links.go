@@ -0,0 +1,133 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sporule/grater/models"
+	"github.com/sporule/grater/modules/utility"
+)
+
+//linkFilterFromQuery reads the link filter from the query string
+func linkFilterFromQuery(c *gin.Context) models.LinkFilter {
+	return models.LinkFilter{
+		RuleID:    c.DefaultQuery("ruleid", ""),
+		Status:    c.DefaultQuery("status", ""),
+		Scraper:   c.DefaultQuery("scraper", ""),
+		OlderThan: c.DefaultQuery("olderthan", ""),
+		NewerThan: c.DefaultQuery("newerthan", ""),
+	}
+}
+
+func getLinksController(c *gin.Context) {
+	cCp := c.Copy()
+	res := make(chan utility.Result)
+	go func() {
+		page, err := strconv.Atoi(cCp.DefaultQuery("page", "1"))
+		if err != nil {
+			//default page is 1
+			page = 1
+		}
+		links, err := models.FindLinks(linkFilterFromQuery(cCp), page)
+		if err != nil {
+			res <- errorResult(cCp, err)
+			return
+		}
+		res <- utility.Result{Code: http.StatusOK, Obj: links}
+		return
+	}()
+	result := <-res
+	c.JSON(result.Expand())
+}
+
+func countLinksController(c *gin.Context) {
+	cCp := c.Copy()
+	res := make(chan utility.Result)
+	go func() {
+		filters, err := linkFilterFromQuery(cCp).Filters()
+		if err != nil {
+			res <- errorResult(cCp, err)
+			return
+		}
+		counts, err := models.CountLinks(filters)
+		if err != nil {
+			res <- errorResult(cCp, err)
+			return
+		}
+		res <- utility.Result{Code: http.StatusOK, Obj: counts}
+		return
+	}()
+	result := <-res
+	c.JSON(result.Expand())
+}
+
+//bulkLinksController returns the controller that applies the bulk action to the links selected by the filter in the body
+func bulkLinksController(action func(models.LinkFilter) error) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cCp := c.Copy()
+		res := make(chan utility.Result)
+		go func() {
+			var filter models.LinkFilter
+			if err := cCp.ShouldBindJSON(&filter); err != nil {
+				res <- utility.ErrorResult(utility.ValidationError(utility.Enums().ErrorMessages.LackOfInfo, err.Error()))
+				return
+			}
+			if err := action(filter); err != nil {
+				res <- errorResult(cCp, err)
+				return
+			}
+			res <- utility.Result{Code: http.StatusOK, Obj: nil}
+			return
+		}()
+		result := <-res
+		c.JSON(result.Expand())
+	}
+}
+
+func addRuleLinksController(c *gin.Context) {
+	cCp := c.Copy()
+	res := make(chan utility.Result)
+	go func() {
+		var body struct {
+			Links []string `json:"links"`
+		}
+		if err := cCp.ShouldBindJSON(&body); err != nil {
+			res <- utility.ErrorResult(utility.ValidationError(utility.Enums().ErrorMessages.LackOfInfo, err.Error()))
+			return
+		}
+		rule, err := models.GetRule(cCp.Param("id"))
+		if err != nil {
+			res <- errorResult(cCp, err)
+			return
+		}
+		if err := rule.AddLinks(body.Links); err != nil {
+			res <- errorResult(cCp, err)
+			return
+		}
+		res <- utility.Result{Code: http.StatusCreated, Obj: nil}
+		return
+	}()
+	result := <-res
+	c.JSON(result.Expand())
+}
+
+func generateRuleLinksController(c *gin.Context) {
+	cCp := c.Copy()
+	res := make(chan utility.Result)
+	go func() {
+		rule, err := models.GetRule(cCp.Param("id"))
+		if err != nil {
+			res <- errorResult(cCp, err)
+			return
+		}
+		if _, err := rule.GenerateAndInsertLinks(); err != nil {
+			res <- errorResult(cCp, err)
+			return
+		}
+		res <- utility.Result{Code: http.StatusOK, Obj: nil}
+		return
+	}()
+	result := <-res
+	c.JSON(result.Expand())
+}
@@ -0,0 +1,87 @@
+package controllers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sporule/grater/models"
+	"github.com/sporule/grater/modules/scraper"
+	"github.com/sporule/grater/modules/utility"
+)
+
+//reextractRuleController starts a job that runs the current pattern of the rule over the stored pages in the time range,
+//the job runs in the background and its progress is returned by getReextractJobController
+func reextractRuleController(c *gin.Context) {
+	cCp := c.Copy()
+	res := make(chan utility.Result)
+	go func() {
+		var body struct {
+			From string `json:"from"`
+			To   string `json:"to"`
+		}
+		if cCp.Request.ContentLength != 0 {
+			if err := cCp.ShouldBindJSON(&body); err != nil {
+				res <- utility.ErrorResult(utility.ValidationError(utility.Enums().ErrorMessages.LackOfInfo, err.Error()))
+				return
+			}
+		}
+		from, err := parseTime("from", body.From)
+		if err != nil {
+			res <- errorResult(cCp, err)
+			return
+		}
+		to, err := parseTime("to", body.To)
+		if err != nil {
+			res <- errorResult(cCp, err)
+			return
+		}
+		rule, err := models.GetRule(cCp.Param("id"))
+		if err != nil {
+			res <- errorResult(cCp, err)
+			return
+		}
+		job, err := models.NewReextractJob(rule, from, to)
+		if err != nil {
+			res <- errorResult(cCp, err)
+			return
+		}
+		if err := job.Upsert(); err != nil {
+			res <- errorResult(cCp, err)
+			return
+		}
+		go scraper.Reextract(job)
+		res <- utility.Result{Code: http.StatusAccepted, Obj: job}
+		return
+	}()
+	result := <-res
+	c.JSON(result.Expand())
+}
+
+//parseTime reads the time in RFC3339 format, it is zero if the value is empty
+func parseTime(key, value string) (time.Time, error) {
+	if utility.IsNil(value) {
+		return time.Time{}, nil
+	}
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return parsed, utility.ValidationError("Invalid "+key+" time, it should be in RFC3339 format", map[string]string{key: value})
+	}
+	return parsed, nil
+}
+
+func getReextractJobController(c *gin.Context) {
+	cCp := c.Copy()
+	res := make(chan utility.Result)
+	go func() {
+		job, err := models.GetReextractJob(cCp.Param("id"))
+		if err != nil {
+			res <- errorResult(cCp, err)
+			return
+		}
+		res <- utility.Result{Code: http.StatusOK, Obj: job}
+		return
+	}()
+	result := <-res
+	c.JSON(result.Expand())
+}
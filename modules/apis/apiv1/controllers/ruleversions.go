@@ -0,0 +1,99 @@
+package controllers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sporule/grater/models"
+	"github.com/sporule/grater/modules/utility"
+)
+
+//getRuleVersionsController returns the version history of the rule, the latest version comes first
+func getRuleVersionsController(c *gin.Context) {
+	cCp := c.Copy()
+	res := make(chan utility.Result)
+	go func() {
+		page, err := strconv.Atoi(cCp.DefaultQuery("page", "1"))
+		if err != nil {
+			//default page is 1
+			page = 1
+		}
+		rule, err := models.GetRule(cCp.Param("id"))
+		if err != nil {
+			res <- errorResult(cCp, err)
+			return
+		}
+		versions, err := models.GetRuleVersions(rule.ID, page)
+		if err != nil {
+			res <- errorResult(cCp, err)
+			return
+		}
+		res <- utility.Result{Code: http.StatusOK, Obj: versions}
+		return
+	}()
+	result := <-res
+	c.JSON(result.Expand())
+}
+
+//diffRuleVersionsController returns the fields that changed between the from and to versions
+func diffRuleVersionsController(c *gin.Context) {
+	cCp := c.Copy()
+	res := make(chan utility.Result)
+	go func() {
+		invalid := make(map[string]string)
+		from, err := strconv.Atoi(cCp.DefaultQuery("from", ""))
+		if err != nil || from <= 0 {
+			invalid["from"] = "it should be a version number"
+		}
+		to, err := strconv.Atoi(cCp.DefaultQuery("to", ""))
+		if err != nil || to <= 0 {
+			invalid["to"] = "it should be a version number"
+		}
+		if len(invalid) > 0 {
+			res <- utility.ErrorResult(utility.ValidationError(utility.Enums().ErrorMessages.LackOfInfo, invalid))
+			return
+		}
+		changes, err := models.DiffRuleVersions(cCp.Param("id"), from, to)
+		if err != nil {
+			res <- errorResult(cCp, err)
+			return
+		}
+		res <- utility.Result{Code: http.StatusOK, Obj: changes}
+		return
+	}()
+	result := <-res
+	c.JSON(result.Expand())
+}
+
+//rollbackRuleController restores the definition of an earlier version as the new latest version
+func rollbackRuleController(c *gin.Context) {
+	cCp := c.Copy()
+	res := make(chan utility.Result)
+	go func() {
+		var body struct {
+			Version int `json:"version"`
+		}
+		if err := cCp.ShouldBindJSON(&body); err != nil {
+			res <- utility.ErrorResult(utility.ValidationError(utility.Enums().ErrorMessages.LackOfInfo, err.Error()))
+			return
+		}
+		if body.Version <= 0 {
+			res <- utility.ErrorResult(utility.ValidationError(utility.Enums().ErrorMessages.LackOfInfo, map[string]string{"version": "required"}))
+			return
+		}
+		rule, err := models.GetRule(cCp.Param("id"))
+		if err != nil {
+			res <- errorResult(cCp, err)
+			return
+		}
+		if err := rule.Rollback(body.Version); err != nil {
+			res <- errorResult(cCp, err)
+			return
+		}
+		res <- utility.Result{Code: http.StatusOK, Obj: rule}
+		return
+	}()
+	result := <-res
+	c.JSON(result.Expand())
+}
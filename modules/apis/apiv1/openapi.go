@@ -0,0 +1,811 @@
+package apiv1
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+//openAPIController serves the OpenAPI 3 specification of all api routes
+func openAPIController(c *gin.Context) {
+	c.Data(http.StatusOK, "application/json; charset=utf-8", []byte(openAPISpec))
+}
+
+//openAPISpec documents every route registered by RegisterAPIRoutes, routes_test.go checks they are in sync
+const openAPISpec = `{
+  "openapi": "3.0.3",
+  "info": {
+    "title": "Grater",
+    "description": "Distributed scraping api. Scraper nodes use the distributor endpoints to obtain rules and links, admins use the admin endpoints to manage results, users and api keys.",
+    "version": "1.0.0"
+  },
+  "servers": [{ "url": "/" }],
+  "security": [{ "bearerAuth": [] }, { "apiKeyAuth": [] }],
+  "paths": {
+    "/metrics": {
+      "get": {
+        "summary": "Get the metrics in prometheus format",
+        "operationId": "getMetrics",
+        "security": [],
+        "responses": {
+          "200": { "description": "The metrics", "content": { "text/plain": { "schema": { "type": "string" } } } }
+        }
+      }
+    },
+    "/healthz": {
+      "get": {
+        "summary": "Liveness probe, it checks the scheduler and the scraper loop are making progress",
+        "operationId": "healthz",
+        "security": [],
+        "responses": {
+          "200": { "description": "All checks passed", "content": { "application/json": { "schema": { "$ref": "#/components/schemas/HealthReport" } } } },
+          "503": { "description": "At least one check failed", "content": { "application/json": { "schema": { "$ref": "#/components/schemas/HealthReport" } } } }
+        }
+      }
+    },
+    "/readyz": {
+      "get": {
+        "summary": "Readiness probe, it runs the liveness checks and checks the database and the proxy pool",
+        "operationId": "readyz",
+        "security": [],
+        "responses": {
+          "200": { "description": "All checks passed", "content": { "application/json": { "schema": { "$ref": "#/components/schemas/HealthReport" } } } },
+          "503": { "description": "At least one check failed", "content": { "application/json": { "schema": { "$ref": "#/components/schemas/HealthReport" } } } }
+        }
+      }
+    },
+    "/api/v1/heartbeat": {
+      "get": {
+        "summary": "Check if the node is running",
+        "operationId": "heartbeat",
+        "security": [],
+        "responses": {
+          "200": { "description": "The node is running", "content": { "application/json": { "schema": { "type": "object", "properties": { "data": { "type": "string" } } } } } }
+        }
+      }
+    },
+    "/api/v1/openapi.json": {
+      "get": {
+        "summary": "Get this specification",
+        "operationId": "getOpenAPI",
+        "security": [],
+        "responses": {
+          "200": { "description": "The OpenAPI specification", "content": { "application/json": { "schema": { "type": "object" } } } }
+        }
+      }
+    },
+    "/api/v1/auth/login": {
+      "post": {
+        "summary": "Log in with email and password",
+        "operationId": "login",
+        "security": [],
+        "requestBody": { "required": true, "content": { "application/json": { "schema": { "$ref": "#/components/schemas/Credentials" } } } },
+        "responses": {
+          "200": { "description": "The token to use as bearer token", "content": { "application/json": { "schema": { "$ref": "#/components/schemas/Token" } } } },
+          "400": { "$ref": "#/components/responses/Error" },
+          "401": { "$ref": "#/components/responses/Error" }
+        }
+      }
+    },
+    "/api/v1/dist/rules": {
+      "get": {
+        "summary": "List rules, or get a random rule with active links for scrapers",
+        "operationId": "getRules",
+        "parameters": [
+          { "name": "isscraper", "in": "query", "description": "Set to 1 to get a random rule with active links", "schema": { "type": "string", "enum": ["0", "1"], "default": "0" } },
+          { "$ref": "#/components/parameters/Page" }
+        ],
+        "responses": {
+          "200": { "description": "Rules", "content": { "application/json": { "schema": { "type": "array", "items": { "$ref": "#/components/schemas/Rule" } } } } },
+          "401": { "$ref": "#/components/responses/Error" },
+          "403": { "$ref": "#/components/responses/Error" },
+          "404": { "$ref": "#/components/responses/Error" }
+        }
+      },
+      "post": {
+        "summary": "Create or update a rule",
+        "operationId": "addRule",
+        "requestBody": { "required": true, "content": { "application/json": { "schema": { "$ref": "#/components/schemas/Rule" } } } },
+        "responses": {
+          "200": { "description": "The rule is saved" },
+          "400": { "$ref": "#/components/responses/Error" },
+          "401": { "$ref": "#/components/responses/Error" },
+          "403": { "$ref": "#/components/responses/Error" }
+        }
+      }
+    },
+    "/api/v1/dist/links": {
+      "get": {
+        "summary": "Allocate active links of the rule to the scraper",
+        "operationId": "allocateLinks",
+        "parameters": [
+          { "name": "ruleid", "in": "query", "required": true, "schema": { "type": "string" } },
+          { "name": "scraper", "in": "query", "required": true, "schema": { "type": "string" } }
+        ],
+        "responses": {
+          "200": { "description": "The allocated links", "content": { "application/json": { "schema": { "type": "array", "items": { "$ref": "#/components/schemas/Link" } } } } },
+          "400": { "$ref": "#/components/responses/Error" },
+          "401": { "$ref": "#/components/responses/Error" },
+          "403": { "$ref": "#/components/responses/Error" },
+          "404": { "$ref": "#/components/responses/Error" }
+        }
+      },
+      "post": {
+        "summary": "Mark links as completed",
+        "operationId": "completeLinks",
+        "requestBody": { "required": true, "content": { "application/json": { "schema": { "$ref": "#/components/schemas/LinkIDs" } } } },
+        "responses": {
+          "200": { "description": "The links are completed" },
+          "400": { "$ref": "#/components/responses/Error" },
+          "401": { "$ref": "#/components/responses/Error" },
+          "403": { "$ref": "#/components/responses/Error" }
+        }
+      }
+    },
+    "/api/v1/admin/results": {
+      "get": {
+        "summary": "List results of a table, latest first",
+        "operationId": "getResults",
+        "parameters": [
+          { "name": "tablename", "in": "query", "required": true, "schema": { "type": "string" } },
+          { "$ref": "#/components/parameters/Page" }
+        ],
+        "responses": {
+          "200": { "description": "Results", "content": { "application/json": { "schema": { "type": "array", "items": { "$ref": "#/components/schemas/Result" } } } } },
+          "400": { "$ref": "#/components/responses/Error" },
+          "401": { "$ref": "#/components/responses/Error" },
+          "403": { "$ref": "#/components/responses/Error" }
+        }
+      }
+    },
+    "/api/v1/admin/results/export": {
+      "get": {
+        "summary": "Stream results as csv, jsonl or parquet",
+        "operationId": "exportResults",
+        "parameters": [
+          { "name": "tablename", "in": "query", "schema": { "type": "string" } },
+          { "name": "ruleid", "in": "query", "schema": { "type": "string" } },
+          { "name": "from", "in": "query", "schema": { "type": "string", "format": "date-time" } },
+          { "name": "to", "in": "query", "schema": { "type": "string", "format": "date-time" } },
+          { "name": "fields", "in": "query", "description": "Comma separated flattened columns", "schema": { "type": "string" } },
+          { "name": "format", "in": "query", "schema": { "type": "string", "enum": ["csv", "jsonl", "parquet"], "default": "csv" } }
+        ],
+        "responses": {
+          "200": {
+            "description": "The exported results, the Link header points to the schema of the rule if ruleid is set",
+            "content": {
+              "text/csv": { "schema": { "type": "string" } },
+              "application/x-ndjson": { "schema": { "type": "string" } },
+              "application/octet-stream": { "schema": { "type": "string", "format": "binary" } }
+            }
+          },
+          "400": { "$ref": "#/components/responses/Error" },
+          "401": { "$ref": "#/components/responses/Error" },
+          "403": { "$ref": "#/components/responses/Error" },
+          "404": { "$ref": "#/components/responses/Error" }
+        }
+      }
+    },
+    "/api/v1/admin/users": {
+      "get": {
+        "summary": "List users",
+        "operationId": "getUsers",
+        "parameters": [{ "$ref": "#/components/parameters/Page" }],
+        "responses": {
+          "200": { "description": "Users", "content": { "application/json": { "schema": { "type": "array", "items": { "$ref": "#/components/schemas/User" } } } } },
+          "401": { "$ref": "#/components/responses/Error" },
+          "403": { "$ref": "#/components/responses/Error" }
+        }
+      },
+      "post": {
+        "summary": "Create a user",
+        "operationId": "addUser",
+        "requestBody": { "required": true, "content": { "application/json": { "schema": { "$ref": "#/components/schemas/NewUser" } } } },
+        "responses": {
+          "201": { "description": "The created user", "content": { "application/json": { "schema": { "$ref": "#/components/schemas/User" } } } },
+          "400": { "$ref": "#/components/responses/Error" },
+          "401": { "$ref": "#/components/responses/Error" },
+          "403": { "$ref": "#/components/responses/Error" },
+          "409": { "$ref": "#/components/responses/Error" }
+        }
+      }
+    },
+    "/api/v1/admin/keys": {
+      "get": {
+        "summary": "List api keys",
+        "operationId": "getAPIKeys",
+        "parameters": [{ "$ref": "#/components/parameters/Page" }],
+        "responses": {
+          "200": { "description": "Api keys", "content": { "application/json": { "schema": { "type": "array", "items": { "$ref": "#/components/schemas/APIKey" } } } } },
+          "401": { "$ref": "#/components/responses/Error" },
+          "403": { "$ref": "#/components/responses/Error" }
+        }
+      },
+      "post": {
+        "summary": "Issue an api key, the plain key is only returned once",
+        "operationId": "issueAPIKey",
+        "requestBody": { "required": true, "content": { "application/json": { "schema": { "$ref": "#/components/schemas/NewAPIKey" } } } },
+        "responses": {
+          "201": { "description": "The issued key", "content": { "application/json": { "schema": { "$ref": "#/components/schemas/IssuedAPIKey" } } } },
+          "400": { "$ref": "#/components/responses/Error" },
+          "401": { "$ref": "#/components/responses/Error" },
+          "403": { "$ref": "#/components/responses/Error" }
+        }
+      }
+    },
+    "/api/v1/admin/links": {
+      "get": {
+        "summary": "List links by filters, latest updated first",
+        "operationId": "getLinks",
+        "parameters": [
+          { "$ref": "#/components/parameters/LinkRuleID" },
+          { "$ref": "#/components/parameters/LinkStatus" },
+          { "$ref": "#/components/parameters/LinkScraper" },
+          { "$ref": "#/components/parameters/LinkOlderThan" },
+          { "$ref": "#/components/parameters/LinkNewerThan" },
+          { "$ref": "#/components/parameters/Page" }
+        ],
+        "responses": {
+          "200": { "description": "Links", "content": { "application/json": { "schema": { "type": "array", "items": { "$ref": "#/components/schemas/Link" } } } } },
+          "400": { "$ref": "#/components/responses/Error" },
+          "401": { "$ref": "#/components/responses/Error" },
+          "403": { "$ref": "#/components/responses/Error" }
+        }
+      }
+    },
+    "/api/v1/admin/links/counts": {
+      "get": {
+        "summary": "Count links by rule and status",
+        "operationId": "countLinks",
+        "parameters": [
+          { "$ref": "#/components/parameters/LinkRuleID" },
+          { "$ref": "#/components/parameters/LinkStatus" },
+          { "$ref": "#/components/parameters/LinkScraper" },
+          { "$ref": "#/components/parameters/LinkOlderThan" },
+          { "$ref": "#/components/parameters/LinkNewerThan" }
+        ],
+        "responses": {
+          "200": { "description": "Link counts", "content": { "application/json": { "schema": { "type": "array", "items": { "$ref": "#/components/schemas/LinkCount" } } } } },
+          "400": { "$ref": "#/components/responses/Error" },
+          "401": { "$ref": "#/components/responses/Error" },
+          "403": { "$ref": "#/components/responses/Error" }
+        }
+      }
+    },
+    "/api/v1/admin/links/requeue": {
+      "post": {
+        "summary": "Set the links back to active so they will be allocated again",
+        "operationId": "requeueLinks",
+        "requestBody": { "required": true, "content": { "application/json": { "schema": { "$ref": "#/components/schemas/LinkFilter" } } } },
+        "responses": {
+          "200": { "description": "The links are requeued" },
+          "400": { "$ref": "#/components/responses/Error" },
+          "401": { "$ref": "#/components/responses/Error" },
+          "403": { "$ref": "#/components/responses/Error" }
+        }
+      }
+    },
+    "/api/v1/admin/links/cancel": {
+      "post": {
+        "summary": "Cancel the links, completed links are kept unless the status is set",
+        "operationId": "cancelLinks",
+        "requestBody": { "required": true, "content": { "application/json": { "schema": { "$ref": "#/components/schemas/LinkFilter" } } } },
+        "responses": {
+          "200": { "description": "The links are cancelled" },
+          "400": { "$ref": "#/components/responses/Error" },
+          "401": { "$ref": "#/components/responses/Error" },
+          "403": { "$ref": "#/components/responses/Error" }
+        }
+      }
+    },
+    "/api/v1/admin/links/delete": {
+      "post": {
+        "summary": "Delete the links",
+        "operationId": "deleteLinks",
+        "requestBody": { "required": true, "content": { "application/json": { "schema": { "$ref": "#/components/schemas/LinkFilter" } } } },
+        "responses": {
+          "200": { "description": "The links are deleted" },
+          "400": { "$ref": "#/components/responses/Error" },
+          "401": { "$ref": "#/components/responses/Error" },
+          "403": { "$ref": "#/components/responses/Error" }
+        }
+      }
+    },
+    "/api/v1/admin/rules/{id}/links": {
+      "post": {
+        "summary": "Add ad-hoc links to the rule",
+        "operationId": "addRuleLinks",
+        "parameters": [{ "name": "id", "in": "path", "required": true, "schema": { "type": "string" } }],
+        "requestBody": { "required": true, "content": { "application/json": { "schema": { "$ref": "#/components/schemas/NewLinks" } } } },
+        "responses": {
+          "201": { "description": "The links are added" },
+          "400": { "$ref": "#/components/responses/Error" },
+          "401": { "$ref": "#/components/responses/Error" },
+          "403": { "$ref": "#/components/responses/Error" },
+          "404": { "$ref": "#/components/responses/Error" }
+        }
+      }
+    },
+    "/api/v1/admin/rules/{id}/generate": {
+      "post": {
+        "summary": "Generate the links of the rule now, incomplete links of the rule are cancelled",
+        "operationId": "generateRuleLinks",
+        "parameters": [{ "name": "id", "in": "path", "required": true, "schema": { "type": "string" } }],
+        "responses": {
+          "200": { "description": "The links are generated" },
+          "401": { "$ref": "#/components/responses/Error" },
+          "403": { "$ref": "#/components/responses/Error" },
+          "404": { "$ref": "#/components/responses/Error" }
+        }
+      }
+    },
+    "/api/v1/admin/rules/{id}/resume": {
+      "post": {
+        "summary": "Set a paused rule back to active and clear the layout drift flag",
+        "operationId": "resumeRule",
+        "parameters": [{ "name": "id", "in": "path", "required": true, "schema": { "type": "string" } }],
+        "responses": {
+          "200": { "description": "The resumed rule", "content": { "application/json": { "schema": { "$ref": "#/components/schemas/Rule" } } } },
+          "401": { "$ref": "#/components/responses/Error" },
+          "403": { "$ref": "#/components/responses/Error" },
+          "404": { "$ref": "#/components/responses/Error" },
+          "409": { "$ref": "#/components/responses/Error" }
+        }
+      }
+    },
+    "/api/v1/admin/rules/{id}/versions": {
+      "get": {
+        "summary": "List the version history of the rule, latest first",
+        "operationId": "getRuleVersions",
+        "parameters": [
+          { "name": "id", "in": "path", "required": true, "schema": { "type": "string" } },
+          { "name": "page", "in": "query", "schema": { "type": "integer", "default": 1 } }
+        ],
+        "responses": {
+          "200": { "description": "Rule versions", "content": { "application/json": { "schema": { "type": "array", "items": { "$ref": "#/components/schemas/RuleVersion" } } } } },
+          "401": { "$ref": "#/components/responses/Error" },
+          "403": { "$ref": "#/components/responses/Error" },
+          "404": { "$ref": "#/components/responses/Error" }
+        }
+      }
+    },
+    "/api/v1/admin/rules/{id}/versions/diff": {
+      "get": {
+        "summary": "Compare two versions of the rule",
+        "operationId": "diffRuleVersions",
+        "parameters": [
+          { "name": "id", "in": "path", "required": true, "schema": { "type": "string" } },
+          { "name": "from", "in": "query", "required": true, "schema": { "type": "integer" } },
+          { "name": "to", "in": "query", "required": true, "schema": { "type": "integer" } }
+        ],
+        "responses": {
+          "200": { "description": "The fields that changed from one version to the other", "content": { "application/json": { "schema": { "type": "array", "items": { "$ref": "#/components/schemas/FieldChange" } } } } },
+          "400": { "$ref": "#/components/responses/Error" },
+          "401": { "$ref": "#/components/responses/Error" },
+          "403": { "$ref": "#/components/responses/Error" },
+          "404": { "$ref": "#/components/responses/Error" }
+        }
+      }
+    },
+    "/api/v1/admin/rules/{id}/schema": {
+      "get": {
+        "summary": "Get the JSON Schema of the records the rule scrapes, it is derived from the pattern and the data types of its items",
+        "operationId": "getRuleSchema",
+        "parameters": [{ "name": "id", "in": "path", "required": true, "schema": { "type": "string" } }],
+        "responses": {
+          "200": { "description": "The JSON Schema of the records", "content": { "application/json": { "schema": { "type": "object" } } } },
+          "400": { "$ref": "#/components/responses/Error" },
+          "401": { "$ref": "#/components/responses/Error" },
+          "403": { "$ref": "#/components/responses/Error" },
+          "404": { "$ref": "#/components/responses/Error" }
+        }
+      }
+    },
+    "/api/v1/admin/rules/{id}/reextract": {
+      "post": {
+        "summary": "Start a job that runs the current pattern of the rule over the stored pages in the time range and replaces their results, nothing is requested",
+        "operationId": "reextractRule",
+        "parameters": [{ "name": "id", "in": "path", "required": true, "schema": { "type": "string" } }],
+        "requestBody": { "required": false, "content": { "application/json": { "schema": { "type": "object", "properties": { "from": { "type": "string", "format": "date-time" }, "to": { "type": "string", "format": "date-time" } } } } } },
+        "responses": {
+          "202": { "description": "The started job", "content": { "application/json": { "schema": { "$ref": "#/components/schemas/ReextractJob" } } } },
+          "400": { "$ref": "#/components/responses/Error" },
+          "401": { "$ref": "#/components/responses/Error" },
+          "403": { "$ref": "#/components/responses/Error" },
+          "404": { "$ref": "#/components/responses/Error" }
+        }
+      }
+    },
+    "/api/v1/admin/reextract/{id}": {
+      "get": {
+        "summary": "Get the progress of a re-extraction job",
+        "operationId": "getReextractJob",
+        "parameters": [{ "name": "id", "in": "path", "required": true, "schema": { "type": "string" } }],
+        "responses": {
+          "200": { "description": "The job", "content": { "application/json": { "schema": { "$ref": "#/components/schemas/ReextractJob" } } } },
+          "401": { "$ref": "#/components/responses/Error" },
+          "403": { "$ref": "#/components/responses/Error" },
+          "404": { "$ref": "#/components/responses/Error" }
+        }
+      }
+    },
+    "/api/v1/admin/rules/{id}/rollback": {
+      "post": {
+        "summary": "Restore the definition of an earlier version as a new version of the rule",
+        "operationId": "rollbackRule",
+        "parameters": [{ "name": "id", "in": "path", "required": true, "schema": { "type": "string" } }],
+        "requestBody": { "required": true, "content": { "application/json": { "schema": { "type": "object", "required": ["version"], "properties": { "version": { "type": "integer" } } } } } },
+        "responses": {
+          "200": { "description": "The rule with the restored definition", "content": { "application/json": { "schema": { "$ref": "#/components/schemas/Rule" } } } },
+          "400": { "$ref": "#/components/responses/Error" },
+          "401": { "$ref": "#/components/responses/Error" },
+          "403": { "$ref": "#/components/responses/Error" },
+          "404": { "$ref": "#/components/responses/Error" },
+          "409": { "$ref": "#/components/responses/Error" }
+        }
+      }
+    },
+    "/api/v1/admin/drift": {
+      "get": {
+        "summary": "Compare the recent pages, records and layout errors of every rule with its baseline",
+        "operationId": "getDrift",
+        "responses": {
+          "200": { "description": "Drift reports", "content": { "application/json": { "schema": { "type": "array", "items": { "$ref": "#/components/schemas/DriftReport" } } } } },
+          "401": { "$ref": "#/components/responses/Error" },
+          "403": { "$ref": "#/components/responses/Error" }
+        }
+      }
+    },
+    "/api/v1/admin/layout-errors": {
+      "get": {
+        "summary": "List pages that didn't match the pattern, latest first",
+        "operationId": "getLayoutErrors",
+        "parameters": [
+          { "name": "ruleid", "in": "query", "schema": { "type": "string" } },
+          { "name": "from", "in": "query", "description": "RFC3339", "schema": { "type": "string", "format": "date-time" } },
+          { "name": "to", "in": "query", "description": "RFC3339", "schema": { "type": "string", "format": "date-time" } },
+          { "$ref": "#/components/parameters/Page" }
+        ],
+        "responses": {
+          "200": { "description": "Layout errors", "content": { "application/json": { "schema": { "type": "array", "items": { "$ref": "#/components/schemas/LayoutError" } } } } },
+          "400": { "$ref": "#/components/responses/Error" },
+          "401": { "$ref": "#/components/responses/Error" },
+          "403": { "$ref": "#/components/responses/Error" }
+        }
+      }
+    },
+    "/api/v1/admin/layout-errors/groups": {
+      "get": {
+        "summary": "Count layout errors by rule and failing selector, the largest groups first",
+        "operationId": "groupLayoutErrors",
+        "parameters": [
+          { "name": "ruleid", "in": "query", "schema": { "type": "string" } },
+          { "name": "from", "in": "query", "description": "RFC3339", "schema": { "type": "string", "format": "date-time" } },
+          { "name": "to", "in": "query", "description": "RFC3339", "schema": { "type": "string", "format": "date-time" } }
+        ],
+        "responses": {
+          "200": { "description": "Layout error groups", "content": { "application/json": { "schema": { "type": "array", "items": { "$ref": "#/components/schemas/LayoutErrorGroup" } } } } },
+          "400": { "$ref": "#/components/responses/Error" },
+          "401": { "$ref": "#/components/responses/Error" },
+          "403": { "$ref": "#/components/responses/Error" }
+        }
+      }
+    },
+    "/api/v1/admin/layout-errors/snapshots/{hash}": {
+      "get": {
+        "summary": "Get the html of a layout error",
+        "operationId": "getLayoutSnapshot",
+        "parameters": [{ "name": "hash", "in": "path", "required": true, "schema": { "type": "string" } }],
+        "responses": {
+          "200": { "description": "The snapshot", "content": { "application/json": { "schema": { "$ref": "#/components/schemas/LayoutSnapshot" } } } },
+          "401": { "$ref": "#/components/responses/Error" },
+          "403": { "$ref": "#/components/responses/Error" },
+          "404": { "$ref": "#/components/responses/Error" }
+        }
+      }
+    },
+    "/api/v1/admin/keys/{id}": {
+      "delete": {
+        "summary": "Revoke an api key",
+        "operationId": "revokeAPIKey",
+        "parameters": [{ "name": "id", "in": "path", "required": true, "schema": { "type": "string" } }],
+        "responses": {
+          "200": { "description": "The key is revoked" },
+          "401": { "$ref": "#/components/responses/Error" },
+          "403": { "$ref": "#/components/responses/Error" },
+          "404": { "$ref": "#/components/responses/Error" }
+        }
+      }
+    }
+  },
+  "components": {
+    "securitySchemes": {
+      "bearerAuth": { "type": "http", "scheme": "bearer", "bearerFormat": "JWT" },
+      "apiKeyAuth": { "type": "apiKey", "in": "header", "name": "X-API-Key" }
+    },
+    "parameters": {
+      "Page": { "name": "page", "in": "query", "schema": { "type": "integer", "minimum": 1, "default": 1 } },
+      "LinkRuleID": { "name": "ruleid", "in": "query", "schema": { "type": "string" } },
+      "LinkStatus": { "name": "status", "in": "query", "schema": { "type": "string", "enum": ["Active", "Running", "Completed", "Cancelled", "Failed"] } },
+      "LinkScraper": { "name": "scraper", "in": "query", "schema": { "type": "string" } },
+      "LinkOlderThan": { "name": "olderthan", "in": "query", "description": "Only links last updated before this duration ago, e.g. 2h", "schema": { "type": "string" } },
+      "LinkNewerThan": { "name": "newerthan", "in": "query", "description": "Only links last updated within this duration, e.g. 30m", "schema": { "type": "string" } }
+    },
+    "responses": {
+      "Error": { "description": "The error envelope", "content": { "application/json": { "schema": { "$ref": "#/components/schemas/Error" } } } }
+    },
+    "schemas": {
+      "HealthReport": {
+        "type": "object",
+        "properties": {
+          "status": { "type": "string", "enum": ["ok", "fail"] },
+          "checks": {
+            "type": "object",
+            "additionalProperties": {
+              "type": "object",
+              "properties": {
+                "status": { "type": "string", "enum": ["ok", "fail"] },
+                "error": { "type": "string" },
+                "durationSeconds": { "type": "number" }
+              }
+            }
+          }
+        }
+      },
+      "Error": {
+        "type": "object",
+        "required": ["error"],
+        "properties": {
+          "error": {
+            "type": "object",
+            "required": ["code", "message"],
+            "properties": {
+              "code": { "type": "string", "enum": ["validation_failed", "not_found", "conflict", "unauthorized", "forbidden", "internal_error"] },
+              "message": { "type": "string" },
+              "details": {}
+            }
+          }
+        }
+      },
+      "Credentials": {
+        "type": "object",
+        "required": ["email", "password"],
+        "properties": { "email": { "type": "string" }, "password": { "type": "string" } }
+      },
+      "Token": {
+        "type": "object",
+        "properties": { "token": { "type": "string" }, "role": { "type": "string" } }
+      },
+      "Rule": {
+        "type": "object",
+        "properties": {
+          "id": { "type": "string" },
+          "name": { "type": "string" },
+          "status": { "type": "string" },
+          "pattern": { "type": "string", "description": "The extraction pattern as json string" },
+          "priorty": { "type": "integer" },
+          "targetLocation": { "type": "string" },
+          "linkPattern": { "type": "string" },
+          "deeplinkPatterns": { "type": "string" },
+          "totalPages": { "type": "integer" },
+          "lastUpdate": { "type": "string", "format": "date-time" },
+          "headers": { "type": "string", "description": "The request headers as json string" },
+          "frequency": { "type": "integer" },
+          "responseType": { "type": "string", "enum": ["html", "json"], "description": "How the responses are parsed, html is the default" },
+          "cursor": { "type": "string", "description": "The json path of the next page in json responses" },
+          "layoutDrift": { "type": "boolean" },
+          "driftReason": { "type": "string" },
+          "driftDetectedAt": { "type": "string", "format": "date-time" },
+          "source": { "type": "string", "description": "The rule file the rule is synced from, empty if the rule is managed by the api" },
+          "version": { "type": "integer", "description": "The current version of the rule, it increases when the definition changes" }
+        }
+      },
+      "ReextractJob": {
+        "type": "object",
+        "properties": {
+          "id": { "type": "string" },
+          "ruleID": { "type": "string" },
+          "ruleVersion": { "type": "integer", "description": "The version of the rule the pages are extracted with" },
+          "from": { "type": "string", "format": "date-time" },
+          "to": { "type": "string", "format": "date-time" },
+          "status": { "type": "string", "enum": ["Running", "Completed", "Failed"] },
+          "pages": { "type": "integer" },
+          "records": { "type": "integer" },
+          "layoutErrors": { "type": "integer" },
+          "failed": { "type": "integer" },
+          "error": { "type": "string" },
+          "startTime": { "type": "string", "format": "date-time" },
+          "lastUpdate": { "type": "string", "format": "date-time" }
+        }
+      },
+      "RuleVersion": {
+        "type": "object",
+        "properties": {
+          "id": { "type": "string" },
+          "ruleID": { "type": "string" },
+          "version": { "type": "integer" },
+          "definition": { "$ref": "#/components/schemas/Rule" },
+          "rolledBackFrom": { "type": "integer", "description": "The version restored by a rollback" },
+          "lastUpdate": { "type": "string", "format": "date-time" }
+        }
+      },
+      "FieldChange": {
+        "type": "object",
+        "properties": {
+          "field": { "type": "string" },
+          "old": { "type": "string" },
+          "new": { "type": "string" }
+        }
+      },
+      "Link": {
+        "type": "object",
+        "properties": {
+          "id": { "type": "string" },
+          "link": { "type": "string" },
+          "status": { "type": "string" },
+          "scraper": { "type": "string" },
+          "ruleID": { "type": "string" },
+          "ruleVersion": { "type": "integer", "description": "The version of the rule the link is generated with" },
+          "attempts": { "type": "integer", "description": "How many times the link was reset after its scraper stopped responding" },
+          "LastUpdate": { "type": "string", "format": "date-time" }
+        }
+      },
+      "LayoutError": {
+        "type": "object",
+        "properties": {
+          "id": { "type": "string" },
+          "ruleID": { "type": "string" },
+          "link": { "type": "string" },
+          "proxy": { "type": "string", "description": "Host and port of the proxy" },
+          "statusCode": { "type": "integer" },
+          "missing": { "type": "array", "items": { "$ref": "#/components/schemas/MissingPattern" } },
+          "snapshotHash": { "type": "string" },
+          "lastUpdate": { "type": "string", "format": "date-time" }
+        }
+      },
+      "MissingPattern": {
+        "type": "object",
+        "properties": {
+          "path": { "type": "string", "description": "Keys in the pattern joined by ." },
+          "selector": { "type": "string" }
+        }
+      },
+      "LayoutErrorGroup": {
+        "type": "object",
+        "properties": {
+          "ruleID": { "type": "string" },
+          "path": { "type": "string" },
+          "selector": { "type": "string" },
+          "count": { "type": "integer" },
+          "firstSeen": { "type": "string", "format": "date-time" },
+          "lastSeen": { "type": "string", "format": "date-time" },
+          "latestLink": { "type": "string" },
+          "snapshotHash": { "type": "string" }
+        }
+      },
+      "RuleStatTotals": {
+        "type": "object",
+        "properties": {
+          "pages": { "type": "integer" },
+          "records": { "type": "integer" },
+          "layoutErrors": { "type": "integer" },
+          "validationFailures": { "type": "integer" },
+          "cacheHits": { "type": "integer", "description": "Unchanged pages answered from the cache" },
+          "cacheMisses": { "type": "integer", "description": "Cached pages that were downloaded" }
+        }
+      },
+      "DriftReport": {
+        "type": "object",
+        "properties": {
+          "ruleID": { "type": "string" },
+          "name": { "type": "string" },
+          "status": { "type": "string" },
+          "current": { "$ref": "#/components/schemas/RuleStatTotals" },
+          "baseline": { "$ref": "#/components/schemas/RuleStatTotals" },
+          "evaluated": { "type": "boolean", "description": "False if the window has fewer pages than DRIFT_MIN_PAGES" },
+          "drift": { "type": "boolean" },
+          "reason": { "type": "string" },
+          "flagged": { "type": "boolean" }
+        }
+      },
+      "LayoutSnapshot": {
+        "type": "object",
+        "properties": {
+          "id": { "type": "string", "description": "sha256 of the html" },
+          "size": { "type": "integer" },
+          "html": { "type": "string" }
+        }
+      },
+      "LinkFilter": {
+        "type": "object",
+        "description": "Either linkids or ruleid is required",
+        "properties": {
+          "linkids": { "type": "array", "items": { "type": "string" } },
+          "ruleid": { "type": "string" },
+          "status": { "type": "string", "enum": ["Active", "Running", "Completed", "Cancelled", "Failed"] },
+          "scraper": { "type": "string" },
+          "olderthan": { "type": "string" },
+          "newerthan": { "type": "string" }
+        }
+      },
+      "LinkCount": {
+        "type": "object",
+        "properties": {
+          "ruleID": { "type": "string" },
+          "status": { "type": "string" },
+          "count": { "type": "integer" }
+        }
+      },
+      "NewLinks": {
+        "type": "object",
+        "required": ["links"],
+        "properties": { "links": { "type": "array", "items": { "type": "string", "format": "uri" } } }
+      },
+      "LinkIDs": {
+        "type": "object",
+        "required": ["linkids"],
+        "properties": { "linkids": { "type": "array", "items": { "type": "string" } } }
+      },
+      "Result": {
+        "type": "object",
+        "properties": {
+          "id": { "type": "string" },
+          "ruleID": { "type": "string" },
+          "ruleVersion": { "type": "integer", "description": "The version of the rule that scraped the record" },
+          "content": { "type": "string", "description": "The scraped record as json string" },
+          "warcFile": { "type": "string", "description": "The WARC file of the archived page, empty if the archive is off" },
+          "warcRecordID": { "type": "string", "description": "The WARC-Record-ID of the archived response in the file" },
+          "pageID": { "type": "string", "description": "The stored page the record is extracted from, empty if the pages are not stored" },
+          "lastUpdate": { "type": "string", "format": "date-time" }
+        }
+      },
+      "User": {
+        "type": "object",
+        "properties": {
+          "id": { "type": "string" },
+          "email": { "type": "string" },
+          "name": { "type": "string" },
+          "role": { "$ref": "#/components/schemas/Role" },
+          "status": { "type": "string" },
+          "lastUpdate": { "type": "string", "format": "date-time" }
+        }
+      },
+      "NewUser": {
+        "type": "object",
+        "required": ["email", "name", "password", "role"],
+        "properties": {
+          "email": { "type": "string" },
+          "name": { "type": "string" },
+          "password": { "type": "string" },
+          "role": { "$ref": "#/components/schemas/Role" }
+        }
+      },
+      "APIKey": {
+        "type": "object",
+        "properties": {
+          "id": { "type": "string" },
+          "name": { "type": "string" },
+          "prefix": { "type": "string" },
+          "role": { "$ref": "#/components/schemas/Role" },
+          "status": { "type": "string" },
+          "lastUpdate": { "type": "string", "format": "date-time" }
+        }
+      },
+      "NewAPIKey": {
+        "type": "object",
+        "required": ["name"],
+        "properties": { "name": { "type": "string" }, "role": { "$ref": "#/components/schemas/Role" } }
+      },
+      "IssuedAPIKey": {
+        "type": "object",
+        "properties": {
+          "id": { "type": "string" },
+          "name": { "type": "string" },
+          "role": { "$ref": "#/components/schemas/Role" },
+          "key": { "type": "string" }
+        }
+      },
+      "Role": { "type": "string", "enum": ["Admin", "Member", "Test", "Scraper"] }
+    }
+  }
+}`
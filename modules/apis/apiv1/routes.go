@@ -1,7 +1,7 @@
 package apiv1
 
 import (
-	"log"
+	"context"
 	"net/http"
 	"time"
 
@@ -9,27 +9,48 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/go-co-op/gocron"
 	"github.com/sporule/grater/models"
+	"github.com/sporule/grater/modules/alerting"
 	"github.com/sporule/grater/modules/apis/apiv1/controllers"
+	"github.com/sporule/grater/modules/config"
+	"github.com/sporule/grater/modules/database"
+	"github.com/sporule/grater/modules/drift"
+	"github.com/sporule/grater/modules/health"
+	"github.com/sporule/grater/modules/logger"
+	"github.com/sporule/grater/modules/metrics"
+	"github.com/sporule/grater/modules/notifier"
 	"github.com/sporule/grater/modules/timerjob"
 )
 
 //RegisterAPIRoutes registers all api routers
-func RegisterAPIRoutes(router *gin.Engine, mode string) {
+func RegisterAPIRoutes(router *gin.Engine, config *config.Config) {
+	mode := config.Mode
+	router.Use(metrics.Middleware())
+	//metrics are exported on every node including scraper only nodes
+	router.GET("/metrics", metrics.Handler())
+	//probes for kubernetes, every node uses the database
+	health.Register("database", false, func(ctx context.Context) error {
+		return database.Client.Ping(ctx)
+	})
+	router.GET("/healthz", health.LivenessController)
+	router.GET("/readyz", health.ReadinessController)
 	r := router.Group("/api/v1")
 	r.GET("/heartbeat", heartbeatController)
+	r.GET("/openapi.json", openAPIController)
 	r.Use(gzip.Gzip(gzip.DefaultCompression))
 	if mode != "scraper" {
-		registerEndpoints(r)
+		metrics.RegisterDistributorMetrics()
+		registerEndpoints(r, config)
 		if mode != "api" {
-			runTimerJobs()
+			runTimerJobs(config)
 		}
 	}
 }
 
 //registerEndpoints register the core end points
-func registerEndpoints(router *gin.RouterGroup) {
+func registerEndpoints(router *gin.RouterGroup, config *config.Config) {
+	controllers.InitiateAuthRouters(router)
 	controllers.InitiateDistRouters(router)
-	controllers.InitiateAdminRouters(router)
+	controllers.InitiateAdminRouters(router, config.Drift)
 
 }
 
@@ -38,21 +59,31 @@ func heartbeatController(c *gin.Context) {
 }
 
 //runTimerJobs runs timerjobs to refresh the links
-func runTimerJobs() {
+func runTimerJobs(config *config.Config) {
+	log := logger.New("component", "timerjob")
 	scheduler := gocron.NewScheduler(time.Local)
-	rules, err := models.GetRules(nil, 0)
-	if err != nil {
-		log.Fatal("Can't get rules:", err)
+	//the rule files are synced before the rules are scheduled
+	if config.Rules.Dir != "" {
+		timerjob.SyncRules(log, config.Rules)
+		scheduler.Every(config.Rules.SyncInterval).Minutes().StartAt(time.Now().Add(time.Duration(config.Rules.SyncInterval)*time.Minute)).Do(timerjob.SyncRules, log, config.Rules)
 	}
-	for _, rule := range rules {
-		//setting timer jobs
-		if rule.Frequency > 0 {
-			//generate rules
-			scheduler.Every(uint64(rule.Frequency)).Seconds().Do(timerjob.GenerateLinks, rule)
-		}
+	schedule := timerjob.NewSchedule(scheduler, log)
+	if err := schedule.Refresh(); err != nil {
+		log.Fatal("Can't get rules", "error", err)
 	}
+	//pick up the rules that are added or changed while the distributor is running
+	scheduler.Every(1).Minutes().StartAt(time.Now().Add(time.Minute)).Do(schedule.Refresh)
+	//the scheduler beats every 30 seconds so a stuck scheduler fails the liveness check
+	scheduler.Every(30).Seconds().Do(health.Beat, "scheduler")
+	health.Register("scheduler", true, health.Heartbeat("scheduler", 2*time.Minute))
+	//compare the recent rule stats with the baseline to catch site redesigns
+	notify := notifier.FromConfig(config.Notify)
+	driftInterval := config.Drift.CheckInterval
+	scheduler.Every(driftInterval).Minutes().StartAt(time.Now().Add(time.Duration(driftInterval)*time.Minute)).Do(drift.Detect, config.Drift, notify)
+	//alert on missing results, dead links, missing scrapers and database errors
+	scheduler.Every(config.Alerts.CheckInterval).Minutes().Do(alerting.Check, config.Alerts, notify)
 	//reset dead running links
-	scheduler.Every(60).Minutes().StartAt(time.Now().Add(time.Duration(60 * time.Minute))).Do(models.ResetInactiveLinks)
+	scheduler.Every(60).Minutes().StartAt(time.Now().Add(time.Duration(60 * time.Minute))).Do(models.ResetInactiveLinks, config.Links.MaxAttempts)
 	scheduler.StartAsync()
-	log.Println("Timer Jobs registered.")
+	log.Info("Timer jobs registered", "rules", schedule.Len())
 }
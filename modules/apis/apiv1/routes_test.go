@@ -0,0 +1,46 @@
+package apiv1
+
+import (
+	"encoding/json"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sporule/grater/modules/config"
+	"github.com/stretchr/testify/assert"
+)
+
+//TestOpenAPIMatchesRoutes checks every registered route is documented and every documented route is registered
+func TestOpenAPIMatchesRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	//api mode registers all endpoints without starting the timer jobs
+	config := config.Default()
+	config.Mode = "api"
+	RegisterAPIRoutes(router, config)
+
+	pathParam := regexp.MustCompile(`:([^/]+)`)
+	var registered []string
+	for _, route := range router.Routes() {
+		registered = append(registered, route.Method+" "+pathParam.ReplaceAllString(route.Path, "{$1}"))
+	}
+
+	var spec struct {
+		OpenAPI string                                `json:"openapi"`
+		Paths   map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	assert.Nil(t, json.Unmarshal([]byte(openAPISpec), &spec), "the specification should be valid json")
+	assert.Equal(t, "3.0.3", spec.OpenAPI)
+	var documented []string
+	for path, operations := range spec.Paths {
+		for method := range operations {
+			documented = append(documented, strings.ToUpper(method)+" "+path)
+		}
+	}
+
+	sort.Strings(registered)
+	sort.Strings(documented)
+	assert.Equal(t, registered, documented, "the specification should match the registered routes")
+}
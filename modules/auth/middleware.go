@@ -0,0 +1,72 @@
+package auth
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sporule/grater/models"
+	"github.com/sporule/grater/modules/utility"
+)
+
+var enabled = true
+
+//IsEnabled returns false if the authentication is disabled in the config
+func IsEnabled() bool {
+	return enabled
+}
+
+//Authorize returns a middleware that only allows the given roles, it accepts either a bearer token or an api key
+func Authorize(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !IsEnabled() {
+			c.Set(utility.Enums().Others.Role, utility.Enums().Roles.Admin)
+			c.Next()
+			return
+		}
+		userID, role, ok := authenticate(c)
+		if !ok {
+			c.AbortWithStatusJSON(utility.ErrorResult(utility.UnauthorizedError()).Expand())
+			return
+		}
+		if !hasRole(role, roles) {
+			c.AbortWithStatusJSON(utility.ErrorResult(utility.ForbiddenError()).Expand())
+			return
+		}
+		c.Set(utility.Enums().Others.UserID, userID)
+		c.Set(utility.Enums().Others.Role, role)
+		c.Next()
+	}
+}
+
+//authenticate returns the identity from the request
+func authenticate(c *gin.Context) (userID, role string, ok bool) {
+	if key := c.GetHeader(utility.APIKeyHeader); !utility.IsNil(key) {
+		apiKey, err := models.AuthenticateAPIKey(key)
+		if err != nil {
+			return "", "", false
+		}
+		return apiKey.ID, apiKey.Role, true
+	}
+	if header := c.GetHeader("Authorization"); strings.HasPrefix(header, "Bearer ") {
+		claims, err := ParseToken(strings.TrimPrefix(header, "Bearer "))
+		if err != nil {
+			return "", "", false
+		}
+		//the role is read from the user rather than the claims so a change of the user applies to the tokens already issued
+		user, err := models.AuthenticateUserID(claims.Subject)
+		if err != nil {
+			return "", "", false
+		}
+		return user.ID, user.Role, true
+	}
+	return "", "", false
+}
+
+func hasRole(role string, roles []string) bool {
+	for _, allowed := range roles {
+		if role == allowed {
+			return true
+		}
+	}
+	return false
+}
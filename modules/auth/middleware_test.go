@@ -0,0 +1,63 @@
+package auth
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sporule/grater/models"
+	"github.com/sporule/grater/modules/database"
+	"github.com/sporule/grater/modules/utility"
+)
+
+//fakeDatabase has the active users by id
+type fakeDatabase struct {
+	database.Database
+	users map[string]models.User
+}
+
+func (db fakeDatabase) GetOne(table string, item interface{}, filtersMap map[string]interface{}) error {
+	user, ok := db.users[filtersMap["_id"].(string)]
+	if !ok || user.Status != filtersMap["status"] {
+		return utility.NotFoundError(utility.Enums().ErrorMessages.RecordNotFound)
+	}
+	*(item.(*models.User)) = user
+	return nil
+}
+
+func TestAuthenticateToken(t *testing.T) {
+	db := fakeDatabase{users: map[string]models.User{"user1": {ID: "user1", Role: "Admin", Status: "Active"}}}
+	previous := database.Client
+	database.Client = db
+	defer func() { database.Client = previous }()
+
+	secret = []byte("testing secret")
+	token, err := IssueToken("user1", "Admin")
+	assert.Nil(t, err)
+	authenticateWith := func() (string, string, bool) {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+		c.Request.Header.Set("Authorization", "Bearer "+token)
+		return authenticate(c)
+	}
+	userID, role, ok := authenticateWith()
+	assert.Equal(t, true, ok)
+	assert.Equal(t, "user1", userID)
+	assert.Equal(t, "Admin", role)
+
+	db.users["user1"] = models.User{ID: "user1", Role: "Member", Status: "Active"}
+	_, role, ok = authenticateWith()
+	assert.Equal(t, true, ok)
+	assert.Equal(t, "Member", role, "a demoted user should lose the role of the token")
+
+	db.users["user1"] = models.User{ID: "user1", Role: "Admin", Status: "Cancelled"}
+	_, _, ok = authenticateWith()
+	assert.Equal(t, false, ok, "a disabled user should be rejected")
+
+	delete(db.users, "user1")
+	_, _, ok = authenticateWith()
+	assert.Equal(t, false, ok, "a deleted user should be rejected")
+}
@@ -0,0 +1,91 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/sporule/grater/modules/config"
+	"github.com/sporule/grater/modules/utility"
+)
+
+//Claims is the payload of the token
+type Claims struct {
+	Subject   string `json:"sub"`
+	Role      string `json:"role"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+var (
+	//secret is random until Configure sets it so a token is never signed with an empty secret
+	secret = randomSecret()
+	expiry = 24 * time.Hour
+)
+
+//tokenHeader is the fixed JWT header, only HS256 is supported
+var tokenHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+//Configure sets the token secret and expiry and whether the authentication is enabled, it must be called before the api starts,
+//the config validation requires the secret when the auth is enabled so the random secret is only kept when no token is checked
+func Configure(config config.Auth) {
+	expiry = time.Duration(config.JWTExpiry) * time.Hour
+	enabled = !config.Disabled
+	if len(config.JWTSecret) <= 0 {
+		return
+	}
+	secret = []byte(config.JWTSecret)
+}
+
+func randomSecret() []byte {
+	random := make([]byte, 32)
+	rand.Read(random)
+	return random
+}
+
+func sign(content string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(content))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+//IssueToken returns a signed JWT for the user, it expires after the configured hours
+func IssueToken(subject, role string) (string, error) {
+	claims := Claims{
+		Subject:   subject,
+		Role:      role,
+		ExpiresAt: time.Now().Add(expiry).Unix(),
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	content := tokenHeader + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return content + "." + sign(content), nil
+}
+
+//ParseToken validates the token signature and expiry and returns the claims
+func ParseToken(token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || parts[0] != tokenHeader {
+		return nil, utility.UnauthorizedError()
+	}
+	if !hmac.Equal([]byte(sign(parts[0]+"."+parts[1])), []byte(parts[2])) {
+		return nil, utility.UnauthorizedError()
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, utility.UnauthorizedError()
+	}
+	var claims Claims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, utility.UnauthorizedError()
+	}
+	if time.Now().Unix() > claims.ExpiresAt {
+		return nil, utility.UnauthorizedError()
+	}
+	return &claims, nil
+}
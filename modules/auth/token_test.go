@@ -0,0 +1,47 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/sporule/grater/modules/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIssueAndParseToken(t *testing.T) {
+	secret = []byte("testing secret")
+	token, err := IssueToken("user", "Admin")
+	assert.Nil(t, err)
+	claims, err := ParseToken(token)
+	assert.Nil(t, err, "issued token should be valid")
+	assert.Equal(t, "user", claims.Subject)
+	assert.Equal(t, "Admin", claims.Role)
+
+	parts := strings.Split(token, ".")
+	_, err = ParseToken(parts[0] + "." + parts[1] + "." + "invalid")
+	assert.NotNil(t, err, "token with invalid signature should be rejected")
+
+	secret = []byte("another secret")
+	_, err = ParseToken(token)
+	assert.NotNil(t, err, "token signed by another secret should be rejected")
+}
+
+func TestHasRole(t *testing.T) {
+	assert.Equal(t, true, hasRole("Admin", []string{"Admin", "Member"}))
+	assert.Equal(t, false, hasRole("Scraper", []string{"Admin", "Member"}))
+}
+
+func TestConfigureSecret(t *testing.T) {
+	secret = randomSecret()
+	Configure(config.Auth{JWTExpiry: 24})
+	assert.Equal(t, 32, len(secret), "the random secret should be kept if the secret is not set")
+	token, err := IssueToken("user", "Admin")
+	assert.Nil(t, err)
+	Configure(config.Auth{JWTExpiry: 24})
+	_, err = ParseToken(token)
+	assert.Nil(t, err, "configuring again should not change the random secret")
+
+	Configure(config.Auth{JWTSecret: "configured secret", JWTExpiry: 24})
+	_, err = ParseToken(token)
+	assert.NotNil(t, err, "token signed by the random secret should be rejected")
+}
@@ -0,0 +1,363 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sporule/grater/models"
+	"github.com/sporule/grater/modules/utility"
+)
+
+//APIKeyHeader is the header to send the api key
+const APIKeyHeader = utility.APIKeyHeader
+
+//Client is the typed client of the grater api, see /api/v1/openapi.json for the specification
+type Client struct {
+	//BaseURL is the root of the api such as http://localhost:9999/api/v1
+	BaseURL    string
+	APIKey     string
+	Token      string
+	HTTPClient *http.Client
+}
+
+//New creates a client, a base url that ends with /dist is accepted for compatibility with DISTRIBUTOR_API
+func New(baseURL, apiKey string) *Client {
+	baseURL = strings.TrimSuffix(strings.TrimSuffix(baseURL, "/"), "/dist")
+	return &Client{
+		BaseURL:    baseURL,
+		APIKey:     apiKey,
+		HTTPClient: &http.Client{Timeout: 60 * time.Second},
+	}
+}
+
+//do sends the request and decodes the response to the item, errors are returned as *utility.AppError when the api returns the error envelope
+func (client *Client) do(method, path string, query url.Values, body interface{}, item interface{}) error {
+	res, err := client.send(method, path, query, body)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	content, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+	if item == nil || len(content) <= 0 {
+		return nil
+	}
+	return json.Unmarshal(content, item)
+}
+
+//send sends the request and returns the response if the status is 2xx
+func (client *Client) send(method, path string, query url.Values, body interface{}) (*http.Response, error) {
+	link := client.BaseURL + path
+	if len(query) > 0 {
+		link += "?" + query.Encode()
+	}
+	var reader io.Reader
+	if body != nil {
+		content, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewBuffer(content)
+	}
+	req, err := http.NewRequest(method, link, reader)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if !utility.IsNil(client.APIKey) {
+		req.Header.Set(APIKeyHeader, client.APIKey)
+	}
+	if !utility.IsNil(client.Token) {
+		req.Header.Set("Authorization", "Bearer "+client.Token)
+	}
+	res, err := client.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		defer res.Body.Close()
+		return nil, decodeError(res)
+	}
+	return res, nil
+}
+
+//decodeError reads the error envelope from the response
+func decodeError(res *http.Response) error {
+	content, _ := ioutil.ReadAll(res.Body)
+	var envelope utility.Error
+	if err := json.Unmarshal(content, &envelope); err != nil || envelope.Error == nil {
+		return errors.New("Grater api returned status " + strconv.Itoa(res.StatusCode))
+	}
+	return envelope.Error
+}
+
+//Heartbeat checks if the node is running
+func (client *Client) Heartbeat() error {
+	return client.do(http.MethodGet, "/heartbeat", nil, nil, nil)
+}
+
+//Login logs in with email and password and keeps the token for the following requests
+func (client *Client) Login(email, password string) error {
+	var token struct {
+		Token string `json:"token"`
+	}
+	err := client.do(http.MethodPost, "/auth/login", nil, map[string]string{"email": email, "password": password}, &token)
+	if err != nil {
+		return err
+	}
+	client.Token = token.Token
+	return nil
+}
+
+//GetRules returns rules by page
+func (client *Client) GetRules(page int) ([]models.Rule, error) {
+	var rules []models.Rule
+	query := url.Values{"page": {strconv.Itoa(page)}}
+	return rules, client.do(http.MethodGet, "/dist/rules", query, nil, &rules)
+}
+
+//GetScraperRule returns a random rule with active links
+func (client *Client) GetScraperRule() (*models.Rule, error) {
+	var rules []models.Rule
+	query := url.Values{"isscraper": {"1"}}
+	if err := client.do(http.MethodGet, "/dist/rules", query, nil, &rules); err != nil {
+		return nil, err
+	}
+	if len(rules) <= 0 {
+		return nil, utility.NotFoundError("Unable to find any rules")
+	}
+	return &rules[0], nil
+}
+
+//AddRule creates or updates the rule
+func (client *Client) AddRule(rule models.Rule) error {
+	return client.do(http.MethodPost, "/dist/rules", nil, rule, nil)
+}
+
+//AllocateLinks allocates active links of the rule to the scraper
+func (client *Client) AllocateLinks(ruleID, scraper string) ([]models.Link, error) {
+	var links []models.Link
+	query := url.Values{"ruleid": {ruleID}, "scraper": {scraper}}
+	return links, client.do(http.MethodGet, "/dist/links", query, nil, &links)
+}
+
+//CompleteLinks marks the links as completed
+func (client *Client) CompleteLinks(linkIDs []string) error {
+	return client.do(http.MethodPost, "/dist/links", nil, map[string][]string{"linkids": linkIDs}, nil)
+}
+
+//GetLinks returns links by filter and page, link ids in the filter are ignored
+func (client *Client) GetLinks(filter models.LinkFilter, page int) ([]models.Link, error) {
+	var links []models.Link
+	query := linkFilterQuery(filter)
+	query.Set("page", strconv.Itoa(page))
+	return links, client.do(http.MethodGet, "/admin/links", query, nil, &links)
+}
+
+//CountLinks returns the number of links by rule and status, link ids in the filter are ignored
+func (client *Client) CountLinks(filter models.LinkFilter) ([]models.LinkCount, error) {
+	var counts []models.LinkCount
+	return counts, client.do(http.MethodGet, "/admin/links/counts", linkFilterQuery(filter), nil, &counts)
+}
+
+//RequeueLinks sets the links back to active
+func (client *Client) RequeueLinks(filter models.LinkFilter) error {
+	return client.do(http.MethodPost, "/admin/links/requeue", nil, filter, nil)
+}
+
+//CancelLinks cancels the links
+func (client *Client) CancelLinks(filter models.LinkFilter) error {
+	return client.do(http.MethodPost, "/admin/links/cancel", nil, filter, nil)
+}
+
+//DeleteLinks deletes the links
+func (client *Client) DeleteLinks(filter models.LinkFilter) error {
+	return client.do(http.MethodPost, "/admin/links/delete", nil, filter, nil)
+}
+
+//AddRuleLinks adds ad-hoc links to the rule
+func (client *Client) AddRuleLinks(ruleID string, links []string) error {
+	return client.do(http.MethodPost, "/admin/rules/"+url.PathEscape(ruleID)+"/links", nil, map[string][]string{"links": links}, nil)
+}
+
+//GenerateRuleLinks generates the links of the rule now
+func (client *Client) GenerateRuleLinks(ruleID string) error {
+	return client.do(http.MethodPost, "/admin/rules/"+url.PathEscape(ruleID)+"/generate", nil, nil, nil)
+}
+
+//ResumeRule sets the paused rule back to active and clears the layout drift flag
+func (client *Client) ResumeRule(ruleID string) (*models.Rule, error) {
+	var rule models.Rule
+	return &rule, client.do(http.MethodPost, "/admin/rules/"+url.PathEscape(ruleID)+"/resume", nil, nil, &rule)
+}
+
+//GetRuleVersions returns the version history of the rule by page, the latest version comes first
+func (client *Client) GetRuleVersions(ruleID string, page int) ([]models.RuleVersion, error) {
+	var versions []models.RuleVersion
+	query := url.Values{}
+	query.Set("page", strconv.Itoa(page))
+	return versions, client.do(http.MethodGet, "/admin/rules/"+url.PathEscape(ruleID)+"/versions", query, nil, &versions)
+}
+
+//DiffRuleVersions returns the fields that changed from one version of the rule to the other
+func (client *Client) DiffRuleVersions(ruleID string, from, to int) ([]models.FieldChange, error) {
+	var changes []models.FieldChange
+	query := url.Values{}
+	query.Set("from", strconv.Itoa(from))
+	query.Set("to", strconv.Itoa(to))
+	return changes, client.do(http.MethodGet, "/admin/rules/"+url.PathEscape(ruleID)+"/versions/diff", query, nil, &changes)
+}
+
+//RollbackRule restores the definition of the version as a new version of the rule
+func (client *Client) RollbackRule(ruleID string, version int) (*models.Rule, error) {
+	var rule models.Rule
+	return &rule, client.do(http.MethodPost, "/admin/rules/"+url.PathEscape(ruleID)+"/rollback", nil, map[string]int{"version": version}, &rule)
+}
+
+//GetRuleSchema returns the JSON Schema of the records the rule scrapes
+func (client *Client) GetRuleSchema(ruleID string) (map[string]interface{}, error) {
+	var schema map[string]interface{}
+	return schema, client.do(http.MethodGet, "/admin/rules/"+url.PathEscape(ruleID)+"/schema", nil, nil, &schema)
+}
+
+//ReextractRule starts a job that extracts the stored pages of the rule in the time range again, the zero times are ignored
+func (client *Client) ReextractRule(ruleID string, from, to time.Time) (*models.ReextractJob, error) {
+	body := map[string]string{}
+	if !from.IsZero() {
+		body["from"] = from.Format(time.RFC3339)
+	}
+	if !to.IsZero() {
+		body["to"] = to.Format(time.RFC3339)
+	}
+	var job models.ReextractJob
+	return &job, client.do(http.MethodPost, "/admin/rules/"+url.PathEscape(ruleID)+"/reextract", nil, body, &job)
+}
+
+//GetReextractJob returns the progress of the re-extraction job
+func (client *Client) GetReextractJob(id string) (*models.ReextractJob, error) {
+	var job models.ReextractJob
+	return &job, client.do(http.MethodGet, "/admin/reextract/"+url.PathEscape(id), nil, nil, &job)
+}
+
+//GetDrift returns the layout drift report of every rule
+func (client *Client) GetDrift() ([]models.DriftReport, error) {
+	var reports []models.DriftReport
+	return reports, client.do(http.MethodGet, "/admin/drift", nil, nil, &reports)
+}
+
+func linkFilterQuery(filter models.LinkFilter) url.Values {
+	query := url.Values{}
+	for key, value := range map[string]string{
+		"ruleid":    filter.RuleID,
+		"status":    filter.Status,
+		"scraper":   filter.Scraper,
+		"olderthan": filter.OlderThan,
+		"newerthan": filter.NewerThan,
+	} {
+		if !utility.IsNil(value) {
+			query.Set(key, value)
+		}
+	}
+	return query
+}
+
+//GetLayoutErrors returns layout errors by rule, time range and page, the zero times are ignored
+func (client *Client) GetLayoutErrors(ruleID string, from, to time.Time, page int) ([]models.LayoutError, error) {
+	var layoutErrors []models.LayoutError
+	query := timeRangeQuery(ruleID, from, to)
+	query.Set("page", strconv.Itoa(page))
+	return layoutErrors, client.do(http.MethodGet, "/admin/layout-errors", query, nil, &layoutErrors)
+}
+
+//GroupLayoutErrors counts layout errors by rule and failing selector
+func (client *Client) GroupLayoutErrors(ruleID string, from, to time.Time) ([]models.LayoutErrorGroup, error) {
+	var groups []models.LayoutErrorGroup
+	return groups, client.do(http.MethodGet, "/admin/layout-errors/groups", timeRangeQuery(ruleID, from, to), nil, &groups)
+}
+
+//GetLayoutSnapshot returns the html of the layout error snapshot
+func (client *Client) GetLayoutSnapshot(hash string) (string, error) {
+	var snapshot struct {
+		HTML string `json:"html"`
+	}
+	err := client.do(http.MethodGet, "/admin/layout-errors/snapshots/"+url.PathEscape(hash), nil, nil, &snapshot)
+	return snapshot.HTML, err
+}
+
+func timeRangeQuery(ruleID string, from, to time.Time) url.Values {
+	query := url.Values{}
+	if !utility.IsNil(ruleID) {
+		query.Set("ruleid", ruleID)
+	}
+	if !from.IsZero() {
+		query.Set("from", from.Format(time.RFC3339))
+	}
+	if !to.IsZero() {
+		query.Set("to", to.Format(time.RFC3339))
+	}
+	return query
+}
+
+//GetResults returns results of the table by page
+func (client *Client) GetResults(tableName string, page int) ([]models.Result, error) {
+	var results []models.Result
+	query := url.Values{"tablename": {tableName}, "page": {strconv.Itoa(page)}}
+	return results, client.do(http.MethodGet, "/admin/results", query, nil, &results)
+}
+
+//ExportResults streams the exported results to the writer, query takes the same parameters as the export endpoint
+func (client *Client) ExportResults(w io.Writer, query url.Values) error {
+	res, err := client.send(http.MethodGet, "/admin/results/export", query, nil)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	_, err = io.Copy(w, res.Body)
+	return err
+}
+
+//GetUsers returns users by page
+func (client *Client) GetUsers(page int) ([]models.User, error) {
+	var users []models.User
+	query := url.Values{"page": {strconv.Itoa(page)}}
+	return users, client.do(http.MethodGet, "/admin/users", query, nil, &users)
+}
+
+//AddUser creates a user
+func (client *Client) AddUser(email, name, password, role string) (*models.User, error) {
+	var user models.User
+	body := map[string]string{"email": email, "name": name, "password": password, "role": role}
+	return &user, client.do(http.MethodPost, "/admin/users", nil, body, &user)
+}
+
+//GetAPIKeys returns api keys by page
+func (client *Client) GetAPIKeys(page int) ([]models.APIKey, error) {
+	var apiKeys []models.APIKey
+	query := url.Values{"page": {strconv.Itoa(page)}}
+	return apiKeys, client.do(http.MethodGet, "/admin/keys", query, nil, &apiKeys)
+}
+
+//IssueAPIKey issues an api key and returns the plain key
+func (client *Client) IssueAPIKey(name, role string) (id, key string, err error) {
+	var issued struct {
+		ID  string `json:"id"`
+		Key string `json:"key"`
+	}
+	err = client.do(http.MethodPost, "/admin/keys", nil, map[string]string{"name": name, "role": role}, &issued)
+	return issued.ID, issued.Key, err
+}
+
+//RevokeAPIKey revokes the api key by ID
+func (client *Client) RevokeAPIKey(id string) error {
+	return client.do(http.MethodDelete, "/admin/keys/"+url.PathEscape(id), nil, nil, nil)
+}
@@ -0,0 +1,65 @@
+package client
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sporule/grater/models"
+	"github.com/sporule/grater/modules/utility"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClient(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/api/v1/dist/links", func(c *gin.Context) {
+		if c.GetHeader(APIKeyHeader) != "key" {
+			c.JSON(utility.ErrorResult(utility.UnauthorizedError()).Expand())
+			return
+		}
+		if c.Query("ruleid") == "missing" {
+			c.JSON(utility.ErrorResult(utility.NotFoundError("no links")).Expand())
+			return
+		}
+		c.JSON(http.StatusOK, []gin.H{{"id": "1", "link": "http://test/1", "ruleID": c.Query("ruleid")}})
+	})
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	client := New(server.URL+"/api/v1/dist", "key")
+	assert.Equal(t, server.URL+"/api/v1", client.BaseURL, "the dist suffix should be removed")
+	links, err := client.AllocateLinks("rule", "scraper")
+	assert.Nil(t, err)
+	assert.Equal(t, "http://test/1", links[0].Link, "links should be decoded")
+
+	_, err = client.AllocateLinks("missing", "scraper")
+	assert.Equal(t, true, utility.IsErrorCode(err, utility.Enums().ErrorCodes.NotFound), "404 should be decoded as not found error")
+
+	_, err = New(server.URL+"/api/v1", "wrong").AllocateLinks("rule", "scraper")
+	assert.Equal(t, true, utility.IsErrorCode(err, utility.Enums().ErrorCodes.Unauthorized), "401 should be decoded as unauthorized error")
+}
+
+func TestLinksAdmin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/api/v1/admin/links/counts", func(c *gin.Context) {
+		c.JSON(http.StatusOK, []gin.H{{"ruleID": c.Query("ruleid"), "status": c.Query("status"), "count": 3}})
+	})
+	var requeued models.LinkFilter
+	router.POST("/api/v1/admin/links/requeue", func(c *gin.Context) {
+		c.ShouldBindJSON(&requeued)
+		c.JSON(http.StatusOK, nil)
+	})
+	server := httptest.NewServer(router)
+	defer server.Close()
+
+	client := New(server.URL+"/api/v1", "key")
+	counts, err := client.CountLinks(models.LinkFilter{RuleID: "rule", Status: "Running"})
+	assert.Nil(t, err)
+	assert.Equal(t, models.LinkCount{RuleID: "rule", Status: "Running", Count: 3}, counts[0], "filters should be sent in the query")
+
+	assert.Nil(t, client.RequeueLinks(models.LinkFilter{LinkIDs: []string{"1", "2"}}))
+	assert.Equal(t, []string{"1", "2"}, requeued.LinkIDs, "filters should be sent in the body")
+}
@@ -0,0 +1,132 @@
+package config
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sporule/grater/modules/utility"
+)
+
+func writeFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	assert.Nil(t, ioutil.WriteFile(path, []byte(content), 0600))
+	return path
+}
+
+func setEnv(t *testing.T, key, value string) {
+	previous, existed := os.LookupEnv(key)
+	os.Setenv(key, value)
+	t.Cleanup(func() {
+		if existed {
+			os.Setenv(key, previous)
+			return
+		}
+		os.Unsetenv(key)
+	})
+}
+
+func TestDefaults(t *testing.T) {
+	setEnv(t, "JWT_SECRET", "secret")
+	config, args, err := Load("grater", nil)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(args))
+	assert.Equal(t, 9999, config.Server.Port)
+	assert.Equal(t, []string{"http://127.0.0.1:8080"}, config.Server.CORS)
+	assert.Equal(t, 0.5, config.Drift.LayoutRatio)
+	assert.Equal(t, uint64(15), config.Drift.CheckInterval)
+	assert.NotEqual(t, "", config.Name, "a random name should be generated")
+}
+
+func TestLayers(t *testing.T) {
+	file := writeFile(t, "grater.yaml", `
+server:
+  port: 8000
+  cors: [http://a.com, http://b.com]
+database:
+  name: file
+scraper:
+  threads: 5
+`)
+	setEnv(t, "JWT_SECRET", "secret")
+	setEnv(t, "DATABASE_NAME", "env")
+	setEnv(t, "THREADS", "7")
+	config, args, err := Load("grater", []string{"-config", file, "-scraper.threads", "9", "-drift.autoPause", "scraper"})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"scraper"}, args)
+	assert.Equal(t, 8000, config.Server.Port, "the file should override the default")
+	assert.Equal(t, []string{"http://a.com", "http://b.com"}, config.Server.CORS)
+	assert.Equal(t, "env", config.Database.Name, "env should override the file")
+	assert.Equal(t, 9, config.Scraper.Threads, "flags should override env")
+	assert.Equal(t, true, config.Drift.AutoPause)
+}
+
+func TestFileFormats(t *testing.T) {
+	files := map[string]string{
+		"grater.json": `{"mode": "dist", "log": {"level": "debug"}, "notify": {"smtpTo": ["a@b.com"], "smtpAddr": "mail:25"}}`,
+		"grater.toml": `
+mode = "dist" # the distributor only
+log = { level = 'debug' }
+
+[notify]
+smtpAddr = "mail:25"
+smtpTo = [
+  "a@b.com", # the on call
+]
+`,
+	}
+	setEnv(t, "JWT_SECRET", "secret")
+	for name, content := range files {
+		setEnv(t, FileEnv, writeFile(t, name, content))
+		config, _, err := Load("grater", nil)
+		assert.Nil(t, err, name)
+		assert.Equal(t, "dist", config.Mode, name)
+		assert.Equal(t, "debug", config.Log.Level, name)
+		assert.Equal(t, []string{"a@b.com"}, config.Notify.SMTPTo, name)
+	}
+}
+
+func TestUnknownKey(t *testing.T) {
+	file := writeFile(t, "grater.yaml", "server:\n  prot: 8000\n")
+	config, _, err := Load("grater", []string{"-config", file})
+	assert.Nil(t, config)
+	assert.Equal(t, true, strings.Contains(err.Error(), "prot"))
+}
+
+func TestValidation(t *testing.T) {
+	config, _, err := Load("grater", []string{"-mode", "all", "-drift.layoutRatio", "2"})
+	assert.NotNil(t, config, "the config should be returned so it can be printed")
+	details := err.(*utility.AppError).Details.(map[string]string)
+	assert.Equal(t, "must be one of both, dist, api, scraper", details["mode"])
+	assert.Equal(t, "must be between 0 and 1", details["drift.layoutRatio"])
+	assert.Equal(t, "is required when the auth is enabled", details["auth.jwtSecret"])
+
+	for _, args := range [][]string{{"-auth.disabled"}, {"-mode", "scraper"}} {
+		_, _, err = Load("grater", args)
+		assert.Nil(t, err, "the secret is only needed by the api")
+	}
+
+	setEnv(t, "PORT", "abc")
+	config, _, err = Load("grater", nil)
+	assert.Nil(t, config)
+	assert.Equal(t, "PORT must be an integer", err.(*utility.AppError).Details.(map[string]string)["server.port"])
+}
+
+func TestPrintMasksSecrets(t *testing.T) {
+	config := Default()
+	config.Auth.JWTSecret = "topsecret"
+	config.Notify.SlackURL = "https://hooks.slack.com/services/abc"
+	var buf bytes.Buffer
+	assert.Nil(t, config.Print(&buf, "yaml"))
+	output := buf.String()
+	for _, secret := range []string{"topsecret", "hooks.slack.com", "root:example"} {
+		assert.Equal(t, false, strings.Contains(output, secret), secret+" should be masked")
+	}
+	assert.Equal(t, true, strings.Contains(output, "port: 9999"))
+	assert.Equal(t, "topsecret", config.Auth.JWTSecret, "the config should not be changed")
+}
@@ -0,0 +1,253 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/google/uuid"
+	"gopkg.in/yaml.v2"
+
+	"github.com/sporule/grater/modules/utility"
+)
+
+//FileEnv is the environment variable of the config file, the -config flag overrides it
+const FileEnv = "CONFIG_FILE"
+
+//field is a leaf of the config with its tags
+type field struct {
+	path   string
+	env    string
+	def    string
+	usage  string
+	secret bool
+	value  reflect.Value
+}
+
+//fields walks the config and returns the leaves in declaration order
+func fields(config *Config) []field {
+	var result []field
+	var walk func(value reflect.Value, prefix string)
+	walk = func(value reflect.Value, prefix string) {
+		for i := 0; i < value.NumField(); i++ {
+			structField := value.Type().Field(i)
+			path := prefix + strings.Split(structField.Tag.Get("json"), ",")[0]
+			if structField.Type.Kind() == reflect.Struct {
+				walk(value.Field(i), path+".")
+				continue
+			}
+			result = append(result, field{
+				path:   path,
+				env:    structField.Tag.Get("env"),
+				def:    structField.Tag.Get("default"),
+				usage:  structField.Tag.Get("usage"),
+				secret: structField.Tag.Get("secret") == "true",
+				value:  value.Field(i),
+			})
+		}
+	}
+	walk(reflect.ValueOf(config).Elem(), "")
+	return result
+}
+
+//set parses the raw string into the field, lists are comma separated and a bool that isn't true or false is true if it is not empty
+func (f field) set(raw string) error {
+	switch f.value.Kind() {
+	case reflect.String:
+		f.value.SetString(raw)
+	case reflect.Int, reflect.Int64:
+		value, err := strconv.ParseInt(strings.TrimSpace(raw), 10, 64)
+		if err != nil {
+			return errors.New("must be an integer")
+		}
+		f.value.SetInt(value)
+	case reflect.Uint64:
+		value, err := strconv.ParseUint(strings.TrimSpace(raw), 10, 64)
+		if err != nil {
+			return errors.New("must be a positive integer")
+		}
+		f.value.SetUint(value)
+	case reflect.Float64:
+		value, err := strconv.ParseFloat(strings.TrimSpace(raw), 64)
+		if err != nil {
+			return errors.New("must be a number")
+		}
+		f.value.SetFloat(value)
+	case reflect.Bool:
+		value, err := strconv.ParseBool(strings.TrimSpace(raw))
+		if err != nil {
+			//the environment variables used to be switched on by any value
+			value = strings.TrimSpace(raw) != ""
+		}
+		f.value.SetBool(value)
+	case reflect.Slice:
+		values := []string{}
+		for _, value := range strings.Split(raw, ",") {
+			if value = strings.TrimSpace(value); value != "" {
+				values = append(values, value)
+			}
+		}
+		f.value.Set(reflect.ValueOf(values))
+	default:
+		return errors.New("unsupported type " + f.value.Kind().String())
+	}
+	return nil
+}
+
+//flagValue keeps the flag until the other layers are loaded
+type flagValue struct {
+	field   field
+	pending *[]pendingFlag
+}
+
+type pendingFlag struct {
+	field field
+	raw   string
+}
+
+func (value *flagValue) String() string {
+	return ""
+}
+
+func (value *flagValue) Set(raw string) error {
+	*value.pending = append(*value.pending, pendingFlag{field: value.field, raw: raw})
+	return nil
+}
+
+func (value *flagValue) IsBoolFlag() bool {
+	return value.field.value.Kind() == reflect.Bool
+}
+
+//Default returns the config with only the defaults
+func Default() *Config {
+	config := &Config{}
+	for _, f := range fields(config) {
+		f.set(f.def)
+	}
+	return config
+}
+
+//Load builds the config from the defaults, the config file, the environment variables and the flags in args, it returns the arguments after the flags
+func Load(name string, args []string) (*Config, []string, error) {
+	config := Default()
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	configFile := flags.String("config", utility.GetEnv(FileEnv, ""), "yaml, json or toml config file, env "+FileEnv)
+	var pending []pendingFlag
+	for _, f := range fields(config) {
+		usage := f.usage
+		if f.env != "" {
+			usage += ", env " + f.env
+		}
+		if f.def != "" {
+			usage += " (default " + f.def + ")"
+		}
+		flags.Var(&flagValue{field: f, pending: &pending}, f.path, usage)
+	}
+	if err := flags.Parse(args); err != nil {
+		return nil, nil, err
+	}
+	if *configFile != "" {
+		if err := config.loadFile(*configFile); err != nil {
+			return nil, nil, err
+		}
+	}
+	details := map[string]string{}
+	for _, f := range fields(config) {
+		if value, ok := os.LookupEnv(f.env); ok && f.env != "" {
+			if err := f.set(value); err != nil {
+				details[f.path] = f.env + " " + err.Error()
+			}
+		}
+	}
+	for _, flagged := range pending {
+		if err := flagged.field.set(flagged.raw); err != nil {
+			details[flagged.field.path] = "-" + flagged.field.path + " " + err.Error()
+		}
+	}
+	if len(details) > 0 {
+		return nil, nil, utility.ValidationError("The configuration is invalid", details)
+	}
+	if config.Name == "" {
+		id, _ := uuid.NewRandom()
+		config.Name = id.String()
+	}
+	return config, flags.Args(), config.Validate()
+}
+
+//loadFile reads the config file on top of the current values, the format is picked by the extension and unknown keys are rejected
+func (config *Config) loadFile(path string) error {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	//the yaml and toml files are converted to json so every format uses the json names
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		if content, err = utility.YAMLToJSON(content); err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+	case ".toml":
+		var values map[string]interface{}
+		if _, err := toml.Decode(string(content), &values); err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		if content, err = json.Marshal(values); err != nil {
+			return err
+		}
+	case ".json":
+	default:
+		return errors.New(path + ": the config file should be yaml, json or toml")
+	}
+	if err := utility.DecodeJSONStrict(content, config); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	return nil
+}
+
+const mask = "******"
+
+//Masked returns a copy of the config with the secrets masked, the secrets are strings so the lists can be shared
+func (config *Config) Masked() *Config {
+	masked := *config
+	for _, f := range fields(&masked) {
+		if f.secret && f.value.String() != "" {
+			f.value.SetString(mask)
+		}
+	}
+	return &masked
+}
+
+//Print writes the config with the secrets masked in yaml or json
+func (config *Config) Print(w io.Writer, format string) error {
+	content, err := json.MarshalIndent(config.Masked(), "", "  ")
+	if err != nil {
+		return err
+	}
+	switch format {
+	case "json":
+		_, err = w.Write(append(content, '\n'))
+		return err
+	case "yaml", "":
+		//json is valid yaml and the map slice keeps the order of the fields
+		var ordered yaml.MapSlice
+		if err := yaml.Unmarshal(content, &ordered); err != nil {
+			return err
+		}
+		content, err = yaml.Marshal(ordered)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(content)
+		return err
+	}
+	return utility.ValidationError("Invalid format", map[string]string{"format": "must be yaml or json"})
+}
@@ -1,33 +1,56 @@
 package database
 
+import (
+	"context"
+
+	"github.com/sporule/grater/modules/config"
+)
+
 //Database is the interface for storage layer
 type Database interface {
 	Connect(uri, databaseName string) error
+	Ping(ctx context.Context) error
 	GetOne(table string, item interface{}, filtersMap map[string]interface{}) error
 	GetAll(table string, items interface{}, filtersMap map[string]interface{}, sortByMap map[string]interface{}, page int) error
+	GetCursor(table string, filtersMap map[string]interface{}, sortByMap map[string]interface{}) (Cursor, error)
+	CountGroups(table string, filtersMap map[string]interface{}, groupByFields []string) ([]map[string]interface{}, error)
+	SumGroups(table string, filtersMap map[string]interface{}, groupByFields []string, sumFields []string) ([]map[string]interface{}, error)
+	UnwindGroups(table string, filtersMap map[string]interface{}, unwindField string, groupByFields []string, timeField string, latestFields []string) ([]map[string]interface{}, error)
 	InsertOne(table string, item interface{}) error
 	InsertMany(table string, items []interface{}) error
 	UpdateOne(table string, filtersMap map[string]interface{}, updatedItem interface{}) error
 	UpsertOne(table string, filtersMap map[string]interface{}, updatedItem interface{}) error
 	UpdateMany(table string, filtersMap map[string]interface{}, updatesFieldsMap map[string]interface{}) error
+	IncrementMany(table string, filtersMap map[string]interface{}, incrementsMap map[string]interface{}, updatesFieldsMap map[string]interface{}) error
 	UpsertMany(table string, filtersMap map[string]interface{}, updatesFieldsMap map[string]interface{}) error
+	DeleteMany(table string, filtersMap map[string]interface{}) error
+	EnsureIndex(table string, fields []string, unique bool) error
 	InQry(values interface{}) interface{}
 	NotInQry(values interface{}) interface{}
 	GreaterThanQry(value interface{}) interface{}
 	LessThanQry(value interface{}) interface{}
 	NotEqualQry(value interface{}) interface{}
+	BetweenQry(from, to interface{}) interface{}
+}
+
+//Cursor iterates over the items of a query one by one without loading them all into memory
+type Cursor interface {
+	Next() bool
+	Decode(item interface{}) error
+	Err() error
+	Close() error
 }
 
 //Client is the global database instance
 var Client Database
 
 //InitiateDB creates database object
-func InitiateDB(databaseType, uri, databaseName string) error {
+func InitiateDB(databaseType string, config config.Database) error {
 	switch databaseType {
 	default:
 		//default is mongodb
-		Client = &MongoDB{}
+		Client = &MongoDB{itemsPerPage: config.ItemsPerPage}
 	}
-	err := Client.Connect(uri, databaseName)
+	err := Client.Connect(config.URI, config.Name)
 	return err
 }
@@ -2,12 +2,13 @@ package database
 
 import (
 	"context"
-	"log"
-	"strconv"
+	"strings"
 	"time"
 
 	"github.com/sporule/grater/modules/database/mgoqry"
+	"github.com/sporule/grater/modules/logger"
 	"github.com/sporule/grater/modules/utility"
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 	"go.mongodb.org/mongo-driver/mongo/readpref"
@@ -15,7 +16,8 @@ import (
 
 //MongoDB is the mongodb implementation
 type MongoDB struct {
-	client *mongo.Database
+	client       *mongo.Database
+	itemsPerPage int
 }
 
 //Connect creates a connection pool to mongodb
@@ -26,26 +28,37 @@ func (db *MongoDB) Connect(uri, databaseName string) error {
 	if err != nil {
 		return err
 	}
-	if err := client.Ping(context.TODO(), readpref.Primary()); err != nil {
-		// Can't connect to Mongo server
-		log.Fatal("Can't connect to Mongo server ", err)
-	}
 	db.client = client.Database(databaseName)
+	if err := db.Ping(ctx); err != nil {
+		//the driver keeps reconnecting in the background, /readyz reports the database as failed until it is reachable
+		logger.New("component", "database").Warn("Can't connect to Mongo server", "error", err)
+	}
 	return nil
 }
 
+//Ping checks if the primary server is reachable
+func (db *MongoDB) Ping(ctx context.Context) error {
+	return db.client.Client().Ping(ctx, readpref.Primary())
+}
+
 //GetOne returns one result
 func (db *MongoDB) GetOne(table string, item interface{}, filtersMap map[string]interface{}) error {
 	//convert filters map to filter bson.M
 	filters := mgoqry.Bsons(filtersMap)
-	return db.client.Collection(table).FindOne(context.TODO(), filters).Decode(item)
+	err := db.client.Collection(table).FindOne(context.TODO(), filters).Decode(item)
+	if err == mongo.ErrNoDocuments {
+		return utility.NotFoundError(utility.Enums().ErrorMessages.RecordNotFound)
+	}
+	return err
 }
 
 //GetAll returns all result
 func (db *MongoDB) GetAll(table string, items interface{}, filtersMap map[string]interface{}, sortByMap map[string]interface{}, page int) error {
 	//set pagination
-	itemPerPageStr := utility.GetEnv("ITEM_PER_PAGE", "10")
-	itemPerPage, _ := strconv.Atoi(itemPerPageStr)
+	itemPerPage := db.itemsPerPage
+	if itemPerPage <= 0 {
+		itemPerPage = 10
+	}
 	skipSize := (page - 1) * itemPerPage
 	if page == 0 {
 		//set unlimited item per page
@@ -70,6 +83,180 @@ func (db *MongoDB) GetAll(table string, items interface{}, filtersMap map[string
 	return cursor.All(context.Background(), items)
 }
 
+//GetCursor returns a cursor over all items that match the filters
+func (db *MongoDB) GetCursor(table string, filtersMap map[string]interface{}, sortByMap map[string]interface{}) (Cursor, error) {
+	options := &options.FindOptions{}
+	if sortByMap != nil {
+		options.SetSort(mgoqry.Bsons(sortByMap))
+	}
+	filters := mgoqry.Bsons(filtersMap)
+	cursor, err := db.client.Collection(table).Find(context.TODO(), filters, options)
+	if err != nil {
+		return nil, err
+	}
+	return &mongoCursor{cursor: cursor}, nil
+}
+
+//CountGroups counts the items that match the filters by the group fields, each group is returned as the group fields with a "count" field
+func (db *MongoDB) CountGroups(table string, filtersMap map[string]interface{}, groupByFields []string) ([]map[string]interface{}, error) {
+	groupID := mgoqry.Bsons(nil)
+	for _, field := range groupByFields {
+		groupID[field] = "$" + field
+	}
+	pipeline := []interface{}{
+		mgoqry.Bson("$match", mgoqry.Bsons(filtersMap)),
+		mgoqry.Bson("$group", mgoqry.Bsons(map[string]interface{}{"_id": groupID, "count": mgoqry.Bson("$sum", 1)})),
+	}
+	cursor, err := db.client.Collection(table).Aggregate(context.TODO(), pipeline)
+	if err != nil {
+		return nil, err
+	}
+	var groups []struct {
+		ID    map[string]interface{} `bson:"_id"`
+		Count int64                  `bson:"count"`
+	}
+	if err := cursor.All(context.TODO(), &groups); err != nil {
+		return nil, err
+	}
+	results := make([]map[string]interface{}, len(groups))
+	for i, group := range groups {
+		results[i] = group.ID
+		if results[i] == nil {
+			results[i] = make(map[string]interface{})
+		}
+		results[i]["count"] = group.Count
+	}
+	return results, nil
+}
+
+//SumGroups returns the group by fields and the sum of each sum field as int64 for every group
+func (db *MongoDB) SumGroups(table string, filtersMap map[string]interface{}, groupByFields []string, sumFields []string) ([]map[string]interface{}, error) {
+	groupID := mgoqry.Bsons(nil)
+	for _, field := range groupByFields {
+		groupID[field] = "$" + field
+	}
+	group := map[string]interface{}{"_id": groupID}
+	for _, field := range sumFields {
+		group[field] = mgoqry.Bson("$sum", "$"+field)
+	}
+	pipeline := []interface{}{
+		mgoqry.Bson("$match", mgoqry.Bsons(filtersMap)),
+		mgoqry.Bson("$group", mgoqry.Bsons(group)),
+	}
+	cursor, err := db.client.Collection(table).Aggregate(context.TODO(), pipeline)
+	if err != nil {
+		return nil, err
+	}
+	var groups []struct {
+		ID   map[string]interface{} `bson:"_id"`
+		Sums map[string]interface{} `bson:",inline"`
+	}
+	if err := cursor.All(context.TODO(), &groups); err != nil {
+		return nil, err
+	}
+	results := make([]map[string]interface{}, len(groups))
+	for i, group := range groups {
+		results[i] = make(map[string]interface{})
+		for key, value := range group.ID {
+			results[i][key] = value
+		}
+		for _, field := range sumFields {
+			//$sum returns int32 or int64 depending on the size
+			switch value := group.Sums[field].(type) {
+			case int32:
+				results[i][field] = int64(value)
+			case int64:
+				results[i][field] = value
+			case float64:
+				results[i][field] = int64(value)
+			default:
+				results[i][field] = int64(0)
+			}
+		}
+	}
+	return results, nil
+}
+
+//UnwindGroups counts the items that match the filters by the group fields after every element of the unwind field is made an item of its own,
+//an item with an empty unwind field is kept, the group fields such as missing.path are returned by the name after the last dot with a "count",
+//the "first" and "last" value of the time field and the latest fields of the item with the last time
+func (db *MongoDB) UnwindGroups(table string, filtersMap map[string]interface{}, unwindField string, groupByFields []string, timeField string, latestFields []string) ([]map[string]interface{}, error) {
+	groupID := mgoqry.Bsons(nil)
+	for _, field := range groupByFields {
+		groupID[field[strings.LastIndex(field, ".")+1:]] = "$" + field
+	}
+	latest := mgoqry.Bsons(nil)
+	for _, field := range latestFields {
+		latest[field] = "$" + field
+	}
+	pipeline := []interface{}{
+		mgoqry.Bson("$match", mgoqry.Bsons(filtersMap)),
+		//the items are sorted by time so $last is the latest item
+		mgoqry.Bson("$sort", mgoqry.Bson(timeField, 1)),
+		mgoqry.Bson("$unwind", mgoqry.Bsons(map[string]interface{}{"path": "$" + unwindField, "preserveNullAndEmptyArrays": true})),
+		mgoqry.Bson("$group", mgoqry.Bsons(map[string]interface{}{
+			"_id":    groupID,
+			"count":  mgoqry.Bson("$sum", 1),
+			"first":  mgoqry.Bson("$min", "$"+timeField),
+			"last":   mgoqry.Bson("$max", "$"+timeField),
+			"latest": mgoqry.Bson("$last", latest),
+		})),
+	}
+	cursor, err := db.client.Collection(table).Aggregate(context.TODO(), pipeline)
+	if err != nil {
+		return nil, err
+	}
+	var groups []struct {
+		ID     map[string]interface{} `bson:"_id"`
+		Count  int64                  `bson:"count"`
+		First  time.Time              `bson:"first"`
+		Last   time.Time              `bson:"last"`
+		Latest map[string]interface{} `bson:"latest"`
+	}
+	if err := cursor.All(context.TODO(), &groups); err != nil {
+		return nil, err
+	}
+	results := make([]map[string]interface{}, len(groups))
+	for i, group := range groups {
+		results[i] = make(map[string]interface{})
+		for key, value := range group.ID {
+			results[i][key] = value
+		}
+		for key, value := range group.Latest {
+			results[i][key] = value
+		}
+		results[i]["count"] = group.Count
+		results[i]["first"] = group.First
+		results[i]["last"] = group.Last
+	}
+	return results, nil
+}
+
+//mongoCursor wraps the mongo cursor to fit the Cursor interface
+type mongoCursor struct {
+	cursor *mongo.Cursor
+}
+
+//Next moves the cursor to the next item
+func (c *mongoCursor) Next() bool {
+	return c.cursor.Next(context.TODO())
+}
+
+//Decode decodes the current item
+func (c *mongoCursor) Decode(item interface{}) error {
+	return c.cursor.Decode(item)
+}
+
+//Err returns the last error of the cursor
+func (c *mongoCursor) Err() error {
+	return c.cursor.Err()
+}
+
+//Close closes the cursor
+func (c *mongoCursor) Close() error {
+	return c.cursor.Close(context.TODO())
+}
+
 //InsertOne inserts one item to the database
 func (db *MongoDB) InsertOne(table string, item interface{}) error {
 	_, err := db.client.Collection(table).InsertOne(context.TODO(), item)
@@ -104,6 +291,32 @@ func (db *MongoDB) UpdateMany(table string, filtersMap map[string]interface{}, u
 	return err
 }
 
+//IncrementMany adds the increments to the numeric fields and sets the other fields of many items
+func (db *MongoDB) IncrementMany(table string, filtersMap map[string]interface{}, incrementsMap map[string]interface{}, updatesFieldsMap map[string]interface{}) error {
+	filters := mgoqry.Bsons(filtersMap)
+	update := mgoqry.Bsons(map[string]interface{}{"$inc": mgoqry.Bsons(incrementsMap), "$set": mgoqry.Bsons(updatesFieldsMap)})
+	_, err := db.client.Collection(table).UpdateMany(context.TODO(), filters, update)
+	return err
+}
+
+//DeleteMany deletes the items by filters
+func (db *MongoDB) DeleteMany(table string, filtersMap map[string]interface{}) error {
+	filters := mgoqry.Bsons(filtersMap)
+	_, err := db.client.Collection(table).DeleteMany(context.TODO(), filters)
+	return err
+}
+
+//EnsureIndex creates the ascending index on the fields if it doesn't exist, the index is named by the fields so it is only created once
+func (db *MongoDB) EnsureIndex(table string, fields []string, unique bool) error {
+	keys := bson.D{}
+	for _, field := range fields {
+		keys = append(keys, bson.E{Key: field, Value: 1})
+	}
+	index := mongo.IndexModel{Keys: keys, Options: options.Index().SetName(strings.Join(fields, "_")).SetUnique(unique)}
+	_, err := db.client.Collection(table).Indexes().CreateOne(context.TODO(), index)
+	return err
+}
+
 //UpsertMany updates or inserts many items
 func (db *MongoDB) UpsertMany(table string, filtersMap map[string]interface{}, updatesFieldsMap map[string]interface{}) error {
 	filters := mgoqry.Bsons(filtersMap)
@@ -136,3 +349,15 @@ func (db *MongoDB) LessThanQry(value interface{}) interface{} {
 func (db *MongoDB) NotEqualQry(value interface{}) interface{} {
 	return mgoqry.Bson("$ne", value)
 }
+
+//BetweenQry take two values and returns "gte" and "lt" query, the empty side will be ignored
+func (db *MongoDB) BetweenQry(from, to interface{}) interface{} {
+	qry := mgoqry.Bsons(nil)
+	if !utility.IsNil(from) {
+		qry["$gte"] = from
+	}
+	if !utility.IsNil(to) {
+		qry["$lt"] = to
+	}
+	return qry
+}
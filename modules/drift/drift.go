@@ -0,0 +1,139 @@
+package drift
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/sporule/grater/models"
+	"github.com/sporule/grater/modules/config"
+	"github.com/sporule/grater/modules/logger"
+	"github.com/sporule/grater/modules/notifier"
+	"github.com/sporule/grater/modules/utility"
+)
+
+//window returns the recent period that is compared with the baseline
+func window(config config.Drift) time.Duration {
+	return time.Duration(config.Window) * time.Minute
+}
+
+//baseline returns the period before the window that is treated as normal
+func baseline(config config.Drift) time.Duration {
+	return time.Duration(config.Baseline) * time.Hour
+}
+
+func ratio(part, total int64) float64 {
+	if total <= 0 {
+		return 0
+	}
+	return float64(part) / float64(total)
+}
+
+//Evaluate compares the window with the baseline, a redesign shows up as a jump of the layout error ratio or a drop of records per page
+func Evaluate(current, baseline models.RuleStatTotals, config config.Drift) (evaluated, drift bool, reason string) {
+	if current.Pages < config.MinPages {
+		return false, false, ""
+	}
+	currentRatio := ratio(current.LayoutErrors, current.Pages)
+	baselineRatio := ratio(baseline.LayoutErrors, baseline.Pages)
+	if currentRatio >= config.LayoutRatio && currentRatio >= baselineRatio*config.Factor {
+		return true, true, fmt.Sprintf("%.0f%% of pages had layout errors in the last %s, the baseline is %.0f%%", currentRatio*100, window(config), baselineRatio*100)
+	}
+	if baseline.Pages >= config.MinPages {
+		currentRecords := ratio(current.Records, current.Pages)
+		baselineRecords := ratio(baseline.Records, baseline.Pages)
+		if baselineRecords > 0 && currentRecords < baselineRecords*config.RecordsDrop {
+			return true, true, fmt.Sprintf("%.2f records per page in the last %s, the baseline is %.2f", currentRecords, window(config), baselineRecords)
+		}
+	}
+	return true, false, ""
+}
+
+//Reports evaluates every rule that is not cancelled
+func Reports(config config.Drift) ([]models.DriftReport, error) {
+	now := time.Now()
+	windowStart := now.Add(-window(config))
+	current, err := models.SumRuleStats(windowStart, now)
+	if err != nil {
+		return nil, err
+	}
+	baseline, err := models.SumRuleStats(windowStart.Add(-baseline(config)), windowStart)
+	if err != nil {
+		return nil, err
+	}
+	rules, err := models.GetRules(nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	reports := make([]models.DriftReport, 0, len(rules))
+	for _, rule := range rules {
+		if rule.Status == utility.Enums().Status.Cancelled {
+			continue
+		}
+		report := models.DriftReport{
+			RuleID:   rule.ID,
+			Name:     rule.Name,
+			Status:   rule.Status,
+			Current:  current[rule.ID],
+			Baseline: baseline[rule.ID],
+			Flagged:  rule.LayoutDrift,
+		}
+		report.Evaluated, report.Drift, report.Reason = Evaluate(report.Current, report.Baseline, config)
+		reports = append(reports, report)
+	}
+	return reports, nil
+}
+
+//Detect flags the rules with layout drift and notifies, a flagged rule that is back to normal is cleared unless it is paused
+func Detect(config config.Drift, notify *notifier.Notifier) error {
+	log := logger.New("component", "drift")
+	reports, err := Reports(config)
+	if err != nil {
+		log.Error("Unable to evaluate layout drift", "error", err)
+		return err
+	}
+	for _, report := range reports {
+		if !report.Evaluated || report.Drift == report.Flagged {
+			continue
+		}
+		rule, err := models.GetRule(report.RuleID)
+		if err != nil {
+			log.Error("Unable to get the rule", "rule", report.RuleID, "error", err)
+			continue
+		}
+		fields := map[string]string{
+			"rule":                 rule.Name,
+			"ruleID":               rule.ID,
+			"pages":                strconv.FormatInt(report.Current.Pages, 10),
+			"layoutErrors":         strconv.FormatInt(report.Current.LayoutErrors, 10),
+			"records":              strconv.FormatInt(report.Current.Records, 10),
+			"baselinePages":        strconv.FormatInt(report.Baseline.Pages, 10),
+			"baselineRecords":      strconv.FormatInt(report.Baseline.Records, 10),
+			"baselineLayoutErrors": strconv.FormatInt(report.Baseline.LayoutErrors, 10),
+		}
+		if report.Drift {
+			if err := rule.FlagLayoutDrift(report.Reason, config.AutoPause); err != nil {
+				log.Error("Unable to flag the rule", "rule", rule.ID, "error", err)
+				continue
+			}
+			log.Warn("Layout drift detected", "rule", rule.ID, "reason", report.Reason, "paused", config.AutoPause)
+			title := "Layout drift detected on " + rule.Name
+			if config.AutoPause {
+				title += ", the rule is paused"
+			}
+			notify.Notify(notifier.Notification{Key: "layout-drift:" + rule.ID, Title: title, Message: report.Reason, Severity: notifier.Warning, Fields: fields})
+			continue
+		}
+		if rule.Status == utility.Enums().Status.Paused {
+			//a paused rule has to be resumed by an admin
+			continue
+		}
+		if err := rule.ClearLayoutDrift(); err != nil {
+			log.Error("Unable to clear the layout drift flag", "rule", rule.ID, "error", err)
+			continue
+		}
+		log.Info("Layout drift resolved", "rule", rule.ID)
+		notify.Notify(notifier.Notification{Key: "layout-drift:" + rule.ID, Title: "Layout drift resolved on " + rule.Name, Message: "The layout error ratio and records per page are back to the baseline", Severity: notifier.Warning, Fields: fields, Resolved: true})
+	}
+	return nil
+}
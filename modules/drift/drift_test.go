@@ -0,0 +1,36 @@
+package drift
+
+import (
+	"testing"
+
+	"github.com/sporule/grater/models"
+	"github.com/sporule/grater/modules/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEvaluate(t *testing.T) {
+	settings := config.Drift{Window: 60, MinPages: 20, LayoutRatio: 0.5, Factor: 3, RecordsDrop: 0.2}
+	baseline := models.RuleStatTotals{Pages: 1000, Records: 1000, LayoutErrors: 50}
+
+	evaluated, drift, _ := Evaluate(models.RuleStatTotals{Pages: 10, LayoutErrors: 10}, baseline, settings)
+	assert.False(t, evaluated, "too few pages should not be evaluated")
+	assert.False(t, drift)
+
+	evaluated, drift, reason := Evaluate(models.RuleStatTotals{Pages: 40, Records: 10, LayoutErrors: 30}, baseline, settings)
+	assert.True(t, evaluated)
+	assert.True(t, drift, "layout error ratio jumped from 5% to 75%")
+	assert.Contains(t, reason, "75%")
+
+	_, drift, _ = Evaluate(models.RuleStatTotals{Pages: 40, Records: 40, LayoutErrors: 2}, baseline, settings)
+	assert.False(t, drift, "normal window should not drift")
+
+	_, drift, _ = Evaluate(models.RuleStatTotals{Pages: 40, Records: 4, LayoutErrors: 0}, baseline, settings)
+	assert.True(t, drift, "records per page dropped from 1 to 0.1")
+
+	_, drift, _ = Evaluate(models.RuleStatTotals{Pages: 40, Records: 4, LayoutErrors: 0}, models.RuleStatTotals{Pages: 5, Records: 5}, settings)
+	assert.False(t, drift, "records drop needs enough baseline pages")
+
+	noisy := models.RuleStatTotals{Pages: 1000, Records: 400, LayoutErrors: 600}
+	_, drift, _ = Evaluate(models.RuleStatTotals{Pages: 40, Records: 16, LayoutErrors: 24}, noisy, settings)
+	assert.False(t, drift, "a site that always has layout errors should not drift")
+}
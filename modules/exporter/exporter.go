@@ -0,0 +1,394 @@
+package exporter
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sporule/grater/models"
+	"github.com/sporule/grater/modules/database"
+	"github.com/sporule/grater/modules/utility"
+	"github.com/xitongsys/parquet-go/common"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+//Supported export formats
+const (
+	CSV     = "csv"
+	JSONL   = "jsonl"
+	Parquet = "parquet"
+)
+
+//Options sets which results will be exported and how
+type Options struct {
+	TableName string
+	RuleID    string
+	From      time.Time
+	To        time.Time
+	Fields    []string
+	Format    string
+}
+
+//ParseOptions builds the options from raw string inputs, from and to are in RFC3339 format and fields are separated by comma
+func ParseOptions(tableName, ruleID, from, to, fields, format string) (Options, error) {
+	opts := Options{
+		TableName: tableName,
+		RuleID:    ruleID,
+		Format:    strings.ToLower(format),
+	}
+	var err error
+	if !utility.IsNil(from) {
+		if opts.From, err = time.Parse(time.RFC3339, from); err != nil {
+			return opts, utility.ValidationError("Invalid from time, it should be in RFC3339 format", map[string]string{"from": from})
+		}
+	}
+	if !utility.IsNil(to) {
+		if opts.To, err = time.Parse(time.RFC3339, to); err != nil {
+			return opts, utility.ValidationError("Invalid to time, it should be in RFC3339 format", map[string]string{"to": to})
+		}
+	}
+	for _, field := range strings.Split(fields, ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			opts.Fields = append(opts.Fields, field)
+		}
+	}
+	return opts, nil
+}
+
+//ContentType returns the http content type of the export format
+func ContentType(format string) string {
+	switch format {
+	case JSONL:
+		return "application/x-ndjson"
+	case Parquet:
+		return "application/octet-stream"
+	default:
+		return "text/csv"
+	}
+}
+
+//Validate checks the options and fills the table name from the rule if it is missing,
+//the table must be the target location of a rule so the other tables such as the users can't be exported
+func (opts *Options) Validate() error {
+	if opts.Format == "" {
+		opts.Format = CSV
+	}
+	if opts.Format != CSV && opts.Format != JSONL && opts.Format != Parquet {
+		return utility.ValidationError("Unsupported export format: "+opts.Format, map[string]string{"format": opts.Format})
+	}
+	if utility.IsNil(opts.TableName) {
+		if utility.IsNil(opts.RuleID) {
+			return utility.ValidationError(utility.Enums().ErrorMessages.LackOfInfo, map[string]string{"tablename": "either tablename or ruleid is required"})
+		}
+		rule, err := models.GetRule(opts.RuleID)
+		if err != nil {
+			return err
+		}
+		opts.TableName = rule.TargetLocation
+		return nil
+	}
+	isTarget, err := models.IsTargetLocation(opts.TableName)
+	if err != nil {
+		return err
+	}
+	if !isTarget {
+		return utility.ValidationError("Invalid table name", map[string]string{"tablename": "must be the targetLocation of a rule"})
+	}
+	return nil
+}
+
+//filters converts the options to database filters
+func (opts *Options) filters() map[string]interface{} {
+	filters := make(map[string]interface{})
+	if !utility.IsNil(opts.RuleID) {
+		filters["ruleid"] = opts.RuleID
+	}
+	if !opts.From.IsZero() || !opts.To.IsZero() {
+		var from, to interface{}
+		if !opts.From.IsZero() {
+			from = opts.From
+		}
+		if !opts.To.IsZero() {
+			to = opts.To
+		}
+		filters["lastupdate"] = database.Client.BetweenQry(from, to)
+	}
+	return filters
+}
+
+//Exporter is an export whose options are validated and whose columns and cursor are ready,
+//nothing is written before Stream so the errors of the setup can still be returned to the client
+type Exporter struct {
+	Options Options
+	columns []string
+	cursor  *models.ResultCursor
+}
+
+//Prepare validates the options, collects the columns and opens the cursor
+func Prepare(opts Options) (*Exporter, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	filters := opts.filters()
+	columns := opts.Fields
+	if len(columns) <= 0 && opts.Format != JSONL {
+		//csv and parquet need the header before the first row, so it takes an extra pass to collect the columns
+		var err error
+		columns, err = collectColumns(opts.TableName, filters)
+		if err != nil {
+			return nil, err
+		}
+	}
+	cursor, err := models.OpenResults(opts.TableName, filters)
+	if err != nil {
+		return nil, err
+	}
+	return &Exporter{Options: opts, columns: columns, cursor: cursor}, nil
+}
+
+//Stream writes the results to the writer in the format of the options and closes the cursor,
+//the row writer is closed even if the cursor fails so its buffered rows are released and the first error is returned
+func (exporter *Exporter) Stream(w io.Writer) (err error) {
+	rw, err := newRowWriter(w, exporter.Options.Format, exporter.columns)
+	if err != nil {
+		exporter.Close()
+		return err
+	}
+	defer func() {
+		if closeErr := rw.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+	return exporter.cursor.Each(func(result models.Result) error {
+		return rw.Write(FlattenResult(result))
+	})
+}
+
+//Close closes the cursor of an export that is not streamed
+func (exporter *Exporter) Close() error {
+	return exporter.cursor.Close()
+}
+
+//Export streams the matching results to the writer in the given format
+func Export(w io.Writer, opts Options) error {
+	exporter, err := Prepare(opts)
+	if err != nil {
+		return err
+	}
+	return exporter.Stream(w)
+}
+
+//collectColumns returns all flattened column names in the table, the meta columns come first
+func collectColumns(tableName string, filters map[string]interface{}) ([]string, error) {
+	metaColumns := []string{"id", "ruleID", "ruleVersion", "lastUpdate"}
+	seen := make(map[string]bool)
+	for _, column := range metaColumns {
+		seen[column] = true
+	}
+	var contentColumns []string
+	err := models.StreamResults(tableName, filters, func(result models.Result) error {
+		for column := range FlattenResult(result) {
+			if !seen[column] {
+				seen[column] = true
+				contentColumns = append(contentColumns, column)
+			}
+		}
+		return nil
+	})
+	sort.Strings(contentColumns)
+	return append(metaColumns, contentColumns...), err
+}
+
+//FlattenResult converts the result to a flat row, nested keys in the content are joined by "."
+func FlattenResult(result models.Result) map[string]string {
+	row := map[string]string{
+		"id":          result.ID,
+		"ruleID":      result.RuleID,
+		"ruleVersion": "",
+		"lastUpdate":  result.LastUpdate.Format(time.RFC3339),
+	}
+	//results scraped before the rules were versioned have no version
+	if result.RuleVersion > 0 {
+		row["ruleVersion"] = strconv.Itoa(result.RuleVersion)
+	}
+	var content interface{}
+	if err := json.Unmarshal([]byte(result.Content), &content); err != nil {
+		//keep the raw content if it is not json
+		row["content"] = result.Content
+		return row
+	}
+	flatten("", content, row)
+	return row
+}
+
+func flatten(prefix string, value interface{}, row map[string]string) {
+	join := func(key string) string {
+		if prefix == "" {
+			return key
+		}
+		return prefix + "." + key
+	}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			flatten(join(key), child, row)
+		}
+	case []interface{}:
+		for index, child := range v {
+			flatten(join(strconv.Itoa(index)), child, row)
+		}
+	case nil:
+		row[prefix] = ""
+	case string:
+		row[prefix] = v
+	default:
+		row[prefix] = fmt.Sprint(v)
+	}
+}
+
+//rowWriter writes flattened rows to the output
+type rowWriter interface {
+	Write(row map[string]string) error
+	Close() error
+}
+
+func newRowWriter(w io.Writer, format string, columns []string) (rowWriter, error) {
+	switch format {
+	case JSONL:
+		return &jsonlWriter{encoder: json.NewEncoder(w), columns: columns}, nil
+	case Parquet:
+		return newParquetWriter(w, columns)
+	default:
+		cw := csv.NewWriter(w)
+		return &csvWriter{writer: cw, columns: columns}, cw.Write(columns)
+	}
+}
+
+type csvWriter struct {
+	writer  *csv.Writer
+	columns []string
+	rows    int
+}
+
+func (cw *csvWriter) Write(row map[string]string) error {
+	record := make([]string, len(cw.columns))
+	for i, column := range cw.columns {
+		record[i] = row[column]
+	}
+	if err := cw.writer.Write(record); err != nil {
+		return err
+	}
+	cw.rows++
+	if cw.rows%1000 == 0 {
+		//flush periodically so the output is streamed
+		cw.writer.Flush()
+		return cw.writer.Error()
+	}
+	return nil
+}
+
+func (cw *csvWriter) Close() error {
+	cw.writer.Flush()
+	return cw.writer.Error()
+}
+
+type jsonlWriter struct {
+	encoder *json.Encoder
+	columns []string
+}
+
+func (jw *jsonlWriter) Write(row map[string]string) error {
+	if len(jw.columns) > 0 {
+		selected := make(map[string]string, len(jw.columns))
+		for _, column := range jw.columns {
+			selected[column] = row[column]
+		}
+		row = selected
+	}
+	return jw.encoder.Encode(row)
+}
+
+func (jw *jsonlWriter) Close() error {
+	return nil
+}
+
+type parquetWriter struct {
+	writer  *writer.JSONWriter
+	columns []string
+	names   []string
+}
+
+//parquetRowGroupSize keeps the buffered row group small so the export does not hold the table in memory
+const parquetRowGroupSize = 8 * 1024 * 1024
+
+func newParquetWriter(w io.Writer, columns []string) (*parquetWriter, error) {
+	type field struct {
+		Tag string
+	}
+	schema := struct {
+		Tag    string
+		Fields []field
+	}{Tag: "name=result, repetitiontype=REQUIRED"}
+	names := parquetNames(columns)
+	for i := range columns {
+		schema.Fields = append(schema.Fields, field{Tag: "name=" + names[i] + ", type=BYTE_ARRAY, convertedtype=UTF8, repetitiontype=OPTIONAL"})
+	}
+	schemaJSON, err := json.Marshal(schema)
+	if err != nil {
+		return nil, err
+	}
+	pw, err := writer.NewJSONWriterFromWriter(string(schemaJSON), w, 1)
+	if err != nil {
+		return nil, err
+	}
+	pw.RowGroupSize = parquetRowGroupSize
+	return &parquetWriter{writer: pw, columns: columns, names: names}, nil
+}
+
+//parquetInvalid matches the characters that can't be in a parquet column name,
+//"." is the path separator of parquet and "," and "=" separate the tags of the schema
+var parquetInvalid = regexp.MustCompile(`[^\p{L}\p{N}_]`)
+
+//parquetNames replaces the invalid characters of the columns by "_" and adds a number to the names that are the same after it,
+//parquet-go capitalises the first letter of the names so they are compared the same way
+func parquetNames(columns []string) []string {
+	names := make([]string, len(columns))
+	used := make(map[string]bool, len(columns))
+	for i, column := range columns {
+		base := parquetInvalid.ReplaceAllString(column, "_")
+		if base == "" {
+			base = "_"
+		}
+		name := base
+		for n := 2; used[common.HeadToUpper(name)]; n++ {
+			name = base + "_" + strconv.Itoa(n)
+		}
+		used[common.HeadToUpper(name)] = true
+		names[i] = name
+	}
+	return names
+}
+
+func (pw *parquetWriter) Write(row map[string]string) error {
+	record := make(map[string]string, len(pw.columns))
+	for i, column := range pw.columns {
+		if value, ok := row[column]; ok {
+			record[pw.names[i]] = value
+		}
+	}
+	recordJSON, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return pw.writer.Write(string(recordJSON))
+}
+
+func (pw *parquetWriter) Close() error {
+	return pw.writer.WriteStop()
+}
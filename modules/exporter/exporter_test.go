@@ -0,0 +1,158 @@
+package exporter
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/sporule/grater/models"
+	"github.com/sporule/grater/modules/database"
+	"github.com/stretchr/testify/assert"
+	"github.com/xitongsys/parquet-go-source/buffer"
+	"github.com/xitongsys/parquet-go/reader"
+)
+
+func TestFlattenResult(t *testing.T) {
+	result := models.Result{
+		ID:          "1",
+		RuleID:      "rule",
+		RuleVersion: 3,
+		Content:     `{"name":{"value":"PS5"},"tags":["a","b"],"price":{"value":499.99},"link":"http://test"}`,
+		LastUpdate:  time.Date(2021, 1, 27, 0, 0, 0, 0, time.UTC),
+	}
+	row := FlattenResult(result)
+	assert.Equal(t, "PS5", row["name.value"], "nested keys should be joined by dot")
+	assert.Equal(t, "b", row["tags.1"], "arrays should be flattened by index")
+	assert.Equal(t, "499.99", row["price.value"], "numbers should be converted to string")
+	assert.Equal(t, "2021-01-27T00:00:00Z", row["lastUpdate"], "meta columns should be included")
+	assert.Equal(t, "3", row["ruleVersion"], "the rule version should be included")
+	raw := FlattenResult(models.Result{ID: "2", Content: "not json"})
+	assert.Equal(t, "not json", raw["content"], "raw content should be kept if it is not json")
+}
+
+func TestCSVWriter(t *testing.T) {
+	var buf bytes.Buffer
+	rw, err := newRowWriter(&buf, CSV, []string{"id", "name.value"})
+	assert.Nil(t, err)
+	assert.Nil(t, rw.Write(map[string]string{"id": "1", "name.value": "PS5", "other": "x"}))
+	assert.Nil(t, rw.Close())
+	assert.Equal(t, "id,name.value\n1,PS5\n", buf.String(), "only the selected columns should be written")
+}
+
+func TestParquetWriter(t *testing.T) {
+	var buf bytes.Buffer
+	rw, err := newRowWriter(&buf, Parquet, []string{"id", "name.value"})
+	assert.Nil(t, err)
+	assert.Nil(t, rw.Write(map[string]string{"id": "1", "name.value": "PS5"}))
+	assert.Nil(t, rw.Write(map[string]string{"id": "2"}))
+	assert.Nil(t, rw.Close())
+
+	file, err := buffer.NewBufferFile(buf.Bytes())
+	assert.Nil(t, err)
+	pr, err := reader.NewParquetReader(file, nil, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, int64(2), pr.GetNumRows(), "all rows should be written")
+	rows, err := pr.ReadByNumber(2)
+	assert.Nil(t, err)
+	rowsJSON, _ := json.Marshal(rows)
+	assert.Equal(t, `[{"Id":"1","Name_value":"PS5"},{"Id":"2","Name_value":null}]`, string(rowsJSON), "values should be readable from the parquet file")
+}
+
+func TestParquetNames(t *testing.T) {
+	assert.Equal(t, []string{"a_b", "a_b_2", "a_b_3", "a_b_2_2"}, parquetNames([]string{"a.b", "a_b", "a,b", "a.b_2"}), "the names should be unique after the replacement")
+	assert.Equal(t, []string{"name", "Name_2", "_"}, parquetNames([]string{"name", "Name", ""}), "the names that are the same after parquet-go capitalises them should be unique")
+}
+
+func TestParquetWriterColumnNames(t *testing.T) {
+	var buf bytes.Buffer
+	columns := []string{"a.b", "a_b", "size=big, type=INT32", "price,value"}
+	rw, err := newRowWriter(&buf, Parquet, columns)
+	assert.Nil(t, err, "the tag characters in the columns should not break the schema")
+	assert.Nil(t, rw.Write(map[string]string{"a.b": "1", "a_b": "2", "size=big, type=INT32": "3", "price,value": "4"}))
+	assert.Nil(t, rw.Close())
+
+	file, err := buffer.NewBufferFile(buf.Bytes())
+	assert.Nil(t, err)
+	pr, err := reader.NewParquetReader(file, nil, 1)
+	assert.Nil(t, err)
+	rows, err := pr.ReadByNumber(1)
+	assert.Nil(t, err)
+	rowsJSON, _ := json.Marshal(rows)
+	assert.Equal(t, `[{"A_b":"1","A_b_2":"2","Size_big__type_INT32":"3","Price_value":"4"}]`, string(rowsJSON), "every column should keep its own value")
+}
+
+//fakeDatabase has one rule whose target location is PS5 and fails the cursor of the other tables
+type fakeDatabase struct {
+	database.Database
+}
+
+func (db fakeDatabase) GetAll(table string, items interface{}, filtersMap map[string]interface{}, sortByMap map[string]interface{}, page int) error {
+	if filtersMap["targetlocation"] == "PS5" {
+		*(items.(*[]models.Rule)) = []models.Rule{{ID: "rule1", TargetLocation: "PS5"}}
+	}
+	return nil
+}
+
+func (db fakeDatabase) GetCursor(table string, filtersMap map[string]interface{}, sortByMap map[string]interface{}) (database.Cursor, error) {
+	return nil, errors.New("cursor failed")
+}
+
+func TestPrepare(t *testing.T) {
+	previous := database.Client
+	database.Client = fakeDatabase{}
+	defer func() { database.Client = previous }()
+
+	for _, table := range []string{"user", "apikey"} {
+		_, err := Prepare(Options{TableName: table, Format: CSV})
+		assert.NotNil(t, err, "only the target locations of the rules can be exported")
+	}
+	_, err := Prepare(Options{TableName: "PS5", Format: JSONL})
+	assert.EqualError(t, err, "cursor failed", "the cursor should be opened before anything is written")
+}
+
+//brokenCursor returns one result and then fails like a lost connection
+type brokenCursor struct {
+	read bool
+}
+
+func (cursor *brokenCursor) Next() bool {
+	next := !cursor.read
+	cursor.read = true
+	return next
+}
+
+func (cursor *brokenCursor) Decode(item interface{}) error {
+	*(item.(*models.Result)) = models.Result{ID: "result1", Content: `{"name":"PS5"}`}
+	return nil
+}
+
+func (cursor *brokenCursor) Err() error {
+	return errors.New("connection lost")
+}
+
+func (cursor *brokenCursor) Close() error {
+	return nil
+}
+
+//brokenDatabase has the rule of PS5 and its cursor fails after the first result
+type brokenDatabase struct {
+	fakeDatabase
+}
+
+func (db brokenDatabase) GetCursor(table string, filtersMap map[string]interface{}, sortByMap map[string]interface{}) (database.Cursor, error) {
+	return &brokenCursor{}, nil
+}
+
+func TestStreamClosesWriterOnError(t *testing.T) {
+	previous := database.Client
+	database.Client = brokenDatabase{}
+	defer func() { database.Client = previous }()
+
+	exporter, err := Prepare(Options{TableName: "PS5", Format: CSV, Fields: []string{"id"}})
+	assert.Nil(t, err)
+	var buf bytes.Buffer
+	assert.EqualError(t, exporter.Stream(&buf), "connection lost", "the error of the cursor should be returned")
+	assert.Equal(t, "id\nresult1\n", buf.String(), "the writer should be closed so its buffered rows are flushed")
+}
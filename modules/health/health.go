@@ -0,0 +1,159 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+//Check returns an error if the dependency is not healthy, it should return when the context is done
+type Check func(ctx context.Context) error
+
+type registeredCheck struct {
+	name     string
+	liveness bool
+	check    Check
+}
+
+var (
+	checks      []registeredCheck
+	checksMutex sync.RWMutex
+	beats       = make(map[string]time.Time)
+	beatsMutex  sync.RWMutex
+)
+
+//Register adds the check to /readyz, liveness checks are added to /healthz as well, a check with the same name is replaced
+func Register(name string, liveness bool, check Check) {
+	checksMutex.Lock()
+	defer checksMutex.Unlock()
+	for i := range checks {
+		if checks[i].name == name {
+			checks[i] = registeredCheck{name: name, liveness: liveness, check: check}
+			return
+		}
+	}
+	checks = append(checks, registeredCheck{name: name, liveness: liveness, check: check})
+}
+
+//Beat records the progress of the named loop
+func Beat(name string) {
+	beatsMutex.Lock()
+	beats[name] = time.Now()
+	beatsMutex.Unlock()
+}
+
+//Heartbeat returns a check that fails if the named loop has not called Beat within maxAge, the time of this call counts as the first beat
+func Heartbeat(name string, maxAge time.Duration) Check {
+	since := time.Now()
+	return func(ctx context.Context) error {
+		beatsMutex.RLock()
+		last, ok := beats[name]
+		beatsMutex.RUnlock()
+		if !ok {
+			last = since
+		}
+		if idle := time.Since(last); idle > maxAge {
+			return errors.New("No progress for " + idle.Round(time.Second).String())
+		}
+		return nil
+	}
+}
+
+//Result is the result of one check
+type Result struct {
+	Status          string  `json:"status"`
+	Error           string  `json:"error,omitempty"`
+	DurationSeconds float64 `json:"durationSeconds"`
+}
+
+//Report is the result of all checks, the status is ok only if every check is ok
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks"`
+}
+
+//Run runs the checks in parallel, each check is given the timeout
+func Run(livenessOnly bool, timeout time.Duration) Report {
+	checksMutex.RLock()
+	selected := make([]registeredCheck, 0, len(checks))
+	for _, check := range checks {
+		if check.liveness || !livenessOnly {
+			selected = append(selected, check)
+		}
+	}
+	checksMutex.RUnlock()
+
+	report := Report{Status: "ok", Checks: make(map[string]Result, len(selected))}
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	for _, check := range selected {
+		wg.Add(1)
+		go func(check registeredCheck) {
+			defer wg.Done()
+			result := runCheck(check.check, timeout)
+			mutex.Lock()
+			report.Checks[check.name] = result
+			if result.Status != "ok" {
+				report.Status = "fail"
+			}
+			mutex.Unlock()
+		}(check)
+	}
+	wg.Wait()
+	return report
+}
+
+//runCheck runs the check and gives up once the timeout is reached even if the check does not respect the context
+func runCheck(check Check, timeout time.Duration) Result {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		done <- check(ctx)
+	}()
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = errors.New("Check timed out after " + timeout.String())
+	}
+	result := Result{Status: "ok", DurationSeconds: time.Since(start).Seconds()}
+	if err != nil {
+		result.Status = "fail"
+		result.Error = err.Error()
+	}
+	return result
+}
+
+//timeout is the timeout of each check
+var timeout = 2 * time.Second
+
+//SetTimeout changes the timeout of each check
+func SetTimeout(checkTimeout time.Duration) {
+	if checkTimeout > 0 {
+		timeout = checkTimeout
+	}
+}
+
+//LivenessController serves /healthz, it only runs the liveness checks
+func LivenessController(c *gin.Context) {
+	respond(c, Run(true, timeout))
+}
+
+//ReadinessController serves /readyz, it runs all checks
+func ReadinessController(c *gin.Context) {
+	respond(c, Run(false, timeout))
+}
+
+func respond(c *gin.Context, report Report) {
+	status := http.StatusOK
+	if report.Status != "ok" {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, report)
+}
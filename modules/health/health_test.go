@@ -0,0 +1,63 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHeartbeat(t *testing.T) {
+	check := Heartbeat("testLoop", 50*time.Millisecond)
+	assert.Nil(t, check(context.Background()), "the registration counts as the first beat")
+	time.Sleep(60 * time.Millisecond)
+	assert.NotNil(t, check(context.Background()), "the loop has not made progress")
+	Beat("testLoop")
+	assert.Nil(t, check(context.Background()))
+}
+
+func TestRun(t *testing.T) {
+	checks = nil
+	Register("live", true, func(ctx context.Context) error { return nil })
+	Register("database", false, func(ctx context.Context) error { return errors.New("unreachable") })
+	Register("slow", false, func(ctx context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	})
+
+	report := Run(true, 100*time.Millisecond)
+	assert.Equal(t, "ok", report.Status)
+	assert.Equal(t, 1, len(report.Checks), "liveness only runs the liveness checks")
+
+	report = Run(false, 100*time.Millisecond)
+	assert.Equal(t, "fail", report.Status)
+	assert.Equal(t, "ok", report.Checks["live"].Status)
+	assert.Equal(t, "unreachable", report.Checks["database"].Error)
+	assert.Equal(t, "fail", report.Checks["slow"].Status, "the check should time out")
+}
+
+func TestControllers(t *testing.T) {
+	checks = nil
+	Register("database", false, func(ctx context.Context) error { return errors.New("unreachable") })
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/healthz", LivenessController)
+	router.GET("/readyz", ReadinessController)
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	var report Report
+	assert.Nil(t, json.Unmarshal(w.Body.Bytes(), &report))
+	assert.Equal(t, "fail", report.Checks["database"].Status)
+}
@@ -0,0 +1,210 @@
+package httpcache
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/sporule/grater/models"
+)
+
+//Header is set on the responses of the transport, it is Hit if the page is unchanged and the body is from the cache, otherwise Miss
+const Header = "X-Grater-Cache"
+
+//Hit and Miss are the values of Header
+const (
+	Hit  = "hit"
+	Miss = "miss"
+)
+
+//cache types of the scraper config
+const (
+	Disk     = "disk"
+	Database = "database"
+)
+
+//Store keeps the last full response of every link
+type Store interface {
+	//Get returns the entry of the link, it is nil if the link is not cached
+	Get(link string) (*models.HTTPCacheEntry, error)
+	Put(entry *models.HTTPCacheEntry) error
+}
+
+//New returns the store of the cache type, it is nil if the cache is off
+func New(cacheType, dir string) (Store, error) {
+	switch cacheType {
+	case "":
+		return nil, nil
+	case Disk:
+		store, err := NewDiskStore(dir)
+		if err != nil {
+			return nil, err
+		}
+		return store, nil
+	case Database:
+		return DatabaseStore{}, nil
+	}
+	return nil, errors.New("unknown cache type: " + cacheType)
+}
+
+//DatabaseStore keeps the entries in the database so every scraper shares them
+type DatabaseStore struct{}
+
+//Get returns the entry of the link from the database
+func (DatabaseStore) Get(link string) (*models.HTTPCacheEntry, error) {
+	return models.GetHTTPCacheEntry(link)
+}
+
+//Put saves the entry to the database
+func (DatabaseStore) Put(entry *models.HTTPCacheEntry) error {
+	return entry.Upsert()
+}
+
+//DiskStore keeps the entries as json files in the directory, the name of the file is the sha256 of the link
+type DiskStore struct {
+	dir string
+}
+
+//NewDiskStore creates the directory if it doesn't exist
+func NewDiskStore(dir string) (*DiskStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &DiskStore{dir: dir}, nil
+}
+
+func (store *DiskStore) path(link string) string {
+	hash := sha256.Sum256([]byte(link))
+	return filepath.Join(store.dir, hex.EncodeToString(hash[:])+".json")
+}
+
+//Get reads the entry of the link
+func (store *DiskStore) Get(link string) (*models.HTTPCacheEntry, error) {
+	content, err := ioutil.ReadFile(store.path(link))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var entry models.HTTPCacheEntry
+	return &entry, json.Unmarshal(content, &entry)
+}
+
+//Put writes the entry to a temporary file and renames it so a reader never sees half a file
+func (store *DiskStore) Put(entry *models.HTTPCacheEntry) error {
+	content, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	file, err := ioutil.TempFile(store.dir, "entry-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	if _, err := file.Write(content); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), store.path(entry.ID))
+}
+
+//Transport sends conditional requests with the ETag and Last-Modified of the cached response,
+//a 304 is answered with the cached body as a 200 so the scraper parses it like any other page
+type Transport struct {
+	Store Store
+	Next  http.RoundTripper
+	//OnError is called when the cache can't be read or written, the request is sent without the cache
+	OnError func(link string, err error)
+}
+
+//RoundTrip implements http.RoundTripper
+func (transport *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet {
+		return transport.Next.RoundTrip(req)
+	}
+	link := req.URL.String()
+	entry, err := transport.Store.Get(link)
+	if err != nil {
+		transport.onError(link, err)
+		entry = nil
+	}
+	original := req
+	if entry != nil {
+		//the request must not be changed so the headers are set on a copy
+		req = req.Clone(req.Context())
+		if entry.ETag != "" {
+			req.Header.Set("If-None-Match", entry.ETag)
+		}
+		if entry.LastModified != "" {
+			req.Header.Set("If-Modified-Since", entry.LastModified)
+		}
+	}
+	res, err := transport.Next.RoundTrip(req)
+	if req != original {
+		//the next transport can add values to the context of the copy, like the proxy colly reads, so they are kept on the request of the caller
+		*original = *original.WithContext(req.Context())
+	}
+	if err != nil {
+		return res, err
+	}
+	if res.StatusCode == http.StatusNotModified && entry != nil {
+		body, err := entry.Body()
+		if err == nil {
+			res.Body.Close()
+			header := res.Header.Clone()
+			header.Set("Content-Type", entry.ContentType)
+			header.Set(Header, Hit)
+			header.Del("Content-Length")
+			return &http.Response{
+				Status:        strconv.Itoa(http.StatusOK) + " " + http.StatusText(http.StatusOK),
+				StatusCode:    http.StatusOK,
+				Proto:         res.Proto,
+				ProtoMajor:    res.ProtoMajor,
+				ProtoMinor:    res.ProtoMinor,
+				Header:        header,
+				Body:          ioutil.NopCloser(bytes.NewReader(body)),
+				ContentLength: int64(len(body)),
+				Request:       req,
+			}, nil
+		}
+		transport.onError(link, err)
+		return res, nil
+	}
+	res.Header.Set(Header, Miss)
+	etag, lastModified := res.Header.Get("ETag"), res.Header.Get("Last-Modified")
+	if res.StatusCode != http.StatusOK || (etag == "" && lastModified == "") {
+		//the page can't be requested conditionally
+		return res, nil
+	}
+	body, err := ioutil.ReadAll(res.Body)
+	res.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	res.Body = ioutil.NopCloser(bytes.NewReader(body))
+	entry, err = models.NewHTTPCacheEntry(link, etag, lastModified, res.Header.Get("Content-Type"), body)
+	if err == nil {
+		err = transport.Store.Put(entry)
+	}
+	if err != nil {
+		transport.onError(link, err)
+	}
+	return res, nil
+}
+
+func (transport *Transport) onError(link string, err error) {
+	if transport.OnError != nil {
+		transport.OnError(link, err)
+	}
+}
@@ -0,0 +1,98 @@
+package httpcache
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTransport(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path == "/nocache" {
+			w.Write([]byte("no validators"))
+			return
+		}
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write([]byte("<html><body>PS5</body></html>"))
+	}))
+	defer server.Close()
+	store, err := New(Disk, t.TempDir())
+	assert.Nil(t, err)
+	client := &http.Client{Transport: &Transport{Store: store, Next: http.DefaultTransport, OnError: func(link string, err error) {
+		t.Errorf("cache failed for %s: %v", link, err)
+	}}}
+
+	get := func(path string) (*http.Response, string) {
+		res, err := client.Get(server.URL + path)
+		assert.Nil(t, err)
+		defer res.Body.Close()
+		body, err := ioutil.ReadAll(res.Body)
+		assert.Nil(t, err)
+		return res, string(body)
+	}
+	res, body := get("/page")
+	assert.Equal(t, Miss, res.Header.Get(Header))
+	assert.Equal(t, "<html><body>PS5</body></html>", body)
+
+	res, body = get("/page")
+	assert.Equal(t, http.StatusOK, res.StatusCode, "a 304 should be answered with the cached page")
+	assert.Equal(t, Hit, res.Header.Get(Header))
+	assert.Equal(t, "text/html; charset=utf-8", res.Header.Get("Content-Type"))
+	assert.Equal(t, "<html><body>PS5</body></html>", body)
+	assert.Equal(t, 2, requests, "the unchanged page should still be requested conditionally")
+
+	get("/nocache")
+	entry, err := store.Get(server.URL + "/nocache")
+	assert.Nil(t, err)
+	assert.Nil(t, entry, "a page without ETag or Last-Modified can't be cached")
+	res, _ = get("/nocache")
+	assert.Equal(t, Miss, res.Header.Get(Header))
+
+	_, err = New("memory", "")
+	assert.NotNil(t, err)
+	store, err = New("", "")
+	assert.Nil(t, err)
+	assert.Nil(t, store, "the cache should be off by default")
+}
+
+type contextKey string
+
+//contextTransport writes a value into the context of the request like the proxy func of the scraper
+type contextTransport struct{}
+
+func (contextTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	*req = *req.WithContext(context.WithValue(req.Context(), contextKey("proxy"), req.Header.Get("If-None-Match")))
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestTransportKeepsContext(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte("PS5"))
+	}))
+	defer server.Close()
+	store, err := New(Disk, t.TempDir())
+	assert.Nil(t, err)
+	transport := &Transport{Store: store, Next: contextTransport{}}
+
+	for _, etag := range []string{"", `"v1"`} {
+		req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+		res, err := transport.RoundTrip(req)
+		assert.Nil(t, err)
+		ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		assert.Equal(t, etag, req.Context().Value(contextKey("proxy")), "the context written by the next transport should reach the request of the caller")
+		assert.Empty(t, req.Header.Get("If-None-Match"), "the conditional headers should only be set on the copy")
+	}
+}
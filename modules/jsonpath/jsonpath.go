@@ -0,0 +1,102 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+//split returns the keys of the path
+func split(path string) []string {
+	path = strings.TrimPrefix(strings.TrimPrefix(path, "$"), ".")
+	if path == "" || path == "@this" {
+		return nil
+	}
+	var keys []string
+	var key strings.Builder
+	for i := 0; i < len(path); i++ {
+		switch {
+		case path[i] == '\\' && i+1 < len(path):
+			i++
+			key.WriteByte(path[i])
+		case path[i] == '.':
+			keys = append(keys, key.String())
+			key.Reset()
+		default:
+			key.WriteByte(path[i])
+		}
+	}
+	return append(keys, key.String())
+}
+
+//Validate checks the path has no empty key
+func Validate(path string) error {
+	if strings.TrimSpace(path) == "" {
+		return errors.New("required")
+	}
+	for _, key := range split(path) {
+		if key == "" {
+			return errors.New("it should not have an empty key, use \\. for a dot in a key")
+		}
+	}
+	return nil
+}
+
+//Get returns the value at the path of the decoded json, false if the path doesn't exist.
+//The path is keys separated by dots in the gjson style, e.g. items.0.price, a number selects the item of an array
+//and # maps the rest of the path over every item of an array or returns the length if it is the last key,
+//@this or an empty path is the value itself, the JSONPath prefix $. is accepted and \. escapes a dot in a key
+func Get(value interface{}, path string) (interface{}, bool) {
+	return get(value, split(path))
+}
+
+func get(value interface{}, keys []string) (interface{}, bool) {
+	for i, key := range keys {
+		switch current := value.(type) {
+		case map[string]interface{}:
+			child, ok := current[key]
+			if !ok {
+				return nil, false
+			}
+			value = child
+		case []interface{}:
+			if key == "#" {
+				if i == len(keys)-1 {
+					return float64(len(current)), true
+				}
+				values := make([]interface{}, 0, len(current))
+				for _, item := range current {
+					if child, ok := get(item, keys[i+1:]); ok {
+						values = append(values, child)
+					}
+				}
+				return values, true
+			}
+			index, err := strconv.Atoi(key)
+			if err != nil || index < 0 || index >= len(current) {
+				return nil, false
+			}
+			value = current[index]
+		default:
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+//String returns strings as they are, numbers and booleans in their json format, objects and arrays as json and null as empty string
+func String(value interface{}) string {
+	switch value := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(value)
+	}
+	content, _ := json.Marshal(value)
+	return string(content)
+}
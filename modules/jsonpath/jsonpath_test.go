@@ -0,0 +1,42 @@
+package jsonpath
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGet(t *testing.T) {
+	var data interface{}
+	assert.Nil(t, json.Unmarshal([]byte(`{"items":[{"name":"PS5","price":499.99,"tags":["new"]},{"name":"Xbox","price":449}],"next":null,"a.b":true}`), &data))
+	value, ok := Get(data, "items.0.name")
+	assert.True(t, ok)
+	assert.Equal(t, "PS5", value)
+	value, _ = Get(data, "$.items.1.price")
+	assert.Equal(t, "449", String(value), "the JSONPath prefix should be accepted")
+	value, _ = Get(data, "items.#.name")
+	assert.Equal(t, []interface{}{"PS5", "Xbox"}, value)
+	value, _ = Get(data, "items.#")
+	assert.Equal(t, "2", String(value))
+	value, _ = Get(data, `a\.b`)
+	assert.Equal(t, "true", String(value))
+	value, _ = Get(data, "items.0.tags")
+	assert.Equal(t, `["new"]`, String(value))
+	value, ok = Get(data, "next")
+	assert.True(t, ok, "null should be found")
+	assert.Equal(t, "", String(value))
+	value, _ = Get("PS5", "@this")
+	assert.Equal(t, "PS5", value)
+	for _, path := range []string{"items.2.name", "items.x", "missing", "items.0.name.first"} {
+		_, ok = Get(data, path)
+		assert.False(t, ok, path)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	assert.Nil(t, Validate("items.#.name"))
+	assert.Nil(t, Validate("@this"))
+	assert.NotNil(t, Validate(""))
+	assert.NotNil(t, Validate("items..name"))
+}
@@ -0,0 +1,45 @@
+package logger
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/sporule/grater/modules/utility"
+)
+
+//contextKey is where the request logger stored in the gin context
+const contextKey = "logger"
+
+//Middleware logs every request and attaches a request logger to the context
+func Middleware(base *Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		id, _ := uuid.NewRandom()
+		requestLogger := base.With("requestID", id.String(), "method", c.Request.Method, "route", c.FullPath())
+		c.Set(contextKey, requestLogger)
+		c.Next()
+		lineLevel := InfoLevel
+		if c.Writer.Status() >= 500 {
+			lineLevel = ErrorLevel
+		}
+		requestLogger.write(lineLevel, "Request completed", []interface{}{
+			"path", c.Request.URL.Path,
+			"status", c.Writer.Status(),
+			"durationSeconds", time.Since(start).Seconds(),
+			"client", c.ClientIP(),
+			"userID", c.GetString(utility.Enums().Others.UserID),
+		})
+	}
+}
+
+//FromContext returns the request logger, it falls back to a new logger if the middleware is not used
+func FromContext(c *gin.Context) *Logger {
+	if value, ok := c.Get(contextKey); ok {
+		if requestLogger, ok := value.(*Logger); ok {
+			return requestLogger
+		}
+	}
+	return New()
+}
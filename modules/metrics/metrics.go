@@ -0,0 +1,151 @@
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/sporule/grater/models"
+	"github.com/sporule/grater/modules/logger"
+)
+
+var (
+	//ScraperRequests counts the requests sent by scrapers by status code and domain, status is 0 if there is no response
+	ScraperRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "grater_scraper_requests_total",
+		Help: "Requests sent by scrapers by status code and domain.",
+	}, []string{"status", "domain"})
+
+	//ScraperRequestDuration observes the response time of the scraper requests by domain
+	ScraperRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "grater_scraper_request_duration_seconds",
+		Help:    "Response time of the scraper requests by domain.",
+		Buckets: prometheus.ExponentialBuckets(0.1, 2, 10),
+	}, []string{"domain"})
+
+	//RecordsScraped counts the scraped records by rule
+	RecordsScraped = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "grater_scraper_records_total",
+		Help: "Records scraped by rule.",
+	}, []string{"rule"})
+
+	//ValidationFailures counts the pages that failed on the pattern validation by rule
+	ValidationFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "grater_scraper_validation_failures_total",
+		Help: "Pages that failed on the pattern validation by rule.",
+	}, []string{"rule"})
+
+	//LayoutErrors counts the pages that don't match the pattern by rule
+	LayoutErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "grater_scraper_layout_errors_total",
+		Help: "Pages that don't match the pattern by rule.",
+	}, []string{"rule"})
+
+	//CacheRequests counts the cached requests by rule, result is hit if the page is unchanged and miss if it is downloaded
+	CacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "grater_scraper_cache_requests_total",
+		Help: "Cached requests by rule and result.",
+	}, []string{"rule", "result"})
+
+	//ProxyPoolSize is the number of usable proxies by rule
+	ProxyPoolSize = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "grater_scraper_proxy_pool_size",
+		Help: "Usable proxies by rule.",
+	}, []string{"rule"})
+
+	//ProxyBans counts the proxies dropped after failed requests by rule, the ban rate is the rate of this counter
+	ProxyBans = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "grater_scraper_proxy_bans_total",
+		Help: "Proxies dropped after failed requests by rule.",
+	}, []string{"rule"})
+
+	//QueueDepth is the number of links waiting in the scraper queue by rule
+	QueueDepth = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "grater_scraper_queue_depth",
+		Help: "Links waiting in the scraper queue by rule.",
+	}, []string{"rule"})
+
+	//LinkAllocationDuration observes how long the distributor takes to allocate links
+	LinkAllocationDuration = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "grater_dist_link_allocation_duration_seconds",
+		Help:    "Time taken by the distributor to allocate links.",
+		Buckets: prometheus.DefBuckets,
+	})
+
+	//APIRequests counts the api requests by method, route and status code
+	APIRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "grater_api_requests_total",
+		Help: "Api requests by method, route and status code.",
+	}, []string{"method", "route", "status"})
+
+	//APIRequestDuration observes the api response time by method and route
+	APIRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "grater_api_request_duration_seconds",
+		Help:    "Api response time by method and route.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route"})
+)
+
+//linksDesc describes the links gauge that is read from the database on every scrape
+var linksDesc = prometheus.NewDesc("grater_dist_links", "Links by rule and status.", []string{"rule", "status"}, nil)
+
+//linksCollector reports the number of links by rule and status from the database
+type linksCollector struct{}
+
+//Describe implements prometheus.Collector
+func (collector linksCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- linksDesc
+}
+
+//Collect implements prometheus.Collector
+func (collector linksCollector) Collect(ch chan<- prometheus.Metric) {
+	counts, err := models.CountLinks(nil)
+	if err != nil {
+		logger.New("component", "metrics").Error("Unable to count links for metrics", "error", err)
+		return
+	}
+	ruleNames := make(map[string]string)
+	if rules, err := models.GetRules(nil, 0); err == nil {
+		for _, rule := range rules {
+			ruleNames[rule.ID] = rule.Name
+		}
+	}
+	for _, count := range counts {
+		rule, ok := ruleNames[count.RuleID]
+		if !ok {
+			rule = count.RuleID
+		}
+		ch <- prometheus.MustNewConstMetric(linksDesc, prometheus.GaugeValue, float64(count.Count), rule, count.Status)
+	}
+}
+
+//RegisterDistributorMetrics registers the metrics that are read from the database, it should only be called on distributor nodes
+func RegisterDistributorMetrics() {
+	if err := prometheus.Register(linksCollector{}); err != nil {
+		logger.New("component", "metrics").Error("Unable to register links metrics", "error", err)
+	}
+}
+
+//Handler serves the metrics in prometheus format
+func Handler() gin.HandlerFunc {
+	return gin.WrapH(promhttp.Handler())
+}
+
+//Middleware records the api requests
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		route := c.FullPath()
+		if route == "" {
+			//keep the cardinality low for unknown routes
+			route = "unknown"
+		}
+		APIRequests.WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).Inc()
+		APIRequestDuration.WithLabelValues(c.Request.Method, route).Observe(time.Since(start).Seconds())
+	}
+}
@@ -0,0 +1,60 @@
+package metrics
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sporule/grater/models"
+	"github.com/sporule/grater/modules/database"
+	"github.com/sporule/grater/modules/logger"
+)
+
+//fakeDatabase has one named rule and the link counts of two rules, the counts fail if err is set
+type fakeDatabase struct {
+	database.Database
+	err error
+}
+
+func (db fakeDatabase) CountGroups(table string, filtersMap map[string]interface{}, groupByFields []string) ([]map[string]interface{}, error) {
+	if db.err != nil {
+		return nil, db.err
+	}
+	return []map[string]interface{}{
+		{"ruleid": "rule1", "status": "Active", "count": int64(3)},
+		{"ruleid": "rule1", "status": "Completed", "count": int64(5)},
+		{"ruleid": "rule2", "status": "Failed", "count": int64(1)},
+	}, nil
+}
+
+func (db fakeDatabase) GetAll(table string, items interface{}, filtersMap map[string]interface{}, sortByMap map[string]interface{}, page int) error {
+	*(items.(*[]models.Rule)) = []models.Rule{{ID: "rule1", Name: "PS5"}}
+	return nil
+}
+
+func TestLinksCollector(t *testing.T) {
+	previous := database.Client
+	defer func() { database.Client = previous }()
+
+	database.Client = fakeDatabase{}
+	expected := `
+# HELP grater_dist_links Links by rule and status.
+# TYPE grater_dist_links gauge
+grater_dist_links{rule="PS5",status="Active"} 3
+grater_dist_links{rule="PS5",status="Completed"} 5
+grater_dist_links{rule="rule2",status="Failed"} 1
+`
+	assert.Nil(t, testutil.CollectAndCompare(linksCollector{}, strings.NewReader(expected)), "rules without a name should be labelled by their id")
+
+	var buf bytes.Buffer
+	logger.SetOutput(&buf)
+	defer logger.SetOutput(os.Stderr)
+	database.Client = fakeDatabase{err: errors.New("database is down")}
+	assert.Equal(t, 0, testutil.CollectAndCount(linksCollector{}), "no links should be reported if they can't be counted")
+	assert.Equal(t, true, strings.Contains(buf.String(), "database is down"))
+}
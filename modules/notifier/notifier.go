@@ -0,0 +1,343 @@
+package notifier
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"mime"
+	"net/http"
+	"net/smtp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sporule/grater/modules/config"
+	"github.com/sporule/grater/modules/logger"
+	"github.com/sporule/grater/modules/utility"
+)
+
+//Severities of the notification
+const (
+	Info     = "info"
+	Warning  = "warning"
+	Critical = "critical"
+)
+
+//Notification is the message sent to every channel
+type Notification struct {
+	//Key identifies what the notification is about, e.g. layout-drift:<rule id>
+	Key      string            `json:"key"`
+	Title    string            `json:"title"`
+	Message  string            `json:"message"`
+	Severity string            `json:"severity"`
+	Fields   map[string]string `json:"fields,omitempty"`
+	Resolved bool              `json:"resolved"`
+	Time     time.Time         `json:"time"`
+}
+
+//heading is the severity and the title, e.g. [WARNING] Layout drift
+func (notification Notification) heading() string {
+	if notification.Resolved {
+		return "[RESOLVED] " + notification.Title
+	}
+	return "[" + strings.ToUpper(notification.Severity) + "] " + notification.Title
+}
+
+//Channel sends notifications to one destination
+type Channel interface {
+	Name() string
+	Send(notification Notification) error
+}
+
+//Webhook posts the notification as json to the url
+type Webhook struct {
+	URL        string
+	HTTPClient *http.Client
+}
+
+//Name implements Channel
+func (webhook *Webhook) Name() string {
+	return "webhook"
+}
+
+//Send implements Channel
+func (webhook *Webhook) Send(notification Notification) error {
+	return postJSON(webhook.HTTPClient, webhook.URL, notification)
+}
+
+//postJSON posts the body and fails on non 2xx status
+func postJSON(client *http.Client, url string, body interface{}) error {
+	content, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	res, err := client.Post(url, "application/json", bytes.NewBuffer(content))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return errors.New("Notification endpoint returned status " + strconv.Itoa(res.StatusCode))
+	}
+	return nil
+}
+
+//Slack posts the notification to a Slack compatible incoming webhook
+type Slack struct {
+	URL        string
+	HTTPClient *http.Client
+}
+
+//Name implements Channel
+func (slack *Slack) Name() string {
+	return "slack"
+}
+
+//Send implements Channel
+func (slack *Slack) Send(notification Notification) error {
+	colors := map[string]string{Info: "#2196f3", Warning: "#ff9800", Critical: "#f44336"}
+	color := colors[notification.Severity]
+	if notification.Resolved {
+		color = "#4caf50"
+	}
+	type field struct {
+		Title string `json:"title"`
+		Value string `json:"value"`
+		Short bool   `json:"short"`
+	}
+	fields := make([]field, 0, len(notification.Fields))
+	for _, key := range sortedKeys(notification.Fields) {
+		fields = append(fields, field{Title: key, Value: notification.Fields[key], Short: true})
+	}
+	payload := map[string]interface{}{
+		"text": "*" + notification.heading() + "*",
+		"attachments": []map[string]interface{}{{
+			"color":  color,
+			"text":   notification.Message,
+			"fields": fields,
+			"ts":     notification.Time.Unix(),
+		}},
+	}
+	return postJSON(slack.HTTPClient, slack.URL, payload)
+}
+
+//SMTP sends the notification as a plain text email, authentication is skipped if the username is empty
+type SMTP struct {
+	Addr     string
+	From     string
+	To       []string
+	Username string
+	Password string
+}
+
+//Name implements Channel
+func (mail *SMTP) Name() string {
+	return "smtp"
+}
+
+//Send implements Channel
+func (mail *SMTP) Send(notification Notification) error {
+	var auth smtp.Auth
+	if !utility.IsNil(mail.Username) {
+		host := strings.Split(mail.Addr, ":")[0]
+		auth = smtp.PlainAuth("", mail.Username, mail.Password, host)
+	}
+	return smtp.SendMail(mail.Addr, auth, mail.From, mail.To, []byte(mail.message(notification)))
+}
+
+func (mail *SMTP) message(notification Notification) string {
+	var body strings.Builder
+	body.WriteString("From: " + mail.From + "\r\n")
+	body.WriteString("To: " + strings.Join(mail.To, ", ") + "\r\n")
+	//the title can have the name of a rule, it is encoded so a line break can't add a header
+	body.WriteString("Subject: " + mime.QEncoding.Encode("utf-8", "Grater "+notification.heading()) + "\r\n")
+	body.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	body.WriteString(notification.Message + "\r\n")
+	for _, key := range sortedKeys(notification.Fields) {
+		body.WriteString("\r\n" + key + ": " + notification.Fields[key])
+	}
+	body.WriteString("\r\n")
+	return body.String()
+}
+
+func sortedKeys(fields map[string]string) []string {
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+//ChannelsFromConfig returns the channels that have an address in the config
+func ChannelsFromConfig(config config.Notify) []Channel {
+	var channels []Channel
+	if !utility.IsNil(config.WebhookURL) {
+		channels = append(channels, &Webhook{URL: config.WebhookURL})
+	}
+	if !utility.IsNil(config.SlackURL) {
+		channels = append(channels, &Slack{URL: config.SlackURL})
+	}
+	if !utility.IsNil(config.SMTPAddr) {
+		channels = append(channels, &SMTP{
+			Addr:     config.SMTPAddr,
+			From:     config.SMTPFrom,
+			To:       config.SMTPTo,
+			Username: config.SMTPUsername,
+			Password: config.SMTPPassword,
+		})
+	}
+	return channels
+}
+
+//Store keeps the firing alerts so they survive a restart, the alerts are only kept in memory without a store
+type Store interface {
+	Active() ([]Notification, error)
+	Save(notification Notification) error
+	Delete(key string) error
+}
+
+//Notifier sends notifications to all of its channels, alerts fired by key are only sent again after they are resolved or the repeat interval passed
+type Notifier struct {
+	Channels []Channel
+	//RepeatInterval resends an alert that is still firing, zero never resends
+	RepeatInterval time.Duration
+	Store          Store
+	active         map[string]Notification
+	loaded         bool
+	activeMutex    sync.Mutex
+	log            *logger.Logger
+}
+
+//New creates a notifier with the channels, a firing alert is sent again every 24 hours
+func New(channels ...Channel) *Notifier {
+	return &Notifier{
+		Channels:       channels,
+		RepeatInterval: 24 * time.Hour,
+		active:         make(map[string]Notification),
+		log:            logger.New("component", "notifier"),
+	}
+}
+
+//FromConfig creates a notifier with the channels and the repeat interval in the config, the firing alerts are kept in the database
+func FromConfig(config config.Notify) *Notifier {
+	notifier := New(ChannelsFromConfig(config)...)
+	notifier.RepeatInterval = time.Duration(config.Repeat) * time.Hour
+	notifier.Store = DatabaseStore{}
+	return notifier
+}
+
+//load reads the firing alerts of the store once, it is tried again on the next call if it fails, the caller holds the mutex
+func (notifier *Notifier) load() {
+	if notifier.loaded || notifier.Store == nil {
+		return
+	}
+	notifications, err := notifier.Store.Active()
+	if err != nil {
+		notifier.log.Error("Unable to load the firing alerts", "error", err)
+		return
+	}
+	for _, notification := range notifications {
+		//the alerts fired since the start are newer
+		if _, ok := notifier.active[notification.Key]; !ok {
+			notifier.active[notification.Key] = notification
+		}
+	}
+	notifier.loaded = true
+}
+
+//Fire sends the alert unless an alert with the same key is already firing,
+//the alert is only firing once a channel sent it so it is tried again on the next call if every channel failed
+func (notifier *Notifier) Fire(notification Notification) error {
+	notifier.activeMutex.Lock()
+	notifier.load()
+	previous, firing := notifier.active[notification.Key]
+	notifier.activeMutex.Unlock()
+	if firing && (notifier.RepeatInterval <= 0 || time.Since(previous.Time) < notifier.RepeatInterval) {
+		return nil
+	}
+	notification.Time = time.Now()
+	sent, err := notifier.send(notification)
+	if !sent {
+		return err
+	}
+	notifier.activeMutex.Lock()
+	notifier.active[notification.Key] = notification
+	if notifier.Store != nil {
+		if saveErr := notifier.Store.Save(notification); saveErr != nil {
+			notifier.log.Error("Unable to save the firing alert", "key", notification.Key, "error", saveErr)
+		}
+	}
+	notifier.activeMutex.Unlock()
+	return err
+}
+
+//Resolve sends the resolved notification if the alert with the key is firing
+func (notifier *Notifier) Resolve(key, message string) error {
+	notifier.activeMutex.Lock()
+	notifier.load()
+	notification, firing := notifier.active[key]
+	delete(notifier.active, key)
+	if firing && notifier.Store != nil {
+		if err := notifier.Store.Delete(key); err != nil {
+			notifier.log.Error("Unable to delete the resolved alert", "key", key, "error", err)
+		}
+	}
+	notifier.activeMutex.Unlock()
+	if !firing {
+		return nil
+	}
+	notification.Resolved = true
+	notification.Message = message
+	notification.Time = time.Now()
+	return notifier.Notify(notification)
+}
+
+//Active returns the keys of the firing alerts that start with the prefix
+func (notifier *Notifier) Active(prefix string) []string {
+	notifier.activeMutex.Lock()
+	defer notifier.activeMutex.Unlock()
+	notifier.load()
+	var keys []string
+	for key := range notifier.active {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+//Notify sends the notification to every channel, a failed channel doesn't stop the others and the errors are joined
+func (notifier *Notifier) Notify(notification Notification) error {
+	_, err := notifier.send(notification)
+	return err
+}
+
+//send sends the notification to every channel, it returns false if every channel failed
+func (notifier *Notifier) send(notification Notification) (bool, error) {
+	if notification.Time.IsZero() {
+		notification.Time = time.Now()
+	}
+	if notification.Severity == "" {
+		notification.Severity = Info
+	}
+	var failures []string
+	for _, channel := range notifier.Channels {
+		if err := channel.Send(notification); err != nil {
+			notifier.log.Error("Unable to send the notification", "channel", channel.Name(), "key", notification.Key, "error", err)
+			failures = append(failures, channel.Name()+": "+err.Error())
+		}
+	}
+	sent := len(failures) < len(notifier.Channels) || len(notifier.Channels) == 0
+	if len(failures) > 0 {
+		return sent, errors.New("Notification failed on " + strings.Join(failures, "; "))
+	}
+	notifier.log.Info("Notification sent", "key", notification.Key, "channels", len(notifier.Channels))
+	return sent, nil
+}
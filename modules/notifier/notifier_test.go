@@ -0,0 +1,238 @@
+package notifier
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWebhook(t *testing.T) {
+	var received Notification
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&received)
+	}))
+	defer server.Close()
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+
+	notifier := New(&Webhook{URL: server.URL})
+	err := notifier.Notify(Notification{Key: "layout-drift:1", Title: "Layout drift", Fields: map[string]string{"rule": "books"}})
+	assert.Nil(t, err)
+	assert.Equal(t, "layout-drift:1", received.Key)
+	assert.Equal(t, Info, received.Severity, "severity should default to info")
+	assert.False(t, received.Time.IsZero(), "time should be set")
+	assert.Equal(t, "books", received.Fields["rule"])
+
+	received = Notification{}
+	notifier = New(&Webhook{URL: failing.URL}, &Webhook{URL: server.URL})
+	err = notifier.Notify(Notification{Key: "layout-drift:2"})
+	assert.NotNil(t, err, "non 2xx status should fail")
+	assert.Equal(t, "layout-drift:2", received.Key, "a failed channel should not stop the others")
+}
+
+func TestSMTPMessage(t *testing.T) {
+	mail := &SMTP{From: "grater@localhost", To: []string{"a@example.com", "b@example.com"}}
+	message := mail.message(Notification{Title: "Layout drift", Message: "75% errors", Severity: Warning, Fields: map[string]string{"rule": "books", "pages": "40"}})
+	assert.Contains(t, message, "To: a@example.com, b@example.com\r\n")
+	assert.Contains(t, message, "Subject: Grater [WARNING] Layout drift\r\n")
+	assert.True(t, strings.Index(message, "pages: 40") < strings.Index(message, "rule: books"), "fields should be sorted")
+
+	message = mail.message(Notification{Title: "Layout drift books\r\nBcc: victim@example.com", Severity: Warning})
+	assert.NotContains(t, message, "\r\nBcc:", "a line break in the title should not add a header")
+	assert.Contains(t, message, "Subject: =?utf-8?q?Grater_[WARNING]_Layout_drift_books=0D=0ABcc:_victim@")
+}
+
+func TestSlack(t *testing.T) {
+	var received map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&received)
+	}))
+	defer server.Close()
+
+	slack := &Slack{URL: server.URL}
+	assert.Nil(t, slack.Send(Notification{Title: "No healthy scrapers", Severity: Critical, Fields: map[string]string{"pendingLinks": "10"}}))
+	assert.Equal(t, "*[CRITICAL] No healthy scrapers*", received["text"])
+	attachment := received["attachments"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, "#f44336", attachment["color"])
+	assert.Equal(t, "pendingLinks", attachment["fields"].([]interface{})[0].(map[string]interface{})["title"])
+
+	assert.Nil(t, slack.Send(Notification{Title: "No healthy scrapers", Severity: Critical, Resolved: true}))
+	assert.Equal(t, "*[RESOLVED] No healthy scrapers*", received["text"])
+}
+
+//smtpStandIn accepts one email without authentication and returns the data
+func smtpStandIn(t *testing.T) (addr string, data chan string) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	data = make(chan string, 1)
+	go func() {
+		defer listener.Close()
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		text := textproto.NewConn(conn)
+		text.PrintfLine("220 localhost ESMTP")
+		for {
+			line, err := text.ReadLine()
+			if err != nil {
+				return
+			}
+			switch command := strings.ToUpper(strings.SplitN(line, " ", 2)[0]); command {
+			case "EHLO", "HELO", "MAIL", "RCPT", "RSET", "NOOP":
+				text.PrintfLine("250 OK")
+			case "DATA":
+				text.PrintfLine("354 Go ahead")
+				lines, _ := text.ReadDotLines()
+				data <- strings.Join(lines, "\n")
+				text.PrintfLine("250 OK")
+			case "QUIT":
+				text.PrintfLine("221 Bye")
+				return
+			default:
+				text.PrintfLine("502 Not implemented")
+			}
+		}
+	}()
+	return listener.Addr().String(), data
+}
+
+func TestSMTP(t *testing.T) {
+	addr, data := smtpStandIn(t)
+	mail := &SMTP{Addr: addr, From: "grater@localhost", To: []string{"ops@example.com"}}
+	assert.Nil(t, mail.Send(Notification{Title: "Database is not reachable", Message: "connection refused", Severity: Critical}))
+	select {
+	case message := <-data:
+		assert.Contains(t, message, "Subject: Grater [CRITICAL] Database is not reachable")
+		assert.Contains(t, message, "connection refused")
+	case <-time.After(5 * time.Second):
+		t.Fatal("the email was not received")
+	}
+}
+
+type recorder struct {
+	sent []Notification
+}
+
+func (recorder *recorder) Name() string {
+	return "recorder"
+}
+
+func (recorder *recorder) Send(notification Notification) error {
+	recorder.sent = append(recorder.sent, notification)
+	return nil
+}
+
+func TestFireAndResolve(t *testing.T) {
+	channel := &recorder{}
+	notifier := New(channel)
+	notifier.RepeatInterval = 0
+
+	notifier.Fire(Notification{Key: "no-scrapers", Title: "No healthy scrapers"})
+	notifier.Fire(Notification{Key: "no-scrapers", Title: "No healthy scrapers"})
+	assert.Equal(t, 1, len(channel.sent), "a firing alert should not be sent again")
+	assert.Equal(t, []string{"no-scrapers"}, notifier.Active("no-"))
+
+	notifier.Resolve("no-scrapers", "scrapers are back")
+	notifier.Resolve("no-scrapers", "scrapers are back")
+	assert.Equal(t, 2, len(channel.sent), "only a firing alert should be resolved")
+	assert.True(t, channel.sent[1].Resolved)
+	assert.Equal(t, "scrapers are back", channel.sent[1].Message)
+	assert.Empty(t, notifier.Active(""))
+
+	notifier.RepeatInterval = time.Nanosecond
+	notifier.Fire(Notification{Key: "database"})
+	time.Sleep(time.Millisecond)
+	notifier.Fire(Notification{Key: "database"})
+	assert.Equal(t, 4, len(channel.sent), "a firing alert should be sent again after the repeat interval")
+}
+
+//memoryStore keeps the firing alerts of the notifiers that share it like the database after a restart
+type memoryStore map[string]Notification
+
+func (store memoryStore) Active() ([]Notification, error) {
+	var notifications []Notification
+	for _, notification := range store {
+		notifications = append(notifications, notification)
+	}
+	return notifications, nil
+}
+
+func (store memoryStore) Save(notification Notification) error {
+	store[notification.Key] = notification
+	return nil
+}
+
+func (store memoryStore) Delete(key string) error {
+	delete(store, key)
+	return nil
+}
+
+func TestFireAndResolveAfterRestart(t *testing.T) {
+	store := memoryStore{}
+	channel := &recorder{}
+	notifier := New(channel)
+	notifier.RepeatInterval = 0
+	notifier.Store = store
+	notifier.Fire(Notification{Key: "no-scrapers", Title: "No healthy scrapers", Severity: Critical})
+	assert.Equal(t, 1, len(store))
+
+	//a new notifier is the distributor after a restart
+	restarted := New(channel)
+	restarted.RepeatInterval = 0
+	restarted.Store = store
+	assert.Equal(t, []string{"no-scrapers"}, restarted.Active(""))
+	restarted.Fire(Notification{Key: "no-scrapers", Title: "No healthy scrapers", Severity: Critical})
+	assert.Equal(t, 1, len(channel.sent), "an alert fired before the restart should not be sent again")
+
+	restarted.Resolve("no-scrapers", "scrapers are back")
+	assert.Equal(t, 2, len(channel.sent), "an alert fired before the restart should be resolved")
+	assert.True(t, channel.sent[1].Resolved)
+	assert.Equal(t, "No healthy scrapers", channel.sent[1].Title)
+	assert.Empty(t, store)
+}
+
+//failingChannel fails every notification until it is fixed
+type failingChannel struct {
+	recorder
+	fixed bool
+}
+
+func (channel *failingChannel) Send(notification Notification) error {
+	if !channel.fixed {
+		return errors.New("webhook is down")
+	}
+	return channel.recorder.Send(notification)
+}
+
+func TestFireRetriesFailedAlert(t *testing.T) {
+	store := memoryStore{}
+	channel := &failingChannel{}
+	notifier := New(channel)
+	notifier.Store = store
+
+	assert.NotNil(t, notifier.Fire(Notification{Key: "no-scrapers", Title: "No healthy scrapers"}))
+	assert.Empty(t, notifier.Active(""), "an alert that no channel sent should not be firing")
+	assert.Empty(t, store)
+
+	channel.fixed = true
+	assert.Nil(t, notifier.Fire(Notification{Key: "no-scrapers", Title: "No healthy scrapers"}))
+	assert.Equal(t, 1, len(channel.sent), "the alert should be sent on the next check")
+	assert.Equal(t, []string{"no-scrapers"}, notifier.Active(""))
+
+	//one working channel is enough for the alert to be firing
+	notifier = New(&failingChannel{}, &recorder{})
+	assert.NotNil(t, notifier.Fire(Notification{Key: "database"}))
+	assert.Equal(t, []string{"database"}, notifier.Active(""))
+}
@@ -0,0 +1,44 @@
+package notifier
+
+import "github.com/sporule/grater/models"
+
+//DatabaseStore keeps the firing alerts in the alert table
+type DatabaseStore struct{}
+
+//Active implements Store
+func (DatabaseStore) Active() ([]Notification, error) {
+	alerts, err := models.GetAlerts()
+	if err != nil {
+		return nil, err
+	}
+	notifications := make([]Notification, 0, len(alerts))
+	for _, alert := range alerts {
+		notifications = append(notifications, Notification{
+			Key:      alert.Key,
+			Title:    alert.Title,
+			Message:  alert.Message,
+			Severity: alert.Severity,
+			Fields:   alert.Fields,
+			Time:     alert.Time,
+		})
+	}
+	return notifications, nil
+}
+
+//Save implements Store
+func (DatabaseStore) Save(notification Notification) error {
+	alert := &models.Alert{
+		Key:      notification.Key,
+		Title:    notification.Title,
+		Message:  notification.Message,
+		Severity: notification.Severity,
+		Fields:   notification.Fields,
+		Time:     notification.Time,
+	}
+	return alert.Upsert()
+}
+
+//Delete implements Store
+func (DatabaseStore) Delete(key string) error {
+	return models.DeleteAlert(key)
+}
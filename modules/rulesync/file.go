@@ -0,0 +1,192 @@
+package rulesync
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/sporule/grater/models"
+	"github.com/sporule/grater/modules/utility"
+)
+
+//File is a rule written by hand, pattern and headers are nested objects and the deep link patterns are a list,
+//the json strings of the rule api are accepted as well so the api payload can be used as a file
+type File struct {
+	Name             string      `json:"name"`
+	LinkPattern      string      `json:"linkPattern"`
+	TotalPages       int         `json:"totalPages"`
+	Frequency        int         `json:"frequency"`
+	Priority         int         `json:"priority"`
+	TargetLocation   string      `json:"targetLocation"`
+	ResponseType     string      `json:"responseType"`
+	Cursor           string      `json:"cursor"`
+	DeepLinkPatterns interface{} `json:"deeplinkPatterns"`
+	Headers          interface{} `json:"headers"`
+	Pattern          interface{} `json:"pattern"`
+}
+
+//ReadFile reads and validates the yaml or json rule file, the format is picked by the extension and unknown keys are rejected
+func ReadFile(path string) (*models.Rule, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var file File
+	if err := Decode(path, content, &file); err != nil {
+		return nil, err
+	}
+	return file.Rule()
+}
+
+//isRuleFile returns true if the extension is yaml or json
+func isRuleFile(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml", ".json":
+		return true
+	}
+	return false
+}
+
+//Decode reads the yaml or json file into the value by the extension, the yaml is converted to json first
+//so both formats use the json names, unknown keys are rejected
+func Decode(path string, content []byte, value interface{}) error {
+	if strings.ToLower(filepath.Ext(path)) != ".json" {
+		normalised, err := utility.YAMLToJSON(content)
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		content = normalised
+	}
+	if err := utility.DecodeJSONStrict(content, value); err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	return nil
+}
+
+//jsonString returns the string as it is and the other values as json
+func jsonString(value interface{}) (string, error) {
+	switch value := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return value, nil
+	}
+	content, err := json.Marshal(value)
+	return string(content), err
+}
+
+//Rule converts the file to the rule that is stored, the rule is validated
+func (file File) Rule() (*models.Rule, error) {
+	rule := &models.Rule{
+		Name:           file.Name,
+		LinkPattern:    file.LinkPattern,
+		TotalPages:     file.TotalPages,
+		Frequency:      file.Frequency,
+		Priority:       file.Priority,
+		TargetLocation: file.TargetLocation,
+		ResponseType:   file.ResponseType,
+		Cursor:         file.Cursor,
+	}
+	invalid := make(map[string]string)
+	var err error
+	if rule.Pattern, err = jsonString(file.Pattern); err != nil {
+		invalid["pattern"] = err.Error()
+	}
+	if rule.Headers, err = jsonString(file.Headers); err != nil {
+		invalid["headers"] = err.Error()
+	}
+	switch deepLinks := file.DeepLinkPatterns.(type) {
+	case nil:
+	case string:
+		rule.DeepLinkPatterns = deepLinks
+	case []interface{}:
+		patterns := make([]string, len(deepLinks))
+		for i, pattern := range deepLinks {
+			patterns[i] = fmt.Sprint(pattern)
+		}
+		rule.DeepLinkPatterns = strings.Join(patterns, ",")
+	default:
+		invalid["deeplinkPatterns"] = "it should be a list or a comma separated string"
+	}
+	if len(invalid) > 0 {
+		return nil, utility.ValidationError("Invalid rule", invalid)
+	}
+	return rule, rule.Validate()
+}
+
+//describe returns the invalid fields of a validation error or the error message
+func describe(err error) string {
+	var appErr *utility.AppError
+	if errors.As(err, &appErr) {
+		if details, ok := appErr.Details.(map[string]string); ok {
+			fields := make([]string, 0, len(details))
+			for field, message := range details {
+				fields = append(fields, field+" "+message)
+			}
+			sort.Strings(fields)
+			return strings.Join(fields, ", ")
+		}
+	}
+	return err.Error()
+}
+
+//Files returns the yaml and json files in the directory and its sub directories in lexical order, hidden files and directories
+//are skipped and so are the testdata directories of the rule fixtures
+func Files(dir string) ([]string, error) {
+	var files []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if strings.HasPrefix(info.Name(), ".") && path != dir {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if info.IsDir() && info.Name() == "testdata" {
+			return filepath.SkipDir
+		}
+		if !info.IsDir() && isRuleFile(path) {
+			files = append(files, path)
+		}
+		return nil
+	})
+	return files, err
+}
+
+//ReadDir reads the rule files in the directory, see Files, the source of the rules is the path relative to the directory
+func ReadDir(dir string) ([]models.Rule, error) {
+	files, err := Files(dir)
+	if err != nil {
+		return nil, err
+	}
+	var rules []models.Rule
+	invalid := make(map[string]string)
+	sources := make(map[string]string)
+	for _, path := range files {
+		source, _ := filepath.Rel(dir, path)
+		source = filepath.ToSlash(source)
+		rule, err := ReadFile(path)
+		if err != nil {
+			invalid[source] = describe(err)
+			continue
+		}
+		if other, ok := sources[rule.Name]; ok {
+			invalid[source] = "the name " + rule.Name + " is used by " + other
+			continue
+		}
+		sources[rule.Name] = source
+		rule.Source = source
+		rules = append(rules, *rule)
+	}
+	if len(invalid) > 0 {
+		return nil, utility.ValidationError("Invalid rule files", invalid)
+	}
+	return rules, nil
+}
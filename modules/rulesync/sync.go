@@ -0,0 +1,123 @@
+package rulesync
+
+import (
+	"github.com/sporule/grater/models"
+	"github.com/sporule/grater/modules/utility"
+)
+
+//Actions of the changes
+const (
+	Create = "create"
+	Update = "update"
+	Cancel = "cancel"
+)
+
+//Change is what the sync does to a rule, rule is the rule that is saved
+type Change struct {
+	Action string               `json:"action"`
+	Name   string               `json:"name"`
+	RuleID string               `json:"ruleID,omitempty"`
+	Source string               `json:"source"`
+	Fields []models.FieldChange `json:"fields,omitempty"`
+	rule   models.Rule
+}
+
+//diff returns the fields of the existing rule that are different in the file
+func diff(existing, file models.Rule) []models.FieldChange {
+	fields := existing.Definition().Diff(file.Definition())
+	if existing.Source != file.Source {
+		fields = append(fields, models.FieldChange{Field: "source", Old: existing.Source, New: file.Source})
+	}
+	if existing.Status == utility.Enums().Status.Cancelled {
+		fields = append(fields, models.FieldChange{Field: "status", Old: existing.Status, New: utility.Enums().Status.Active})
+	}
+	return fields
+}
+
+//Plan compares the rule files with the existing rules by name, a cancelled rule is activated again if its file comes back
+//and only rules with a source are cancelled when their file is removed so the rules of the api are kept
+func Plan(files, existing []models.Rule) []Change {
+	byName := make(map[string]models.Rule, len(existing))
+	for _, rule := range existing {
+		//the active rule wins if the api created more than one rule with the name
+		if current, ok := byName[rule.Name]; !ok || current.Status == utility.Enums().Status.Cancelled {
+			byName[rule.Name] = rule
+		}
+	}
+	var changes []Change
+	inFiles := make(map[string]bool, len(files))
+	for _, file := range files {
+		inFiles[file.Name] = true
+		current, ok := byName[file.Name]
+		if !ok {
+			file.Status = utility.Enums().Status.Active
+			changes = append(changes, Change{Action: Create, Name: file.Name, Source: file.Source, rule: file})
+			continue
+		}
+		fields := diff(current, file)
+		if len(fields) == 0 {
+			continue
+		}
+		updated := current
+		updated.LinkPattern = file.LinkPattern
+		updated.TotalPages = file.TotalPages
+		updated.Frequency = file.Frequency
+		updated.Priority = file.Priority
+		updated.TargetLocation = file.TargetLocation
+		updated.DeepLinkPatterns = file.DeepLinkPatterns
+		updated.ResponseType = file.ResponseType
+		updated.Cursor = file.Cursor
+		updated.Headers = file.Headers
+		updated.Pattern = file.Pattern
+		updated.Source = file.Source
+		if updated.Status == utility.Enums().Status.Cancelled {
+			updated.Status = utility.Enums().Status.Active
+		}
+		changes = append(changes, Change{Action: Update, Name: file.Name, RuleID: current.ID, Source: file.Source, Fields: fields, rule: updated})
+	}
+	for _, rule := range existing {
+		if rule.Source == "" || inFiles[rule.Name] || rule.Status == utility.Enums().Status.Cancelled {
+			continue
+		}
+		changes = append(changes, Change{Action: Cancel, Name: rule.Name, RuleID: rule.ID, Source: rule.Source, rule: rule})
+	}
+	return changes
+}
+
+//Apply saves the changes, it stops at the first error and returns the changes that are applied
+func Apply(changes []Change) ([]Change, error) {
+	for i, change := range changes {
+		var err error
+		switch change.Action {
+		case Create, Update:
+			err = change.rule.Upsert()
+			changes[i].RuleID = change.rule.ID
+		case Cancel:
+			if err = models.CancelRule(change.RuleID); err == nil {
+				err = models.CancelInactiveLinks(change.RuleID)
+			}
+		}
+		if err != nil {
+			return changes[:i], err
+		}
+	}
+	return changes, nil
+}
+
+//Sync reads the rule files in the directory and applies the changes, nothing is saved in dry run
+//and no rule is changed if any file is invalid
+func Sync(dir string, dryRun bool) ([]Change, error) {
+	files, err := ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	existing, err := models.GetRules(nil, 0)
+	if err != nil {
+		return nil, err
+	}
+	changes := Plan(files, existing)
+	if dryRun {
+		return changes, nil
+	}
+	return Apply(changes)
+}
@@ -0,0 +1,104 @@
+package rulesync
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sporule/grater/models"
+	"github.com/sporule/grater/modules/utility"
+)
+
+func writeRule(t *testing.T, dir, name, content string) {
+	path := filepath.Join(dir, name)
+	assert.Nil(t, os.MkdirAll(filepath.Dir(path), 0700))
+	assert.Nil(t, ioutil.WriteFile(path, []byte(content), 0600))
+}
+
+func TestReadFile(t *testing.T) {
+	dir := t.TempDir()
+	writeRule(t, dir, "ps5.yaml", `
+name: PS5
+linkPattern: https://example.com/?page={page}
+totalPages: 2
+targetLocation: PS5
+deeplinkPatterns: [li.item, span.bids, a]
+headers:
+  referer: https://example.com/
+pattern:
+  name: {pattern: h1, value: text}
+`)
+	writeRule(t, dir, "api.json", `{"name": "PS5", "targetLocation": "PS5", "pattern": "{\"name\":{\"pattern\":\"h1\",\"value\":\"text\"}}", "deeplinkPatterns": "li.item,span.bids,a"}`)
+
+	rule, err := ReadFile(filepath.Join(dir, "ps5.yaml"))
+	assert.Nil(t, err)
+	assert.Equal(t, `{"name":{"pattern":"h1","value":"text"}}`, rule.Pattern)
+	assert.Equal(t, `{"referer":"https://example.com/"}`, rule.Headers)
+	assert.Equal(t, "li.item,span.bids,a", rule.DeepLinkPatterns)
+
+	rule, err = ReadFile(filepath.Join(dir, "api.json"))
+	assert.Nil(t, err, "the payload of the rule api should be accepted")
+	assert.Equal(t, `{"name":{"pattern":"h1","value":"text"}}`, rule.Pattern)
+	assert.Equal(t, "li.item,span.bids,a", rule.DeepLinkPatterns)
+
+	writeRule(t, dir, "typo.yaml", "name: PS5\ntargetLocation: PS5\npatern: {}\n")
+	_, err = ReadFile(filepath.Join(dir, "typo.yaml"))
+	assert.Contains(t, err.Error(), "patern")
+}
+
+func TestReadDir(t *testing.T) {
+	dir := t.TempDir()
+	writeRule(t, dir, "a.yaml", "name: A\ntargetLocation: A\npattern: {name: {pattern: h1, value: text}}\n")
+	writeRule(t, dir, "nested/b.yml", "name: B\ntargetLocation: B\npattern: {name: {pattern: h1, value: text}}\n")
+	writeRule(t, dir, ".git/c.yaml", "not a rule")
+	writeRule(t, dir, "README.md", "not a rule")
+	writeRule(t, dir, "testdata/a/fixture.yaml", "pages: {/: list.html}\n")
+	rules, err := ReadDir(dir)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(rules))
+	assert.Equal(t, "a.yaml", rules[0].Source)
+	assert.Equal(t, "nested/b.yml", rules[1].Source)
+
+	writeRule(t, dir, "copy.yaml", "name: A\ntargetLocation: A\npattern: {name: {pattern: h1, value: text}}\n")
+	writeRule(t, dir, "invalid.yaml", "name: C\n")
+	_, err = ReadDir(dir)
+	details := err.(*utility.AppError).Details.(map[string]string)
+	assert.Equal(t, "the name A is used by a.yaml", details["copy.yaml"])
+	assert.Equal(t, "pattern required, targetLocation required", details["invalid.yaml"])
+}
+
+func TestPlan(t *testing.T) {
+	status := utility.Enums().Status
+	files := []models.Rule{
+		{Name: "New", TargetLocation: "New", Pattern: `{"a":1}`, Source: "new.yaml"},
+		{Name: "Same", TargetLocation: "Same", Pattern: `{"a":1,"b":2}`, Source: "same.yaml"},
+		{Name: "Changed", TargetLocation: "Changed", Pattern: `{"a":2}`, Frequency: 60, Source: "changed.yaml"},
+		{Name: "Back", TargetLocation: "Back", Pattern: `{"a":1}`, Source: "back.yaml"},
+	}
+	existing := []models.Rule{
+		{ID: "1", Name: "Same", Status: status.Active, TargetLocation: "Same", Pattern: `{"b":2, "a":1}`, Source: "same.yaml"},
+		{ID: "2", Name: "Changed", Status: status.Paused, TargetLocation: "Changed", Pattern: `{"a":1}`, Source: "changed.yaml"},
+		{ID: "3", Name: "Back", Status: status.Cancelled, TargetLocation: "Back", Pattern: `{"a":1}`, Source: "back.yaml"},
+		{ID: "4", Name: "Removed", Status: status.Active, Source: "removed.yaml"},
+		{ID: "5", Name: "API", Status: status.Active},
+	}
+	changes := Plan(files, existing)
+	assert.Equal(t, 4, len(changes))
+
+	assert.Equal(t, Create, changes[0].Action)
+	assert.Equal(t, status.Active, changes[0].rule.Status)
+
+	assert.Equal(t, Update, changes[1].Action)
+	assert.Equal(t, "2", changes[1].RuleID)
+	assert.Equal(t, []models.FieldChange{{Field: "frequency", Old: "0", New: "60"}, {Field: "pattern", Old: `{"a":1}`, New: `{"a":2}`}}, changes[1].Fields)
+	assert.Equal(t, status.Paused, changes[1].rule.Status, "a paused rule should stay paused")
+
+	assert.Equal(t, Update, changes[2].Action)
+	assert.Equal(t, []models.FieldChange{{Field: "status", Old: status.Cancelled, New: status.Active}}, changes[2].Fields)
+
+	assert.Equal(t, Cancel, changes[3].Action)
+	assert.Equal(t, "4", changes[3].RuleID, "only rules from files should be cancelled")
+}
@@ -0,0 +1,177 @@
+package scraper
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gocolly/colly"
+	"github.com/google/uuid"
+	"github.com/sporule/grater/models"
+	"github.com/sporule/grater/modules/logger"
+	"github.com/sporule/grater/modules/warc"
+)
+
+//the WARC file and record of the response are kept in the context of the request until the record is added
+const (
+	warcFileKey     = "warcFile"
+	warcRecordIDKey = "warcRecordID"
+)
+
+//pruneInterval is the time between two deletions of the files after the retention
+const pruneInterval = time.Hour
+
+var lastPrune time.Time
+
+//archiver writes the requests and responses of a scraper to one WARC file until the file is uploaded,
+//the name of the file is known before it is uploaded so the results can be linked to it
+type archiver struct {
+	store  warc.Store
+	ruleID string
+	name   string
+	buffer bytes.Buffer
+	mutex  sync.Mutex
+}
+
+//newArchiver returns nil if the archive is off
+func newArchiver(store warc.Store, ruleID string) *archiver {
+	if store == nil {
+		return nil
+	}
+	return &archiver{store: store, ruleID: ruleID}
+}
+
+//write adds the request and the response to the current file and returns the file and the id of the response record
+func (archive *archiver) write(r *colly.Response) (string, string, error) {
+	headers := http.Header{}
+	if r.Request.Headers != nil {
+		headers = *r.Request.Headers
+	}
+	request, err := warc.RequestRecord(r.Request.Method, r.Request.URL.String(), headers)
+	if err != nil {
+		return "", "", err
+	}
+	headers = http.Header{}
+	if r.Headers != nil {
+		headers = *r.Headers
+	}
+	response, err := warc.ResponseRecord(r.Request.URL.String(), r.StatusCode, headers, r.Body)
+	if err != nil {
+		return "", "", err
+	}
+	request.ConcurrentTo = response.ID
+	archive.mutex.Lock()
+	defer archive.mutex.Unlock()
+	if archive.buffer.Len() == 0 {
+		id, _ := uuid.NewRandom()
+		archive.name = archive.ruleID + "/" + time.Now().UTC().Format("20060102T150405Z") + "-" + id.String() + ".warc.gz"
+		if err := warc.Write(&archive.buffer, warc.InfoRecord()); err != nil {
+			return "", "", err
+		}
+	}
+	for _, record := range []*warc.Record{request, response} {
+		if err := warc.Write(&archive.buffer, record); err != nil {
+			return "", "", err
+		}
+	}
+	return archive.name, response.ID, nil
+}
+
+//flush uploads the current file, the file is kept and uploaded again on the next flush if it fails
+func (archive *archiver) flush() error {
+	archive.mutex.Lock()
+	defer archive.mutex.Unlock()
+	if archive.buffer.Len() == 0 {
+		return nil
+	}
+	if err := archive.store.Put(archive.name, archive.buffer.Bytes()); err != nil {
+		return err
+	}
+	archive.buffer.Reset()
+	return nil
+}
+
+//archiveResponse writes the response to the archive and keeps its record in the context for the result
+func (scraper *scraper) archiveResponse(r *colly.Response) {
+	if scraper.archive == nil {
+		return
+	}
+	file, recordID, err := scraper.archive.write(r)
+	if err != nil {
+		scraper.linkLogger(r.Request).Warn("Unable to archive the response", "error", err)
+		return
+	}
+	r.Ctx.Put(warcFileKey, file)
+	r.Ctx.Put(warcRecordIDKey, recordID)
+}
+
+//linkResult links the result to the archived response and the stored body of the page
+func linkResult(result *models.Result, r *colly.Response) {
+	result.WARCFile = r.Ctx.Get(warcFileKey)
+	result.WARCRecordID = r.Ctx.Get(warcRecordIDKey)
+	result.PageID = r.Ctx.Get(pageIDKey)
+}
+
+//pruneArchive deletes the files after the retention, it runs at most once per pruneInterval
+func pruneArchive(store warc.Store, retention int) {
+	if store == nil || retention <= 0 || time.Since(lastPrune) < pruneInterval {
+		return
+	}
+	lastPrune = time.Now()
+	log := logger.New("component", "archive")
+	deleted, err := warc.Prune(store, retention)
+	if err != nil {
+		log.Error("Unable to delete the archived pages after the retention", "error", err)
+		return
+	}
+	if deleted > 0 {
+		log.Info("Deleted the archived pages after the retention", "files", deleted, "retentionDays", retention)
+	}
+}
+
+//replayTransport answers the requests with the archived responses, the links that aren't archived fail
+type replayTransport map[string]*warc.Record
+
+//RoundTrip implements http.RoundTripper
+func (transport replayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	record, ok := transport[req.URL.String()]
+	if !ok {
+		return nil, errors.New("the page is not archived")
+	}
+	res, err := record.HTTPResponse()
+	if err != nil {
+		return nil, err
+	}
+	res.Request = req
+	return res, nil
+}
+
+//Replay runs the rule over the archived responses of the WARC files without requesting anything,
+//the pages are parsed in the order they were fetched and the last response of a link is used
+func Replay(rule models.Rule, files [][]byte) (*OnceReport, error) {
+	transport := replayTransport{}
+	var links []string
+	for _, file := range files {
+		records, err := warc.ReadAll(file)
+		if err != nil {
+			return nil, err
+		}
+		for _, record := range records {
+			if record.Type != warc.Response {
+				continue
+			}
+			if _, ok := transport[record.TargetURI]; !ok {
+				links = append(links, record.TargetURI)
+			}
+			transport[record.TargetURI] = record
+		}
+	}
+	//the deep links are archived too so they are parsed in the same round after their parent
+	scraper, err := scrapeLocally(rule, links, 1, transport, nil)
+	if err != nil {
+		return nil, err
+	}
+	return scraper.report(), nil
+}
@@ -0,0 +1,73 @@
+package scraper
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/gocolly/colly"
+	"github.com/sporule/grater/models"
+	"github.com/sporule/grater/modules/warc"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestArchiveAndReplay(t *testing.T) {
+	store, err := warc.NewDiskStore(t.TempDir())
+	assert.Nil(t, err)
+	archive := newArchiver(store, "rule1")
+	response := func(link, body string) *colly.Response {
+		page, _ := url.Parse(link)
+		ctx := colly.NewContext()
+		return &colly.Response{
+			StatusCode: http.StatusOK,
+			Body:       []byte(body),
+			Ctx:        ctx,
+			Headers:    &http.Header{"Content-Type": {"text/html"}, "Set-Cookie": {"session=1"}},
+			Request:    &colly.Request{URL: page, Method: http.MethodGet, Headers: &http.Header{"Cookie": {"session=1"}}, Ctx: ctx},
+		}
+	}
+	var results []*models.Result
+	for _, page := range []*colly.Response{
+		response("https://example.com/list", `<html><body><div class="item"><span class="bids">3</span><a href="/item/1">PS5</a></div></body></html>`),
+		response("https://example.com/item/1", `<html><body><h1>PS5</h1><span class="price">£450</span></body></html>`),
+	} {
+		file, recordID, err := archive.write(page)
+		assert.Nil(t, err)
+		page.Ctx.Put(warcFileKey, file)
+		page.Ctx.Put(warcRecordIDKey, recordID)
+		result, _ := models.NewResult("{}", "rule1", 1)
+		linkResult(result, page)
+		results = append(results, result)
+	}
+	assert.Equal(t, results[0].WARCFile, results[1].WARCFile, "the pages of a scraper should be written to one file until it is uploaded")
+	assert.NotEqual(t, results[0].WARCRecordID, results[1].WARCRecordID)
+	assert.Nil(t, archive.flush())
+
+	files, err := store.List("rule1/")
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(files))
+	assert.Equal(t, results[0].WARCFile, files[0].Name)
+	content, err := store.Get(files[0].Name)
+	assert.Nil(t, err)
+	records, err := warc.ReadAll(content)
+	assert.Nil(t, err)
+	assert.Equal(t, 5, len(records), "a warcinfo record and a request and response record per page")
+	assert.Equal(t, results[1].WARCRecordID, records[4].ID)
+	assert.Equal(t, records[4].ID, records[3].ConcurrentTo, "the request should be linked to its response")
+	assert.NotContains(t, string(records[3].Block), "session=1", "cookies should not be archived")
+
+	rule := models.Rule{
+		ID:               "rule1",
+		Name:             "PS5",
+		Pattern:          `{"name":{"pattern":"h1","value":"text"},"price":{"pattern":"span.price","value":"text","postprocess":{"replace":"£,"}}}`,
+		DeepLinkPatterns: "div.item,span.bids,a",
+	}
+	report, err := Replay(rule, [][]byte{content})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(report.Records), "the archived deep link should be replayed once")
+	var record map[string]interface{}
+	assert.Nil(t, json.Unmarshal(report.Records[0], &record))
+	assert.Equal(t, "450", record["price"].(map[string]interface{})["value"])
+	assert.Equal(t, 0, len(report.Failed))
+}
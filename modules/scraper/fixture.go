@@ -0,0 +1,205 @@
+package scraper
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/sporule/grater/modules/rulesync"
+)
+
+//FixtureFile is the name of the fixture of a rule, it is in testdata/<name of the rule file> next to the rule file
+const FixtureFile = "fixture.yaml"
+
+//fixtureRounds is the most rounds of a fixture, every round visits the deep links and the next pages found on the round before
+const fixtureRounds = 10
+
+//Fixture is the saved pages of a rule and what the rule should scrape from them,
+//the pages are files next to the fixture by the path and query of their links, the host of the links is ignored
+type Fixture struct {
+	Pages map[string]string `json:"pages"`
+	//Links replaces the links of the linkPattern
+	Links []string `json:"links"`
+	//TotalPages replaces the totalPages of the rule so the fixture doesn't need every page
+	TotalPages   int           `json:"totalPages"`
+	Records      []interface{} `json:"records"`
+	LayoutErrors []string      `json:"layoutErrors"`
+	Failed       []string      `json:"failed"`
+}
+
+//FixtureReport is the result of a fixture, the fixture passed if there is no mismatch and missing is true if the rule has no fixture
+type FixtureReport struct {
+	Rule       string   `json:"rule"`
+	Fixture    string   `json:"fixture"`
+	Missing    bool     `json:"missing,omitempty"`
+	Records    int      `json:"records"`
+	Mismatches []string `json:"mismatches,omitempty"`
+}
+
+//Passed returns true if the rule scraped what the fixture expects
+func (report *FixtureReport) Passed() bool {
+	return len(report.Mismatches) == 0
+}
+
+//FixturePath returns the path of the fixture of the rule file, e.g. rules/testdata/ps5/fixture.yaml for rules/ps5.yaml
+func FixturePath(ruleFile string) string {
+	name := strings.TrimSuffix(filepath.Base(ruleFile), filepath.Ext(ruleFile))
+	return filepath.Join(filepath.Dir(ruleFile), "testdata", name, FixtureFile)
+}
+
+//fixtureTransport sends every request to the fixture server, the links keep their host so the records are the same as online
+type fixtureTransport struct {
+	server *url.URL
+}
+
+//RoundTrip implements http.RoundTripper
+func (transport fixtureTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	local := req.Clone(req.Context())
+	local.URL.Scheme = transport.server.Scheme
+	local.URL.Host = transport.server.Host
+	local.Host = transport.server.Host
+	res, err := http.DefaultTransport.RoundTrip(local)
+	if err != nil {
+		return nil, err
+	}
+	//colly reads the link of the page from the request of the response
+	res.Request = req
+	return res, nil
+}
+
+//fixtureServer serves the pages of the fixture by the path and query of the request, other links are not found
+func fixtureServer(dir string, pages map[string]string) (*httptest.Server, error) {
+	files := make(map[string][]byte, len(pages))
+	for link, file := range pages {
+		content, err := ioutil.ReadFile(filepath.Join(dir, file))
+		if err != nil {
+			return nil, err
+		}
+		files[link] = content
+	}
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		content, ok := files[r.URL.RequestURI()]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		if contentType := mime.TypeByExtension(filepath.Ext(pages[r.URL.RequestURI()])); contentType != "" {
+			w.Header().Set("Content-Type", contentType)
+		}
+		w.Write(content)
+	})), nil
+}
+
+//RunFixture scrapes the pages of the fixture with the rule file through the whole scraper pipeline,
+//the pages are served by a local server so no network, proxies or database are needed
+func RunFixture(ruleFile, fixtureFile string) (*FixtureReport, error) {
+	rule, err := rulesync.ReadFile(ruleFile)
+	if err != nil {
+		return nil, err
+	}
+	content, err := ioutil.ReadFile(fixtureFile)
+	if err != nil {
+		return nil, err
+	}
+	var fixture Fixture
+	if err := rulesync.Decode(fixtureFile, content, &fixture); err != nil {
+		return nil, err
+	}
+	server, err := fixtureServer(filepath.Dir(fixtureFile), fixture.Pages)
+	if err != nil {
+		return nil, err
+	}
+	defer server.Close()
+	serverURL, _ := url.Parse(server.URL)
+
+	links := fixture.Links
+	if len(links) == 0 {
+		if fixture.TotalPages > 0 {
+			rule.TotalPages = fixture.TotalPages
+		}
+		if links, err = rule.GenerateLinks(); err != nil {
+			return nil, err
+		}
+	}
+	scraper, err := scrapeLocally(*rule, links, fixtureRounds, fixtureTransport{server: serverURL}, nil)
+	if err != nil {
+		return nil, err
+	}
+	result := scraper.report()
+	report := &FixtureReport{Rule: ruleFile, Fixture: fixtureFile, Records: len(result.Records)}
+	mismatch := func(format string, values ...interface{}) {
+		report.Mismatches = append(report.Mismatches, fmt.Sprintf(format, values...))
+	}
+	if len(result.Records) != len(fixture.Records) {
+		mismatch("expected %d records, got %d", len(fixture.Records), len(result.Records))
+	}
+	for i, record := range result.Records {
+		var actual interface{}
+		if err := json.Unmarshal(record, &actual); err != nil {
+			return nil, err
+		}
+		if i >= len(fixture.Records) {
+			mismatch("record %d is not expected: %s", i, record)
+			continue
+		}
+		if !reflect.DeepEqual(fixture.Records[i], actual) {
+			expected, _ := json.Marshal(fixture.Records[i])
+			mismatch("record %d: expected %s, got %s", i, expected, record)
+		}
+	}
+	var layoutErrors, failed []string
+	for _, layoutError := range result.LayoutErrors {
+		layoutErrors = append(layoutErrors, layoutError.Link)
+	}
+	for link := range result.Failed {
+		failed = append(failed, link)
+	}
+	for name, links := range map[string][2][]string{
+		"layout errors": {fixture.LayoutErrors, layoutErrors},
+		"failed links":  {fixture.Failed, failed},
+	} {
+		expected, actual := sortedLinks(links[0]), sortedLinks(links[1])
+		if !reflect.DeepEqual(expected, actual) {
+			mismatch("%s: expected %v, got %v", name, expected, actual)
+		}
+	}
+	return report, nil
+}
+
+func sortedLinks(links []string) []string {
+	sorted := append([]string{}, links...)
+	sort.Strings(sorted)
+	return sorted
+}
+
+//RunFixtures runs the fixtures of the rule files in the directory and its sub directories like the rule sync reads them,
+//the rules without a fixture are reported as missing
+func RunFixtures(dir string) ([]*FixtureReport, error) {
+	var reports []*FixtureReport
+	ruleFiles, err := rulesync.Files(dir)
+	if err != nil {
+		return nil, err
+	}
+	for _, ruleFile := range ruleFiles {
+		fixtureFile := FixturePath(ruleFile)
+		if _, err := os.Stat(fixtureFile); os.IsNotExist(err) {
+			reports = append(reports, &FixtureReport{Rule: ruleFile, Fixture: fixtureFile, Missing: true})
+			continue
+		}
+		report, err := RunFixture(ruleFile, fixtureFile)
+		if err != nil {
+			return reports, fmt.Errorf("%s: %v", fixtureFile, err)
+		}
+		reports = append(reports, report)
+	}
+	return reports, nil
+}
@@ -0,0 +1,46 @@
+package scraper
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRuleFixtures(t *testing.T) {
+	reports, err := RunFixtures("../../examples/rules")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(reports))
+	for _, report := range reports {
+		assert.False(t, report.Missing, "every example rule should have a fixture: %s", report.Rule)
+		assert.True(t, report.Passed(), "%s: %v", report.Fixture, report.Mismatches)
+	}
+}
+
+func TestRunFixtures(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		assert.Nil(t, os.MkdirAll(filepath.Dir(path), 0755))
+		assert.Nil(t, ioutil.WriteFile(path, []byte(content), 0644))
+	}
+	rule := "name: %s\nresponseType: json\nlinkPattern: https://example.com/posts.json?page={page}\ntotalPages: 2\ntargetLocation: Posts\npattern:\n  title:\n    pattern: title\n    value: text\n"
+	write("shop/posts.yaml", fmt.Sprintf(rule, "Posts"))
+	write("shop/testdata/posts/fixture.yaml", "pages:\n  /posts.json?page=1: posts.json\nrecords:\n  - {rule: Posts, link: \"https://example.com/posts.json?page=1\", title: {value: Old Title}}\n")
+	write("shop/testdata/posts/posts.json", `{"title": "New Title"}`)
+	write("other.yaml", fmt.Sprintf(rule, "Other"))
+
+	reports, err := RunFixtures(dir)
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(reports), "the rules in the sub directories should be run")
+	assert.True(t, reports[0].Missing, "the rule without a fixture should be reported")
+	assert.Equal(t, filepath.Join(dir, "other.yaml"), reports[0].Rule)
+	report := reports[1]
+	assert.False(t, report.Passed())
+	assert.Equal(t, 1, report.Records)
+	//the changed title and the second page that isn't in the fixture
+	assert.Equal(t, 2, len(report.Mismatches), report.Mismatches)
+}
@@ -0,0 +1,60 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sporule/grater/modules/config"
+	"github.com/sporule/grater/modules/health"
+)
+
+//loopHeartbeat is the name of the heartbeat that is beaten when the scrapers make progress
+const loopHeartbeat = "scraper"
+
+//proxyPools keeps the proxy pool size of the running scrapers
+var proxyPools = struct {
+	sync.Mutex
+	sizes map[*scraper]int
+}{sizes: make(map[*scraper]int)}
+
+//RegisterHealthChecks adds the scraper loop progress to /healthz and the proxy pool to /readyz
+func RegisterHealthChecks(config config.Scraper) {
+	health.Register("scraperLoop", true, health.Heartbeat(loopHeartbeat, time.Duration(config.StallTimeout)*time.Minute))
+	health.Register("proxyPool", false, checkProxyPool)
+}
+
+//checkProxyPool fails if a running scraper doesn't have a usable proxy, scrapers wait for proxy when there is only one left
+func checkProxyPool(ctx context.Context) error {
+	proxyPools.Lock()
+	defer proxyPools.Unlock()
+	waiting := 0
+	for _, size := range proxyPools.sizes {
+		if size <= 1 {
+			waiting++
+		}
+	}
+	if waiting > 0 {
+		return errors.New(strconv.Itoa(waiting) + " of " + strconv.Itoa(len(proxyPools.sizes)) + " scrapers are waiting for proxy")
+	}
+	return nil
+}
+
+//reportProxyPool records the proxy pool size of the scraper
+func (scraper *scraper) reportProxyPool() {
+	if !scraper.useProxy {
+		return
+	}
+	proxyPools.Lock()
+	proxyPools.sizes[scraper] = len(scraper.proxies)
+	proxyPools.Unlock()
+}
+
+//removeProxyPool stops reporting the proxy pool of the scraper once it is stopped
+func (scraper *scraper) removeProxyPool() {
+	proxyPools.Lock()
+	delete(proxyPools.sizes, scraper)
+	proxyPools.Unlock()
+}
@@ -0,0 +1,154 @@
+package scraper
+
+import (
+	"encoding/json"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gocolly/colly"
+
+	"github.com/sporule/grater/models"
+	"github.com/sporule/grater/modules/jsonpath"
+	"github.com/sporule/grater/modules/utility"
+)
+
+//parseJSONResponse queues the next page and the deep links of the json response, the record is saved if the response has no deep links like html pages
+func (scraper *scraper) parseJSONResponse(r *colly.Response) {
+	var data interface{}
+	if err := json.Unmarshal(r.Body, &data); err != nil {
+		//the body is kept as a layout error so the response can be checked
+		scraper.addRecord(r, nil, nil, true, false, []models.MissingPattern{{Selector: "invalid json: " + err.Error()}})
+		return
+	}
+	scraper.addCursorLink(r.Request, data)
+	linkPatterns := strings.Split(scraper.rule.DeepLinkPatterns, ",")
+	if len(linkPatterns) >= 3 {
+		//this is the parent page, the parent path selects the items with the parent value and the link
+		if parents, ok := jsonpath.Get(data, linkPatterns[0]); ok && len(jsonItems(parents)) > 0 {
+			for _, parent := range jsonItems(parents) {
+				parentValue, _ := jsonpath.Get(parent, linkPatterns[1])
+				link, _ := jsonpath.Get(parent, linkPatterns[2])
+				scraper.addDeepLink(r.Request, linkPatterns, jsonpath.String(link), jsonpath.String(parentValue))
+			}
+			return
+		}
+	}
+	var pattern map[string]interface{}
+	if err := json.Unmarshal([]byte(scraper.rule.Pattern), &pattern); !utility.IsNil(err) {
+		scraper.linkLogger(r.Request).Error("Cannot read the rule pattern", "error", err)
+		return
+	}
+	value, isWrongPage, invalidPage, missing := parseJSONPattern(data, pattern, scraper.parentLinks[r.Request.URL.String()], "")
+	scraper.addRecord(r, pattern, value, isWrongPage, invalidPage, missing)
+}
+
+//addCursorLink queues the next page of the json response, the cursor replaces {cursor} in the link pattern
+//or it is the link of the next page if the link pattern has no {cursor}, a page is only queued once
+func (scraper *scraper) addCursorLink(r *colly.Request, data interface{}) {
+	if scraper.rule.Cursor == "" {
+		return
+	}
+	value, _ := jsonpath.Get(data, scraper.rule.Cursor)
+	cursor := strings.TrimSpace(jsonpath.String(value))
+	if cursor == "" || cursor == "false" {
+		//this is the last page
+		return
+	}
+	var link string
+	if strings.Contains(scraper.rule.LinkPattern, "{cursor}") {
+		link = strings.ReplaceAll(strings.ReplaceAll(scraper.rule.LinkPattern, "{page}", "1"), "{cursor}", url.QueryEscape(cursor))
+	} else {
+		link = r.AbsoluteURL(cursor)
+	}
+	scraper.cursorLinksMutex.Lock()
+	defer scraper.cursorLinksMutex.Unlock()
+	if link == "" || scraper.cursorLinks[link] {
+		return
+	}
+	scraper.cursorLinks[link] = true
+	scraper.linkLogger(r).Debug("Queued the next page", "nextPage", link)
+	scraper.addLinkToQueue(link)
+}
+
+//jsonItems returns the items of an array, a value that is not an array is the only item
+func jsonItems(value interface{}) []interface{} {
+	switch value := value.(type) {
+	case nil:
+		return nil
+	case []interface{}:
+		return value
+	}
+	return []interface{}{value}
+}
+
+//parseJSONPattern extracts the values by the pattern like parsePattern, the pattern of an item is a json path and the children are parsed for every item of the array
+func parseJSONPattern(data interface{}, item map[string]interface{}, parentValue string, path string) (map[string]interface{}, bool, bool, []models.MissingPattern) {
+	result := make(map[string]interface{})
+	wrongPage := false
+	invalid := false
+	var missing []models.MissingPattern
+
+	if pattern, ok := item["pattern"]; ok {
+		selected, found := jsonpath.Get(data, pattern.(string))
+		if !found {
+			//can't find the path, return wrong page
+			wrongPage = true
+			missing = append(missing, models.MissingPattern{Path: path, Selector: pattern.(string)})
+		} else {
+			//obtain value
+			if val, ok := item["value"]; ok && val != "" {
+				var value string
+				value, invalid = processValue(item, strings.TrimSpace(jsonpath.String(selected)), parentValue)
+				if !invalid {
+					result["value"] = value
+				}
+			}
+
+			//obtain children
+			if children, ok := item["children"]; ok {
+				for index, element := range jsonItems(selected) {
+					if wrongPage || invalid {
+						break
+					}
+					childResult, wrongPageChild, invalidChild, missingChild := parseJSONPattern(element, children.(map[string]interface{}), parentValue, path)
+					if wrongPageChild {
+						wrongPage = wrongPageChild
+						missing = append(missing, missingChild...)
+					} else if !invalidChild {
+						result[strconv.Itoa(index)] = childResult
+					} else {
+						invalid = invalidChild
+						result = make(map[string]interface{})
+					}
+				}
+			}
+		}
+	} else {
+		for key, value := range item {
+			//keep checking the other keys on a wrong page so every missing path is reported
+			if wrongPage || !invalid {
+				childPath := key
+				if path != "" {
+					childPath = path + "." + key
+				}
+				childResult, wrongPageChild, invalidChild, missingChild := parseJSONPattern(data, value.(map[string]interface{}), parentValue, childPath)
+				if wrongPageChild {
+					wrongPage = wrongPageChild
+					missing = append(missing, missingChild...)
+				} else if !invalidChild {
+					result[key] = childResult
+				} else {
+					invalid = invalidChild
+					result = make(map[string]interface{})
+				}
+			}
+		}
+	}
+
+	if len(result) <= 0 {
+		invalid = true
+	}
+
+	return result, wrongPage, invalid, missing
+}
@@ -0,0 +1,67 @@
+package scraper
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sporule/grater/models"
+)
+
+func TestParseJSONPattern(t *testing.T) {
+	var data, pattern map[string]interface{}
+	assert.Nil(t, json.Unmarshal([]byte(`{"product":{"name":"PS5","price":449.99,"tags":["new","boxed"]}}`), &data))
+	assert.Nil(t, json.Unmarshal([]byte(`{
+		"name": {"pattern": "product.name", "value": "text"},
+		"price": {"pattern": "product.price", "value": "text", "validation": {"equation": "300 <= value", "targetValue": "value"}},
+		"tags": {"pattern": "product.tags", "children": {"tag": {"pattern": "@this", "value": "text"}}}
+	}`), &pattern))
+	value, wrongPage, invalid, _ := parseJSONPattern(data, pattern, "", "")
+	assert.False(t, wrongPage)
+	assert.False(t, invalid)
+	assert.Equal(t, "449.99", value["price"].(map[string]interface{})["value"])
+	assert.Equal(t, "boxed", value["tags"].(map[string]interface{})["1"].(map[string]interface{})["tag"].(map[string]interface{})["value"], "children should iterate over the array")
+
+	pattern["seller"] = map[string]interface{}{"pattern": "product.seller.name", "value": "text"}
+	_, wrongPage, _, missing := parseJSONPattern(data, pattern, "", "")
+	assert.True(t, wrongPage)
+	assert.Equal(t, []models.MissingPattern{{Path: "seller", Selector: "product.seller.name"}}, missing)
+}
+
+func TestScrapeOnceJSON(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path + "?" + r.URL.RawQuery {
+		case "/items?cursor=":
+			w.Write([]byte(`{"items":[{"name":"PS5","url":"/items/1"}],"next":"b c"}`))
+		case "/items?cursor=b+c":
+			w.Write([]byte(`{"items":[{"name":"Xbox","url":"/items/2"}],"next":null}`))
+		case "/html?":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte(`<html><body>maintenance</body></html>`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	rule := models.Rule{
+		ID:           "rule1",
+		Name:         "Consoles",
+		ResponseType: "json",
+		LinkPattern:  server.URL + "/items?cursor={cursor}",
+		Cursor:       "next",
+		Pattern:      `{"names":{"pattern":"items","children":{"name":{"pattern":"name","value":"text"}}}}`,
+	}
+
+	report, err := ScrapeOnce(rule, []string{server.URL + "/items?cursor=", server.URL + "/html"})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(report.Records), "the next page should be scraped")
+	var record map[string]interface{}
+	assert.Nil(t, json.Unmarshal(report.Records[1], &record))
+	assert.Equal(t, "Xbox", record["names"].([]interface{})[0].(map[string]interface{})["name"].(map[string]interface{})["value"])
+	assert.Equal(t, 1, len(report.LayoutErrors), "a response that is not json should be a layout error")
+	assert.Equal(t, server.URL+"/html", report.LayoutErrors[0].Link)
+}
@@ -0,0 +1,77 @@
+package scraper
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/sporule/grater/models"
+	"github.com/sporule/grater/modules/config"
+)
+
+//OnceReport is what ScrapeOnce saw, records are in the same format as the saved results
+type OnceReport struct {
+	Records      []json.RawMessage    `json:"records"`
+	LayoutErrors []models.LayoutError `json:"layoutErrors,omitempty"`
+	//Failed is the error of the links that couldn't be downloaded
+	Failed map[string]string `json:"failed,omitempty"`
+}
+
+//onceReport collects the failed requests of ScrapeOnce
+type onceReport struct {
+	failed map[string]string
+	mutex  sync.Mutex
+}
+
+func (report *onceReport) fail(link string, statusCode int, err error) {
+	report.mutex.Lock()
+	defer report.mutex.Unlock()
+	report.failed[link] = strconv.Itoa(statusCode) + " " + err.Error()
+}
+
+//ScrapeOnce scrapes the links with the rule locally without the distributor, the database or proxies, pages are not retried so it is meant for developing rules
+func ScrapeOnce(rule models.Rule, links []string) (*OnceReport, error) {
+	//the second round visits the deep links found on the first round
+	scraper, err := scrapeLocally(rule, links, 2, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return scraper.report(), nil
+}
+
+//scrapeLocally runs the rounds of ScrapeOnce, the pages are requested with the replay transport if it is set
+//and the parent values of the deep links are set before the first round
+func scrapeLocally(rule models.Rule, links []string, rounds int, replay http.RoundTripper, parentLinks map[string]string) (*scraper, error) {
+	//one thread keeps the records in the order of the links
+	scraper, _ := new("scrape-once", config.Scraper{Threads: 1, WritePageLayoutError: true})
+	scraper.rule = rule
+	scraper.useProxy = false
+	scraper.once = &onceReport{failed: make(map[string]string)}
+	scraper.replay = replay
+	scraper.pendingLinks = links
+	for link, value := range parentLinks {
+		scraper.parentLinks[link] = value
+	}
+	for round := 0; round < rounds && len(scraper.pendingLinks) > 0; round++ {
+		if err := scraper.setCollector(); err != nil {
+			return nil, err
+		}
+		if err := scraper.setLinksQueue(); err != nil {
+			return nil, err
+		}
+		if err := scraper.queue.Run(scraper.collector); err != nil {
+			return nil, err
+		}
+	}
+	return scraper, nil
+}
+
+//report returns the records, the layout errors and the failed links of a local run
+func (scraper *scraper) report() *OnceReport {
+	report := &OnceReport{Records: []json.RawMessage{}, LayoutErrors: scraper.pageLayoutErrors, Failed: scraper.once.failed}
+	for _, record := range scraper.scrapedRecords {
+		report.Records = append(report.Records, json.RawMessage(record.Content))
+	}
+	return report
+}
@@ -0,0 +1,209 @@
+package scraper
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gocolly/colly"
+	"github.com/sporule/grater/models"
+	"github.com/sporule/grater/modules/logger"
+	"github.com/sporule/grater/modules/utility"
+)
+
+//pageIDKey keeps the id of the stored page in the context of the request until the record is added
+const pageIDKey = "pageID"
+
+//pageHeader is set on the stored pages that are extracted again, so their results keep the id of the page
+const pageHeader = "X-Grater-Page"
+
+//reextractBatch is the most pages extracted in one run, a link is only extracted once per run
+const reextractBatch = 100
+
+//storePage keeps the body of the page until the next save if the pages are stored,
+//the unchanged pages are not stored again because they have no results
+func (scraper *scraper) storePage(r *colly.Response) {
+	if id := r.Headers.Get(pageHeader); id != "" {
+		r.Ctx.Put(pageIDKey, id)
+		return
+	}
+	if !scraper.config.StorePages || scraper.unchanged(r) {
+		return
+	}
+	link := r.Request.URL.String()
+	scraper.parentLinksMutex.RLock()
+	parentValue := scraper.parentLinks[link]
+	scraper.parentLinksMutex.RUnlock()
+	page, err := models.NewPage(scraper.rule.ID, link, parentValue, r.Headers.Get("Content-Type"), r.Body)
+	if err != nil {
+		scraper.linkLogger(r.Request).Warn("Unable to store the page", "error", err)
+		return
+	}
+	scraper.pagesMutex.Lock()
+	scraper.pages = append(scraper.pages, page)
+	scraper.pagesMutex.Unlock()
+	r.Ctx.Put(pageIDKey, page.ID)
+}
+
+//savePages inserts the stored pages, they are kept and inserted again on the next save if it fails
+func (scraper *scraper) savePages() error {
+	scraper.pagesMutex.Lock()
+	defer scraper.pagesMutex.Unlock()
+	if len(scraper.pages) <= 0 {
+		return nil
+	}
+	if err := models.InsertPages(scraper.pages); err != nil {
+		return err
+	}
+	scraper.pages = nil
+	return nil
+}
+
+//pageTransport answers the requests with the stored pages, the links that aren't stored fail
+type pageTransport map[string]*models.Page
+
+//pageID returns the id of the stored page of the link, it is empty if the link is not stored
+func (transport pageTransport) pageID(link string) string {
+	if page, ok := transport[link]; ok {
+		return page.ID
+	}
+	return ""
+}
+
+//RoundTrip implements http.RoundTripper
+func (transport pageTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	page, ok := transport[req.URL.String()]
+	if !ok {
+		return nil, errors.New("the page is not stored")
+	}
+	body, err := page.Body()
+	if err != nil {
+		return nil, err
+	}
+	header := http.Header{}
+	header.Set("Content-Type", page.ContentType)
+	header.Set(pageHeader, page.ID)
+	return &http.Response{
+		Status:        strconv.Itoa(http.StatusOK) + " " + http.StatusText(http.StatusOK),
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          ioutil.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+//Reextract runs the current pattern of the rule over the stored pages of the job and replaces the results of the pages,
+//nothing is requested so it can run on the distributor, the progress is saved to the job after every batch
+func Reextract(job *models.ReextractJob) error {
+	log := logger.New("component", "reextract").With("job", job.ID, "rule", job.RuleID)
+	err := reextract(job, log)
+	if err != nil {
+		job.Status = utility.Enums().Status.Failed
+		job.Error = err.Error()
+		log.Error("Re-extraction failed", "error", err)
+	} else {
+		job.Status = utility.Enums().Status.Completed
+		log.Info("Re-extraction completed", "pages", job.Pages, "records", job.Records, "layoutErrors", job.LayoutErrors, "failed", job.Failed)
+	}
+	if saveErr := job.Upsert(); saveErr != nil {
+		log.Error("Unable to save the re-extraction job", "error", saveErr)
+	}
+	return err
+}
+
+func reextract(job *models.ReextractJob, log *logger.Logger) error {
+	rule, err := models.GetRule(job.RuleID)
+	if err != nil {
+		return err
+	}
+	job.RuleVersion = rule.Version
+	var batch []models.Page
+	links := make(map[string]bool)
+	run := func() error {
+		if len(batch) <= 0 {
+			return nil
+		}
+		if err := reextractPages(*rule, batch, job); err != nil {
+			return err
+		}
+		log.Debug("Re-extracted pages", "pages", job.Pages, "records", job.Records)
+		batch = nil
+		links = make(map[string]bool)
+		return job.Upsert()
+	}
+	err = models.StreamPages(rule.ID, job.From, job.To, func(page models.Page) error {
+		//the transport answers a link with one page, so a link that is fetched again starts a new batch
+		if links[page.Link] || len(batch) >= reextractBatch {
+			if err := run(); err != nil {
+				return err
+			}
+		}
+		links[page.Link] = true
+		batch = append(batch, page)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return run()
+}
+
+//reextractPages extracts the pages in one local run and replaces their results,
+//the results keep the time the page was fetched so the exports by time are not changed,
+//pages without records, with a layout error or that failed keep their old results so a broken pattern doesn't erase them
+func reextractPages(rule models.Rule, pages []models.Page, job *models.ReextractJob) error {
+	transport := pageTransport{}
+	parentLinks := make(map[string]string)
+	fetched := make(map[string]time.Time)
+	links := make([]string, len(pages))
+	for i := range pages {
+		page := &pages[i]
+		transport[page.Link] = page
+		if page.ParentValue != "" {
+			parentLinks[page.Link] = page.ParentValue
+		}
+		fetched[page.ID] = page.LastUpdate
+		links[i] = page.Link
+	}
+	scraper, err := scrapeLocally(rule, links, 1, transport, parentLinks)
+	if err != nil {
+		return err
+	}
+	broken := make(map[string]bool)
+	for _, layoutError := range scraper.pageLayoutErrors {
+		broken[transport.pageID(layoutError.Link)] = true
+	}
+	for link := range scraper.once.failed {
+		broken[transport.pageID(link)] = true
+	}
+	var pageIDs []string
+	var results []*models.Result
+	replaced := make(map[string]bool)
+	for _, result := range scraper.scrapedRecords {
+		lastUpdate, ok := fetched[result.PageID]
+		if !ok || broken[result.PageID] {
+			continue
+		}
+		result.LastUpdate = lastUpdate
+		results = append(results, result)
+		if !replaced[result.PageID] {
+			replaced[result.PageID] = true
+			pageIDs = append(pageIDs, result.PageID)
+		}
+	}
+	if err := models.ReplaceResults(rule.TargetLocation, pageIDs, results); err != nil {
+		return err
+	}
+	job.Pages += int64(len(pages))
+	job.Records += int64(len(results))
+	job.LayoutErrors += int64(len(scraper.pageLayoutErrors))
+	job.Failed += int64(len(scraper.once.failed))
+	return nil
+}
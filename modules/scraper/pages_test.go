@@ -0,0 +1,97 @@
+package scraper
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/gocolly/colly"
+	"github.com/sporule/grater/models"
+	"github.com/sporule/grater/modules/config"
+	"github.com/sporule/grater/modules/database"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStorePage(t *testing.T) {
+	scraper, err := new("scraper1", config.Scraper{StorePages: true})
+	assert.Nil(t, err)
+	scraper.rule = models.Rule{ID: "rule1"}
+	scraper.updateParentLinks("https://example.com/item/1", "3")
+	page, _ := url.Parse("https://example.com/item/1")
+	ctx := colly.NewContext()
+	r := &colly.Response{
+		StatusCode: http.StatusOK,
+		Body:       []byte("<html><h1>PS5</h1></html>"),
+		Ctx:        ctx,
+		Headers:    &http.Header{"Content-Type": {"text/html"}},
+		Request:    &colly.Request{URL: page, Method: http.MethodGet, Ctx: ctx},
+	}
+	scraper.storePage(r)
+	assert.Equal(t, 1, len(scraper.pages))
+	assert.Equal(t, scraper.pages[0].ID, ctx.Get(pageIDKey), "the results of the page should be linked to it")
+	assert.Equal(t, "3", scraper.pages[0].ParentValue)
+	body, err := scraper.pages[0].Body()
+	assert.Nil(t, err)
+	assert.Equal(t, "<html><h1>PS5</h1></html>", string(body))
+
+	r.Headers.Set(pageHeader, "page1")
+	scraper.storePage(r)
+	assert.Equal(t, 1, len(scraper.pages), "a page that is extracted again should not be stored again")
+	assert.Equal(t, "page1", ctx.Get(pageIDKey))
+}
+
+func TestReextractPages(t *testing.T) {
+	rule := models.Rule{
+		ID:               "rule1",
+		Name:             "PS5",
+		Version:          2,
+		Pattern:          `{"name":{"pattern":"h1","value":"text"},"price":{"pattern":"span.price","value":"text","postprocess":{"replace":"£,"}}}`,
+		DeepLinkPatterns: "div.item,span.bids,a",
+	}
+	list, _ := models.NewPage("rule1", "https://example.com/list", "", "text/html", []byte(`<html><body><div class="item"><span class="bids">3</span><a href="/item/1">PS5</a></div></body></html>`))
+	item, _ := models.NewPage("rule1", "https://example.com/item/1", "3", "text/html; charset=utf-8", []byte(`<html><body><h1>PS5</h1><span class="price">£450</span></body></html>`))
+	transport := pageTransport{list.Link: list, item.Link: item}
+
+	scraper, err := scrapeLocally(rule, []string{item.Link, list.Link, "https://example.com/item/2"}, 1, transport, map[string]string{item.Link: item.ParentValue})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(scraper.scrapedRecords), "the list page should only queue the deep link")
+	result := scraper.scrapedRecords[0]
+	assert.Equal(t, item.ID, result.PageID, "the result should keep the id of the stored page so it can be replaced")
+	assert.Equal(t, 2, result.RuleVersion, "the result should be extracted with the current version of the rule")
+	var record map[string]interface{}
+	assert.Nil(t, json.Unmarshal([]byte(result.Content), &record))
+	assert.Equal(t, "450", record["price"].(map[string]interface{})["value"])
+	assert.Contains(t, scraper.once.failed["https://example.com/item/2"], "not stored")
+	assert.Equal(t, 1, len(scraper.pendingLinks), "the deep link is queued but the single round doesn't request it")
+}
+
+func TestReextractPagesKeepsBrokenPages(t *testing.T) {
+	db := &fakeDatabase{inserted: map[string]int{}, upserted: map[string]int{}, failing: map[string]bool{}}
+	previous := database.Client
+	database.Client = db
+	defer func() { database.Client = previous }()
+
+	rule := models.Rule{ID: "rule1", TargetLocation: "PS5", Pattern: `{"name":{"pattern":"h1","value":"text"}}`}
+	good, _ := models.NewPage("rule1", "https://example.com/item/1", "", "text/html", []byte(`<html><body><h1>PS5</h1></body></html>`))
+	broken, _ := models.NewPage("rule1", "https://example.com/item/2", "", "text/html", []byte(`<html><body><h2>PS5</h2></body></html>`))
+	job := &models.ReextractJob{}
+	assert.Nil(t, reextractPages(rule, []models.Page{*good, *broken}, job))
+	assert.Equal(t, 1, db.inserted["PS5"])
+	assert.Equal(t, int64(1), job.Records)
+	assert.Equal(t, int64(1), job.LayoutErrors)
+	assert.Equal(t, 1, len(db.deleted))
+	assert.Equal(t, []string{good.ID}, db.deleted[0]["pageid"], "the page with a layout error should keep its old results")
+	assert.NotNil(t, db.deleted[0]["_id"], "the new results should not be deleted")
+
+	//the old results are kept if the new ones can't be inserted
+	db.failing["PS5"] = true
+	db.deleted = nil
+	assert.NotNil(t, reextractPages(rule, []models.Page{*good}, job))
+	assert.Empty(t, db.deleted)
+
+	//a pattern that is still broken replaces nothing
+	db.failing["PS5"] = false
+	assert.Nil(t, reextractPages(rule, []models.Page{*broken}, job))
+	assert.Empty(t, db.deleted)
+}
@@ -1,17 +1,17 @@
 package scraper
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"go/token"
 	"go/types"
 	"io/ioutil"
-	"log"
 	"math"
 	"math/rand"
 	"net/http"
 	"net/url"
+	"sort"
 	"sync"
 	"time"
 
@@ -22,10 +22,16 @@ import (
 	"github.com/gocolly/colly"
 	"github.com/gocolly/colly/extensions"
 	"github.com/gocolly/colly/queue"
-	"github.com/google/uuid"
 
 	"github.com/sporule/grater/models"
+	"github.com/sporule/grater/modules/client"
+	"github.com/sporule/grater/modules/config"
+	"github.com/sporule/grater/modules/health"
+	"github.com/sporule/grater/modules/httpcache"
+	"github.com/sporule/grater/modules/logger"
+	"github.com/sporule/grater/modules/metrics"
 	"github.com/sporule/grater/modules/utility"
+	"github.com/sporule/grater/modules/warc"
 )
 
 //scraper is the struct for scraper
@@ -36,10 +42,17 @@ type scraper struct {
 	rule                     models.Rule
 	queue                    *queue.Queue
 	receviedLinkIDs          []string
-	scrapedRecords           []string
-	pageLayoutErrors         []string
+	scrapedRecords           []*models.Result
+	pageLayoutErrors         []models.LayoutError
+	layoutSnapshots          []models.LayoutSnapshot
+	savedSnapshots           map[string]bool
+	layoutErrorsMutex        sync.Mutex
+	stats                    models.RuleStatTotals
+	statsMutex               sync.Mutex
 	parentLinks              map[string]string
 	parentLinksMutex         sync.RWMutex
+	cursorLinks              map[string]bool
+	cursorLinksMutex         sync.Mutex
 	headers                  map[string]string
 	cookie                   string
 	cookiesJar               []string
@@ -50,6 +63,15 @@ type scraper struct {
 	pendingLinks             []string
 	previousPendingLinksSize int
 	failedTimes              int
+	logger                   *logger.Logger
+	linkIDs                  map[string]string
+	config                   config.Scraper
+	once                     *onceReport
+	cache                    httpcache.Store
+	archive                  *archiver
+	replay                   http.RoundTripper
+	pages                    []*models.Page
+	pagesMutex               sync.Mutex
 }
 
 func (scraper *scraper) updateParentLinks(link, value string) {
@@ -59,45 +81,154 @@ func (scraper *scraper) updateParentLinks(link, value string) {
 }
 
 //new creates new scraper
-func new(id string) (*scraper, error) {
-
+func new(id string, config config.Scraper) (*scraper, error) {
+	cache, err := httpcache.New(config.Cache, config.CacheDir)
+	if err != nil {
+		return nil, err
+	}
 	return &scraper{
-		id:          id,
-		parentLinks: make(map[string]string),
-		useProxy:    true,
+		id:             id,
+		config:         config,
+		parentLinks:    make(map[string]string),
+		cursorLinks:    make(map[string]bool),
+		useProxy:       true,
+		logger:         logger.New("scraper", id),
+		linkIDs:        make(map[string]string),
+		savedSnapshots: make(map[string]bool),
+		cache:          cache,
 	}, nil
 }
 
+//saveScrapedRecords saves the stats, the layout errors and the results, the steps don't depend on each other
+//so the layout errors are saved when a redesign leaves no record or the results fail, the first error is returned
 func (scraper *scraper) saveScrapedRecords() error {
-	//save records
-	if len(scraper.scrapedRecords) <= 0 {
+	var firstErr error
+	for _, save := range []func() error{scraper.saveStats, scraper.saveLayoutErrors, scraper.saveResults} {
+		if err := save(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+//saveResults saves the records, the archive and the pages are saved first so the results never link to a missing page
+func (scraper *scraper) saveResults() error {
+	records := scraper.scrapedRecords
+	if scraper.archive != nil {
+		if err := scraper.archive.flush(); err != nil {
+			return err
+		}
+	}
+	if err := scraper.savePages(); err != nil {
+		return err
+	}
+	if len(records) <= 0 {
 		return nil
 	}
-	err := models.InsertManyResults(scraper.rule.TargetLocation, scraper.scrapedRecords)
-	if err != nil {
+	if err := models.InsertManyResults(scraper.rule.TargetLocation, records); err != nil {
 		return err
 	}
-	scraper.scrapedRecords = make([]string, 0)
+	scraper.scrapedRecords = scraper.scrapedRecords[len(records):]
+	return nil
+}
 
-	//save page layouts
+//saveLayoutErrors saves the page layout errors and their snapshots, they are kept for the next save if it fails
+func (scraper *scraper) saveLayoutErrors() error {
+	scraper.layoutErrorsMutex.Lock()
+	defer scraper.layoutErrorsMutex.Unlock()
 	if len(scraper.pageLayoutErrors) <= 0 {
 		return nil
 	}
-	err = models.InsertManyResults("PageLayoutError", scraper.pageLayoutErrors)
-	if err != nil {
+	if err := models.InsertLayoutErrors(scraper.pageLayoutErrors, scraper.layoutSnapshots); err != nil {
 		return err
 	}
-	scraper.pageLayoutErrors = make([]string, 0)
+	for _, snapshot := range scraper.layoutSnapshots {
+		scraper.savedSnapshots[snapshot.ID] = true
+	}
+	scraper.pageLayoutErrors = make([]models.LayoutError, 0)
+	scraper.layoutSnapshots = make([]models.LayoutSnapshot, 0)
+	return nil
+}
+
+//addStats counts what the scraper saw since the last save
+func (scraper *scraper) addStats(pages, records, layoutErrors, validationFailures int64) {
+	scraper.statsMutex.Lock()
+	defer scraper.statsMutex.Unlock()
+	scraper.stats.Pages += pages
+	scraper.stats.Records += records
+	scraper.stats.LayoutErrors += layoutErrors
+	scraper.stats.ValidationFailures += validationFailures
+}
+
+//addCacheStats counts the cached request, hit is true if the page is unchanged
+func (scraper *scraper) addCacheStats(hit bool) {
+	scraper.statsMutex.Lock()
+	defer scraper.statsMutex.Unlock()
+	result := httpcache.Miss
+	if hit {
+		result = httpcache.Hit
+		scraper.stats.CacheHits++
+	} else {
+		scraper.stats.CacheMisses++
+	}
+	metrics.CacheRequests.WithLabelValues(scraper.rule.Name, result).Inc()
+}
 
+//unchanged checks the page is unchanged since it was cached and it shouldn't be parsed again
+func (scraper *scraper) unchanged(r *colly.Response) bool {
+	return scraper.config.SkipUnchanged && r.Headers.Get(httpcache.Header) == httpcache.Hit
+}
+
+//saveStats saves the counters as a rule stat and resets them, nothing is saved if no page was parsed
+func (scraper *scraper) saveStats() error {
+	scraper.statsMutex.Lock()
+	defer scraper.statsMutex.Unlock()
+	if scraper.stats.Pages <= 0 && scraper.stats.CacheHits <= 0 {
+		return nil
+	}
+	stat := models.NewRuleStat(scraper.rule.ID, scraper.id, scraper.stats.Pages, scraper.stats.Records, scraper.stats.LayoutErrors, scraper.stats.ValidationFailures)
+	stat.CacheHits = scraper.stats.CacheHits
+	stat.CacheMisses = scraper.stats.CacheMisses
+	if err := stat.Insert(); err != nil {
+		return err
+	}
+	scraper.stats = models.RuleStatTotals{}
+	return nil
+}
+
+//addLayoutError keeps the layout error until the next save, the html is only sent once per scraper if it is unchanged
+func (scraper *scraper) addLayoutError(r *colly.Response, missing []models.MissingPattern) error {
+	sort.Slice(missing, func(i, j int) bool {
+		return missing[i].Path < missing[j].Path
+	})
+	layoutError, snapshot, err := models.NewLayoutError(scraper.rule.ID, r.Request.URL.String(), proxyID(r.Request.ProxyURL), r.StatusCode, missing, string(r.Body))
+	if err != nil {
+		return err
+	}
+	scraper.layoutErrorsMutex.Lock()
+	defer scraper.layoutErrorsMutex.Unlock()
+	scraper.pageLayoutErrors = append(scraper.pageLayoutErrors, *layoutError)
+	if !scraper.savedSnapshots[snapshot.ID] {
+		scraper.savedSnapshots[snapshot.ID] = true
+		scraper.layoutSnapshots = append(scraper.layoutSnapshots, *snapshot)
+	}
 	return nil
 }
 
+//proxyID returns the host and port of the proxy without credentials
+func proxyID(proxy string) string {
+	if parsed, err := url.Parse(proxy); err == nil {
+		return parsed.Host
+	}
+	return ""
+}
+
 func (scraper *scraper) changeProfile(isProxy, isCookies bool) {
 	scraper.profileChangedMutex.Lock()
 	scraper.failedRequests++
 	if isProxy {
 		for len(scraper.proxies) <= 1 {
-			log.Println("Waiting for proxy")
+			scraper.logger.Info("Waiting for proxy")
 			time.Sleep(20 * time.Second)
 		}
 	}
@@ -114,9 +245,12 @@ func (scraper *scraper) changeProfile(isProxy, isCookies bool) {
 			//reset proxies
 			scraper.proxies = scraper.proxies[1:]
 			scraper.profileChangedTimeStamp = time.Now()
+			metrics.ProxyBans.WithLabelValues(scraper.rule.Name).Inc()
+			metrics.ProxyPoolSize.WithLabelValues(scraper.rule.Name).Set(float64(len(scraper.proxies)))
+			scraper.reportProxyPool()
 		}
 
-		log.Println("Profile Changed, proxies:", len(scraper.proxies), "cookies:", len(scraper.cookiesJar), "failed requests:", scraper.failedRequests)
+		scraper.logger.Info("Profile changed", "proxies", len(scraper.proxies), "cookies", len(scraper.cookiesJar), "failedRequests", scraper.failedRequests)
 		scraper.failedRequests = 0
 	}
 	scraper.profileChangedMutex.Unlock()
@@ -124,7 +258,6 @@ func (scraper *scraper) changeProfile(isProxy, isCookies bool) {
 
 func (scraper *scraper) addCookiesToJar(cookies ...string) {
 	scraper.cookie += cookies[0]
-	//log.Println("Latest Cookies:", scraper.cookie)
 }
 
 func (scraper *scraper) getCookie() string {
@@ -132,9 +265,8 @@ func (scraper *scraper) getCookie() string {
 }
 
 func (scraper *scraper) setProxies() error {
-	log.Println("Getting Proxies")
-	proxyAPIStr := utility.GetEnv("PROXY_API", "socks5-grater-https://api.proxyscrape.com/v2/?request=getproxies&protocol=socks5&timeout=10000&country=all")
-	proxyAPISlice := strings.Split(proxyAPIStr, "-grater-")
+	scraper.logger.Info("Getting proxies")
+	proxyAPISlice := strings.Split(scraper.config.ProxyAPI, "-grater-")
 	if len(proxyAPISlice) <= 1 {
 		return errors.New("Can't read proxy configuration, disabling proxy")
 	}
@@ -144,17 +276,19 @@ func (scraper *scraper) setProxies() error {
 	if utility.IsNil(proxyProtocol, proxyLink, testLink) {
 		return errors.New("Can't read proxy configuration, disabling proxy")
 	}
-	proxies, cookies, err := getProxies(proxyLink, proxyProtocol, testLink)
+	proxies, cookies, err := getProxies(scraper.logger, proxyLink, proxyProtocol, testLink)
 	if err != nil {
 		return err
 	}
 	scraper.proxies = proxies
 	scraper.cookiesJar = cookies
-	log.Println("Proxy obtained, size:", len(proxies), "cookies, size:", len(cookies))
+	metrics.ProxyPoolSize.WithLabelValues(scraper.rule.Name).Set(float64(len(proxies)))
+	scraper.reportProxyPool()
+	scraper.logger.Info("Proxy obtained", "proxies", len(proxies), "cookies", len(cookies))
 	return nil
 }
 
-func getProxies(link, proxyType, testLink string) (fullProxies []string, cookies []string, err error) {
+func getProxies(log *logger.Logger, link, proxyType, testLink string) (fullProxies []string, cookies []string, err error) {
 	for len(fullProxies) <= 1 {
 		res, err := http.Get(link)
 		if res.Body != nil {
@@ -162,15 +296,15 @@ func getProxies(link, proxyType, testLink string) (fullProxies []string, cookies
 		}
 		body, err := ioutil.ReadAll(res.Body)
 		if err != nil {
-			log.Println("Unable to obtain Proxy")
+			log.Error("Unable to obtain proxy", "error", err)
 			return nil, nil, err
 		}
 		proxies := strings.Split(string(body), "\r\n")
 		if len(proxies) <= 1 {
 			proxies = strings.Split(string(body), "\n")
 		}
-		validatedProxies, cookies := proxyCheck(proxies, proxyType, testLink)
-		log.Println("Proxies:", len(proxies), "Validated:", len(validatedProxies), "Cookies:", len(cookies))
+		validatedProxies, cookies := proxyCheck(log, proxies, proxyType, testLink)
+		log.Info("Proxies validated", "proxies", len(proxies), "validated", len(validatedProxies), "cookies", len(cookies))
 		for _, proxy := range validatedProxies {
 			fullProxies = append(fullProxies, proxyType+"://"+proxy)
 		}
@@ -183,105 +317,70 @@ func getProxies(link, proxyType, testLink string) (fullProxies []string, cookies
 	return nil, nil, nil
 }
 
+//distributor returns the client of the distributor api
+func (scraper *scraper) distributor() *client.Client {
+	return client.New(scraper.config.DistributorAPI, scraper.config.APIKey)
+}
+
 func (scraper *scraper) setLinksToComplete() error {
-	if api := utility.GetEnv("DISTRIBUTOR_API", "http://localhost:9999/api/v1/dist"); !utility.IsNil(api) {
-		body, err := json.Marshal(map[string][]string{
-			"linkids": scraper.receviedLinkIDs,
-		})
-		if err != nil {
-			return errors.New("Error on parsing completed link IDs")
-		}
-		_, err = http.Post(api+"/links", "application/json", bytes.NewBuffer(body))
-		if err == nil {
-			//reset the completedLinkIDs
-			scraper.receviedLinkIDs = make([]string, 0)
-			return nil
-		}
-	} else {
-		return errors.New("API Not found")
+	if err := scraper.distributor().CompleteLinks(scraper.receviedLinkIDs); err != nil {
+		//keep the link ids so they can be sent again
+		return err
 	}
+	//reset the completedLinkIDs
+	scraper.receviedLinkIDs = make([]string, 0)
 	return nil
 }
 
 func (scraper *scraper) setRule() error {
-	if api := utility.GetEnv("DISTRIBUTOR_API", "http://localhost:9999/api/v1/dist"); !utility.IsNil(api) {
-		//obtain the highest priority queue
-		res, err := http.Get(api + "/rules?isscraper=1")
-		if err != nil {
-			log.Println("Unable to make request to obtain rules", err)
-			return err
-		}
-		if res.Body != nil {
-			defer res.Body.Close()
-		}
-		body, err := ioutil.ReadAll(res.Body)
-		if err != nil {
-			log.Println("Unable to read rules", err)
-			return err
-		}
-		var rules []models.Rule
-		err = json.Unmarshal(body, &rules)
-		if err != nil {
-			log.Println("Unable to parse the returned queue result", err)
-			return err
-		}
-		if len(rules) <= 0 {
-			log.Println("Unable to find any rules")
-			return errors.New("Unable to find any rules")
-		}
-		rule := rules[0]
-		if utility.IsNil(rule.ID, rule.Pattern, rule.TargetLocation) {
-			log.Println("Unable to read rule information")
-			return errors.New("Unable to read rule information.")
-		}
-		scraper.rule = rule
-	} else {
-		return errors.New("API Not found")
+	//obtain the highest priority queue
+	rule, err := scraper.distributor().GetScraperRule()
+	switch {
+	case utility.IsErrorCode(err, utility.Enums().ErrorCodes.NotFound):
+		scraper.logger.Info("There is no rule with active links")
+		return err
+	case utility.IsErrorCode(err, utility.Enums().ErrorCodes.Unauthorized), utility.IsErrorCode(err, utility.Enums().ErrorCodes.Forbidden):
+		scraper.logger.Error("The distributor rejected the api key, please check API_KEY", "error", err)
+		return err
+	case err != nil:
+		scraper.logger.Error("Unable to obtain rules", "error", err)
+		return err
+	}
+	if utility.IsNil(rule.ID, rule.Pattern, rule.TargetLocation) {
+		scraper.logger.Error("Unable to read rule information", "rule", rule.ID)
+		return errors.New("Unable to read rule information.")
 	}
+	scraper.rule = *rule
+	domain := ""
+	if link, err := url.Parse(rule.LinkPattern); err == nil {
+		domain = link.Host
+	}
+	scraper.logger = scraper.logger.With("rule", rule.ID, "domain", domain)
 	return nil
 }
 
-func getLinks(ruleID string, scraperID string) (linkIDs, pendingLinks []string, err error) {
-	if api := utility.GetEnv("DISTRIBUTOR_API", "http://localhost:9999/api/v1/dist"); !utility.IsNil(api) {
-		//obtain the links
-		res, err := http.Get(api + "/links?ruleid=" + ruleID + "&scraper=" + scraperID)
-		if err != nil {
-			log.Println("Unable to make request to obtain links ", err)
-			return nil, nil, err
-		}
-		if res.Body != nil {
-			defer res.Body.Close()
-		}
-		body, err := ioutil.ReadAll(res.Body)
-		if err != nil {
-			log.Println("Unable to read links ", err)
-			return nil, nil, err
-		}
-		var links []models.Link
-		err = json.Unmarshal(body, &links)
-		if err != nil {
-			log.Println("Could not find links")
-			return nil, nil, err
-		}
-		for _, link := range links {
-			linkIDs = append(linkIDs, link.ID)
-			pendingLinks = append(pendingLinks, link.Link)
-		}
-	} else {
-		return nil, nil, errors.New("API Not found")
+func getLinks(log *logger.Logger, distributor *client.Client, ruleID string, scraperID string) (linkIDs, pendingLinks []string, err error) {
+	//obtain the links
+	links, err := distributor.AllocateLinks(ruleID, scraperID)
+	if utility.IsErrorCode(err, utility.Enums().ErrorCodes.NotFound) {
+		log.Info("There is no active link for the rule")
+		return nil, nil, err
+	}
+	if err != nil {
+		log.Error("Unable to obtain links", "error", err)
+		return nil, nil, err
+	}
+	for _, link := range links {
+		linkIDs = append(linkIDs, link.ID)
+		pendingLinks = append(pendingLinks, link.Link)
 	}
 	return linkIDs, pendingLinks, nil
 }
 
 func (scraper *scraper) setLinksQueue() error {
 	if len(scraper.pendingLinks) > 0 {
-		threadSizeStr := utility.GetEnv("THREADS", "20")
-		threadSize, err := strconv.Atoi(threadSizeStr)
-		if err != nil {
-			threadSize = 20
-		}
 		scraper.queue, _ = queue.New(
-			threadSize,
+			scraper.config.Threads,
 			&queue.InMemoryQueueStorage{MaxSize: 100000}, // Use default queue storage
 		)
 		for _, link := range scraper.pendingLinks {
@@ -296,7 +395,7 @@ func (scraper *scraper) setLinksQueue() error {
 func (scraper *scraper) addLinkToQueue(url string) {
 	if scraper.failedTimes > 10 {
 		//give up the url
-		log.Println("Giving up the link:", url)
+		scraper.logger.Warn("Giving up the link", "link", url, "linkID", scraper.linkIDs[url])
 		return
 	}
 	scraper.pendingLinks = append(scraper.pendingLinks, url)
@@ -306,9 +405,11 @@ func (scraper *scraper) setCollector() error {
 	c := colly.NewCollector(
 		colly.MaxDepth(2),
 	)
-	c.Limit(&colly.LimitRule{
-		RandomDelay: 10 * time.Second,
-	})
+	if scraper.once == nil {
+		c.Limit(&colly.LimitRule{
+			RandomDelay: 10 * time.Second,
+		})
+	}
 	extensions.RandomUserAgent(c)
 	c.DisableCookies()
 	c.IgnoreRobotsTxt = true
@@ -330,9 +431,13 @@ func (scraper *scraper) setCollector() error {
 			}
 		}
 		r.Headers.Set("cookie", scraper.getCookie())
+		r.Ctx.Put("start", time.Now())
 	})
 
 	c.OnHTML("body", func(e *colly.HTMLElement) {
+		if scraper.rule.IsJSON() || scraper.unchanged(e.Response) {
+			return
+		}
 		requestLink := e.Request.URL.String()
 		linkPatterns := strings.Split(scraper.rule.DeepLinkPatterns, ",")
 		if len(linkPatterns) >= 3 {
@@ -342,39 +447,7 @@ func (scraper *scraper) setCollector() error {
 				parentPageDom.Each(func(index int, elem *goquery.Selection) {
 					parentValue := elem.Find(linkPatterns[1]).First().Text()
 					link, _ := elem.Find(linkPatterns[2]).First().Attr("href")
-					if len(linkPatterns) >= 4 {
-						//remove query string
-						if linkPatterns[3] == "removeQueryString" {
-							link = strings.Split(link, "?")[0]
-						}
-					}
-					if len(linkPatterns) >= 5 {
-						//skip the link if it contains keyword in this list
-						if !strings.Contains(link, linkPatterns[4]) {
-							testLink := link[:4]
-							if strings.ToLower(testLink) != "http" {
-								if link[:1] == "/" {
-									link = e.Request.URL.Scheme + "://" + e.Request.URL.Host + link
-								} else {
-									link = e.Request.URL.Scheme + "://" + e.Request.URL.Host + "/" + link
-								}
-							}
-							scraper.updateParentLinks(link, parentValue)
-							scraper.addLinkToQueue(link)
-						} else {
-							log.Println("Not visting the link:", link, "because it contains:", linkPatterns[4])
-						}
-					} else {
-						if strings.ToLower(link[:4]) != "http" {
-							if link[:1] == "/" {
-								link = e.Request.URL.Scheme + "://" + e.Request.URL.Host + link
-							} else {
-								link = e.Request.URL.Scheme + "://" + e.Request.URL.Host + "/" + link
-							}
-						}
-						scraper.updateParentLinks(link, parentValue)
-						scraper.addLinkToQueue(link)
-					}
+					scraper.addDeepLink(e.Request, linkPatterns, link, parentValue)
 				})
 				return
 			}
@@ -382,51 +455,43 @@ func (scraper *scraper) setCollector() error {
 		var pattern map[string]interface{}
 		err := json.Unmarshal([]byte(scraper.rule.Pattern), &pattern)
 		if !utility.IsNil(err) {
-			log.Println("Cannot read the rule pattern", err)
+			scraper.linkLogger(e.Request).Error("Cannot read the rule pattern", "error", err)
 			return
 		}
-		value, isWrongPage, invalidPage := parsePattern(e.DOM, pattern, scraper.parentLinks[requestLink], true)
-		if isWrongPage {
-			if len(utility.GetEnv("WRITEPAGELAYOUTERROR", "")) > 0 {
-				html, _ := e.DOM.Html()
-				cookie := e.Request.Headers.Get("cookie")
-				scraper.pageLayoutErrors = append(scraper.pageLayoutErrors, requestLink+"*****"+cookie+"*****"+html)
-			}
-			//log.Println("Page layout not as expected,change cookie", requestLink)
-			//change cookie and proxy
-			scraper.changeProfile(true, true)
-			scraper.addLinkToQueue(e.Request.URL.String())
-			return
-		}
-		if !invalidPage {
-			value["link"] = requestLink
-			value["rule"] = scraper.rule.Name
-			jsonString, err := json.Marshal(value)
-			if err != nil {
-				log.Println("Validated data is not valid json")
-				return
-			}
-			scraper.scrapedRecords = append(scraper.scrapedRecords, string(jsonString))
-			//remove item from the map
-			delete(scraper.parentLinks, requestLink)
-			log.Println("Scraped Success:", value)
-		} else {
-			log.Println("Data recevied failed on validation", requestLink)
-		}
-
+		value, isWrongPage, invalidPage, missing := parsePattern(e.DOM, pattern, scraper.parentLinks[requestLink], true, "")
+		forgetStructuredData(e.DOM)
+		scraper.addRecord(e.Response, pattern, value, isWrongPage, invalidPage, missing)
 	})
 
 	c.OnResponse(func(r *colly.Response) {
+		observeRequest(r)
+		health.Beat(loopHeartbeat)
+		scraper.archiveResponse(r)
+		scraper.storePage(r)
 		cookie := getCookieFromRespList(r.Headers.Values("set-cookie"))
 		if !utility.IsNil(cookie) {
 			//get server cookie mannually
 			scraper.addCookiesToJar(cookie)
 		}
-
+		if cached := r.Headers.Get(httpcache.Header); cached != "" {
+			scraper.addCacheStats(cached == httpcache.Hit)
+			if scraper.unchanged(r) {
+				scraper.linkLogger(r.Request).Debug("Page is unchanged, skipped parsing")
+			}
+		}
+		//OnHTML only gets html so json responses are parsed here
+		if scraper.rule.IsJSON() && !scraper.unchanged(r) {
+			scraper.parseJSONResponse(r)
+		}
 	})
 
 	c.OnError(func(r *colly.Response, err error) {
-		//log.Println("Failed HTTP", r.StatusCode, err, r.Request.URL)
+		observeRequest(r)
+		scraper.linkLogger(r.Request).Debug("Request failed", "status", r.StatusCode, "error", err)
+		if scraper.once != nil {
+			scraper.once.fail(r.Request.URL.String(), r.StatusCode, err)
+			return
+		}
 		if r.StatusCode <= 10 {
 			scraper.changeProfile(true, false)
 		} else {
@@ -436,121 +501,194 @@ func (scraper *scraper) setCollector() error {
 	})
 
 	for len(scraper.proxies) <= 0 && scraper.useProxy {
-		log.Println("Waiting for the Proxy...")
+		scraper.logger.Info("Waiting for the proxy")
 		time.Sleep(20 * time.Second)
 	}
-	c.SetProxyFunc(scraper.proxySwitcher)
+	if scraper.replay != nil {
+		c.WithTransport(scraper.replay)
+	} else if scraper.cache != nil {
+		//colly can only set the proxy of a plain http transport, so the proxy is set on the transport the cache wraps
+		var next http.RoundTripper = http.DefaultTransport
+		if scraper.useProxy {
+			transport := http.DefaultTransport.(*http.Transport).Clone()
+			transport.Proxy = scraper.proxySwitcher
+			next = transport
+		}
+		c.WithTransport(&httpcache.Transport{Store: scraper.cache, Next: next, OnError: func(link string, err error) {
+			scraper.logger.Warn("HTTP cache failed", "link", link, "error", err)
+		}})
+	} else if scraper.useProxy {
+		c.SetProxyFunc(scraper.proxySwitcher)
+	}
 
 	//create scraper collector
 	scraper.collector = c
 	return nil
 }
 
+//linkLogger returns the logger with the link of the request, deep links don't have link id
+func (scraper *scraper) linkLogger(r *colly.Request) *logger.Logger {
+	link := r.URL.String()
+	return scraper.logger.With("link", link, "linkID", scraper.linkIDs[link])
+}
+
+//addDeepLink queues the link found on the parent page with the value of the parent,
+//the fourth deep link pattern removes the query string and the links with the fifth pattern in them are skipped
+func (scraper *scraper) addDeepLink(r *colly.Request, linkPatterns []string, link, parentValue string) {
+	if link == "" {
+		return
+	}
+	if len(linkPatterns) >= 4 {
+		//remove query string
+		if linkPatterns[3] == "removeQueryString" {
+			link = strings.Split(link, "?")[0]
+		}
+	}
+	if len(linkPatterns) >= 5 && strings.Contains(link, linkPatterns[4]) {
+		//skip the link if it contains keyword in this list
+		scraper.linkLogger(r).Debug("Not visiting the deep link because it contains the skip keyword", "deepLink", link, "keyword", linkPatterns[4])
+		return
+	}
+	link = absoluteLink(r.URL, link)
+	scraper.updateParentLinks(link, parentValue)
+	scraper.addLinkToQueue(link)
+}
+
+//absoluteLink adds the scheme and host of the page to the link if it isn't an http link
+func absoluteLink(page *url.URL, link string) string {
+	if strings.HasPrefix(strings.ToLower(link), "http") {
+		return link
+	}
+	if strings.HasPrefix(link, "/") {
+		return page.Scheme + "://" + page.Host + link
+	}
+	return page.Scheme + "://" + page.Host + "/" + link
+}
+
+//addRecord saves the parsed value of the page as a record, a wrong page is retried with another proxy and cookie
+//the values are converted to the data types of the pattern, a value that can't be converted fails the validation
+func (scraper *scraper) addRecord(r *colly.Response, pattern, value map[string]interface{}, isWrongPage, invalidPage bool, missing []models.MissingPattern) {
+	requestLink := r.Request.URL.String()
+	linkLogger := scraper.linkLogger(r.Request)
+	if !isWrongPage && !invalidPage {
+		var ok bool
+		if value, ok = typeRecord(value, pattern, r.Request.URL); !ok {
+			linkLogger.Debug("Value can't be converted to its data type")
+			invalidPage = true
+		}
+	}
+	if isWrongPage {
+		scraper.addStats(1, 0, 1, 0)
+		metrics.LayoutErrors.WithLabelValues(scraper.rule.Name).Inc()
+		if scraper.config.WritePageLayoutError {
+			if err := scraper.addLayoutError(r, missing); err != nil {
+				linkLogger.Error("Unable to capture the layout error", "error", err)
+			}
+		}
+		if scraper.once != nil {
+			//the page is not retried with another proxy when the rule is run once
+			return
+		}
+		linkLogger.Debug("Page layout not as expected, changing proxy and cookie", "missing", len(missing))
+		//change cookie and proxy
+		scraper.changeProfile(true, true)
+		scraper.addLinkToQueue(requestLink)
+		return
+	}
+	if !invalidPage {
+		value["link"] = requestLink
+		value["rule"] = scraper.rule.Name
+		jsonString, err := json.Marshal(value)
+		if err != nil {
+			linkLogger.Error("Validated data is not valid json", "error", err)
+			return
+		}
+		result, _ := models.NewResult(string(jsonString), scraper.rule.ID, scraper.rule.Version)
+		linkResult(result, r)
+		scraper.scrapedRecords = append(scraper.scrapedRecords, result)
+		scraper.addStats(1, 1, 0, 0)
+		metrics.RecordsScraped.WithLabelValues(scraper.rule.Name).Inc()
+		//remove item from the map
+		delete(scraper.parentLinks, requestLink)
+		linkLogger.Debug("Scraped record", "fields", len(value))
+	} else {
+		linkLogger.Info("Data received failed on validation")
+		scraper.addStats(1, 0, 0, 1)
+		metrics.ValidationFailures.WithLabelValues(scraper.rule.Name).Inc()
+	}
+}
+
+//observeRequest records the status and response time of the request
+func observeRequest(r *colly.Response) {
+	domain := r.Request.URL.Host
+	metrics.ScraperRequests.WithLabelValues(strconv.Itoa(r.StatusCode), domain).Inc()
+	if start, ok := r.Ctx.GetAny("start").(time.Time); ok {
+		metrics.ScraperRequestDuration.WithLabelValues(domain).Observe(time.Since(start).Seconds())
+	}
+}
+
 func (scraper *scraper) proxySwitcher(pr *http.Request) (*url.URL, error) {
 	for len(scraper.proxies) <= 0 {
-		log.Println("Proxy switcher is waiting for proxy, sleep for 5 seconds")
+		scraper.logger.Info("Proxy switcher is waiting for proxy, sleep for 5 seconds")
 		time.Sleep(5 * time.Second)
 	}
 	proxyStr := scraper.proxies[0] //always return the first proxy
 	proxy := &url.URL{Scheme: strings.Split(proxyStr, "://")[0], Host: strings.Split(proxyStr, "://")[1]}
+	//colly reads the proxy of the request from the context
+	*pr = *pr.WithContext(context.WithValue(pr.Context(), colly.ProxyURLKey, proxy.String()))
 	return proxy, nil
 }
 
-func parsePattern(s *goquery.Selection, item map[string]interface{}, parentValue string, isTopLevel bool) (map[string]interface{}, bool, bool) {
+//parsePattern extracts the values by the pattern, path is the keys leading to the item and the selectors that matched nothing are returned with their path
+func parsePattern(s *goquery.Selection, item map[string]interface{}, parentValue string, isTopLevel bool, path string) (map[string]interface{}, bool, bool, []models.MissingPattern) {
 	result := make(map[string]interface{})
 	wrongPage := false
 	invalid := false
+	var missing []models.MissingPattern
 	var dom *goquery.Selection
 
-	//set dom
+	if _, ok := item["source"]; ok {
+		//the item reads the structured data of the page instead of the dom
+		return parseStructuredPattern(s, item, parentValue, path)
+	}
+
+	//set dom, a regex has the matches instead of the dom
 	if pattern, ok := item["pattern"]; ok {
-		dom = s.Find(pattern.(string))
-		if dom.Size() <= 0 {
+		selectorType, _ := item["selector"].(string)
+		all, _ := item["all"].(bool)
+		var values []string
+		if selectorType == regexSelector {
+			values = matchRegex(s, pattern.(string), all)
+		} else {
+			dom = selectNodes(s, selectorType, pattern.(string))
+		}
+		if (dom == nil && len(values) <= 0) || (dom != nil && dom.Size() <= 0) {
 			//can't find the dom, return wrong page
 			wrongPage = true
+			missing = append(missing, models.MissingPattern{Path: path, Selector: pattern.(string)})
 		} else {
 			//obtain value
 			if val, ok := item["value"]; ok && val != "" {
-				var value string
-				if val.(string) == "text" {
-					//value from text property
-					value = strings.TrimSpace(dom.First().Text())
-				} else if attrs := strings.Split(val.(string), ":"); attrs[0] == "attr" {
-					//value from attr property
-					value, _ = dom.First().Attr(attrs[1])
-					value = strings.TrimSpace(value)
-				}
-				//post process
-				if postProcessJSON, ok := item["postprocess"]; ok {
-					postProcess := postProcessJSON.(map[string]interface{})
-					for k, v := range postProcess {
-						switch strings.ToLower(k) {
-						case "split":
-							//it takes two parameters, first one is the char for split, the second one is the position of the split it wants to take
-							parameters := strings.Split(v.(string), ",")
-							if len(parameters) >= 2 {
-								//split needs at least two parameters
-								index, err := strconv.Atoi(parameters[1])
-								if err == nil {
-									results := strings.Split(value, parameters[0])
-									if len(results) > index {
-										value = results[index]
-									}
-								}
-							}
-						case "replace":
-							//it takes two parameters, [0] is the old string and [1] is the new string
-							parameters := strings.Split(v.(string), ",")
-							if len(parameters) >= 2 {
-								value = strings.ReplaceAll(value, parameters[0], parameters[1])
-							}
-						default:
-						}
-					}
-				}
-
-				//validation
-				if validationJSON, ok := item["validation"]; ok && value != "" {
-					validation := validationJSON.(map[string]interface{})
-					if equation, ok := validation["equation"]; ok {
-						if targetValue, ok := validation["targetValue"]; ok {
-							equationStr := equation.(string)
-							expression := strings.Replace(strings.Replace(equationStr, "parentValue", parentValue, -1), "value", value, -1)
-							fs := token.NewFileSet()
-							isValid, err := types.Eval(fs, nil, token.NoPos, expression)
-							if err == nil {
-								if isValid.Value.String() == "true" {
-									//currently only support parentvalue or this item value
-									if targetValue.(string) == "parentValue" {
-										value = parentValue
-										invalid = false
-									}
-								} else {
-									invalid = true
-								}
-							} else {
-								invalid = true
-							}
-						}
-					}
+				if dom != nil {
+					values = extractValues(dom, val.(string), all)
 				}
-				if !utility.IsNil(value) && !invalid {
-					//only set nameFlag to False if the value is valid
+				var value interface{}
+				value, invalid = processValues(item, values, parentValue, all)
+				if !invalid {
 					result["value"] = value
-				} else {
-					invalid = true
 				}
 			}
 
 			//obtain children
-			if children, ok := item["children"]; ok {
+			if children, ok := item["children"]; ok && dom != nil {
 				dom.Each(func(index int, elem *goquery.Selection) {
 					if !wrongPage && !invalid {
 						key := strconv.Itoa(index)
-						childResult, wrongPageChild, invalidChild := parsePattern(elem, children.(map[string]interface{}), parentValue, false)
+						childResult, wrongPageChild, invalidChild, missingChild := parsePattern(elem, children.(map[string]interface{}), parentValue, false, path)
 						if wrongPageChild {
 							//set global wrongPage to rue if its child dom is invalid
 							wrongPage = wrongPageChild
+							missing = append(missing, missingChild...)
 						} else if !invalidChild {
 							//add the value if it is valid content
 							result[key] = childResult
@@ -565,11 +703,17 @@ func parsePattern(s *goquery.Selection, item map[string]interface{}, parentValue
 
 	} else {
 		for key, value := range item {
-			if !wrongPage && !invalid {
-				childResult, wrongPageChild, invalidChild := parsePattern(s, value.(map[string]interface{}), parentValue, false)
+			//keep checking the other keys on a wrong page so every missing selector is reported
+			if wrongPage || !invalid {
+				childPath := key
+				if path != "" {
+					childPath = path + "." + key
+				}
+				childResult, wrongPageChild, invalidChild, missingChild := parsePattern(s, value.(map[string]interface{}), parentValue, false, childPath)
 				if wrongPageChild {
 					//set global wrongPage to rue if its child dom is invalid
 					wrongPage = wrongPageChild
+					missing = append(missing, missingChild...)
 				} else if !invalidChild {
 					//add the value if it is valid content
 					result[key] = childResult
@@ -585,14 +729,75 @@ func parsePattern(s *goquery.Selection, item map[string]interface{}, parentValue
 		invalid = true
 	}
 
-	return result, wrongPage, invalid
+	return result, wrongPage, invalid, missing
+}
+
+//processValue post processes and validates the value of the item, it returns true if the value is invalid or empty
+func processValue(item map[string]interface{}, value, parentValue string) (string, bool) {
+	invalid := false
+	//post process
+	if postProcessJSON, ok := item["postprocess"]; ok {
+		postProcess := postProcessJSON.(map[string]interface{})
+		for k, v := range postProcess {
+			switch strings.ToLower(k) {
+			case "split":
+				//it takes two parameters, first one is the char for split, the second one is the position of the split it wants to take
+				parameters := strings.Split(v.(string), ",")
+				if len(parameters) >= 2 {
+					//split needs at least two parameters
+					index, err := strconv.Atoi(parameters[1])
+					if err == nil {
+						results := strings.Split(value, parameters[0])
+						if len(results) > index {
+							value = results[index]
+						}
+					}
+				}
+			case "replace":
+				//it takes two parameters, [0] is the old string and [1] is the new string
+				parameters := strings.Split(v.(string), ",")
+				if len(parameters) >= 2 {
+					value = strings.ReplaceAll(value, parameters[0], parameters[1])
+				}
+			default:
+			}
+		}
+	}
+
+	//validation
+	if validationJSON, ok := item["validation"]; ok && value != "" {
+		validation := validationJSON.(map[string]interface{})
+		if equation, ok := validation["equation"]; ok {
+			if targetValue, ok := validation["targetValue"]; ok {
+				equationStr := equation.(string)
+				expression := strings.Replace(strings.Replace(equationStr, "parentValue", parentValue, -1), "value", value, -1)
+				fs := token.NewFileSet()
+				isValid, err := types.Eval(fs, nil, token.NoPos, expression)
+				if err == nil {
+					if isValid.Value.String() == "true" {
+						//currently only support parentvalue or this item value
+						if targetValue.(string) == "parentValue" {
+							value = parentValue
+							invalid = false
+						}
+					} else {
+						invalid = true
+					}
+				} else {
+					invalid = true
+				}
+			}
+		}
+	}
+	//only valid values are kept
+	return value, invalid || utility.IsNil(value)
 }
 
 //proxyCheck code from https://github.com/asm-jaime/go-proxycheck
-func proxyCheck(proxies []string, proxyType string, testLink string) (validatedProxies []string, cookies []string) {
+func proxyCheck(log *logger.Logger, proxies []string, proxyType string, testLink string) (validatedProxies []string, cookies []string) {
 	c := make(chan string)
 	timeout := math.Max(float64(len(proxies))*0.01, 10.0)
-	log.Println("Validating Proxies, it could take:", timeout, "seconds")
+	log.Info("Validating proxies", "timeoutSeconds", timeout)
 	for _, prox := range proxies {
 		go func(prox string) {
 			proxyURL, err := url.Parse(proxyType + "://" + prox)
@@ -635,19 +840,31 @@ func getCookieFromRespList(respCookies []string) string {
 }
 
 //runOneScraper fires of the scraping process
-func runOneScraper(id string) error {
-	log.Println("Scraper started")
+func runOneScraper(id string, config config.Scraper, store warc.Store) error {
 	flag := true
-	scraper, _ := new(id)
+	scraper, err := new(id, config)
+	if err != nil {
+		return err
+	}
+	scraper.logger.Info("Scraper started")
+	health.Beat(loopHeartbeat)
+	defer scraper.removeProxyPool()
 	//Get Rule
-	err := scraper.setRule()
+	err = scraper.setRule()
+	if err != nil {
+		return err
+	}
+	scraper.archive = newArchiver(store, scraper.rule.ID)
 	//Get Links from Rule
-	linkIDs, pendingLinks, err := getLinks(scraper.rule.ID, scraper.id)
+	linkIDs, pendingLinks, err := getLinks(scraper.logger, scraper.distributor(), scraper.rule.ID, scraper.id)
 	if !utility.IsNil(err) {
 		return err
 	}
 	scraper.receviedLinkIDs = linkIDs
 	scraper.pendingLinks = pendingLinks
+	for i, link := range pendingLinks {
+		scraper.linkIDs[link] = linkIDs[i]
+	}
 	//get new proxies periodically
 	go func() {
 		for flag {
@@ -663,62 +880,66 @@ func runOneScraper(id string) error {
 		}
 	}()
 	if err != nil {
-		log.Println(err)
+		scraper.logger.Error("Scraper failed", "error", err)
 		return err
 	}
+	scraper.reportProxyPool()
 	for len(scraper.pendingLinks) > 0 {
+		health.Beat(loopHeartbeat)
 		if len(scraper.pendingLinks) == scraper.previousPendingLinksSize {
 			scraper.failedTimes++
 		} else {
 			scraper.failedTimes = 0
 		}
 		scraper.previousPendingLinksSize = len(scraper.pendingLinks)
+		metrics.QueueDepth.WithLabelValues(scraper.rule.Name).Set(float64(len(scraper.pendingLinks)))
 		coolDownDelay := rand.Int31n(int32(math.Max(float64(len(scraper.pendingLinks)), 60)))
-		if utility.GetEnv("ISCOOLDOWN", "") == "" {
+		if !scraper.config.Cooldown {
 			//set cooldown to 5 if it is disabled
 			coolDownDelay = 10
 		}
-		log.Println("Refreshing collector,queue,proxies and cookies,sleep for ", coolDownDelay, "seconds. Size of Links:", len(scraper.pendingLinks), "Total Failed Time:", scraper.failedTimes)
+		scraper.logger.Info("Refreshing collector, queue, proxies and cookies", "sleepSeconds", coolDownDelay, "pendingLinks", len(scraper.pendingLinks), "failedTimes", scraper.failedTimes)
 		time.Sleep(time.Duration(coolDownDelay) * time.Second)
-		log.Println("Setting collector")
+		scraper.logger.Debug("Setting collector")
 		scraper.setCollector()
-		log.Println("Setting links queue")
+		scraper.logger.Debug("Setting links queue")
 		err = scraper.setLinksQueue()
 		if !utility.IsNil(err) {
 			return err
 		}
 
 		for scraper.collector == nil {
-			log.Println("Waiting for collector to be ready")
+			scraper.logger.Info("Waiting for collector to be ready")
 			time.Sleep(10 * time.Second)
 		}
-		log.Println("SStart running the collector")
+		scraper.logger.Debug("Start running the collector")
 		scraper.queue.Run(scraper.collector)
 	}
+	metrics.QueueDepth.WithLabelValues(scraper.rule.Name).Set(0)
 	err = scraper.setLinksToComplete()
 	if err != nil {
-		log.Println("Set Links to Complete failed:", err)
+		scraper.logger.Error("Set links to complete failed", "error", err)
 	}
-	log.Println("Link set to completed")
+	scraper.logger.Info("Links set to completed", "links", len(linkIDs))
 	scraper.saveScrapedRecords()
 	flag = false //removing those loops
-	log.Println("Scraper Completed")
+	scraper.logger.Info("Scraper completed")
 	return nil
 }
 
-//StartScraping fires of the scraping process
-func StartScraping() (err error) {
-	scrapersStr := utility.GetEnv("SCRAPERS", "3")
-	scrapers, err := strconv.Atoi(scrapersStr)
+//StartScraping fires of the scraping process, the name identifies the node to the distributor,
+//the fetched pages are archived if the archive is on and the files after the retention are deleted
+func StartScraping(name string, config config.Scraper, archive config.Archive) (err error) {
+	store, err := warc.NewStore(archive)
 	if err != nil {
-		scrapers = 3
+		return err
 	}
-	id, _ := uuid.NewRandom()
-	name := utility.GetEnv("NAME", id.String())
+	pruneArchive(store, archive.Retention)
+	scrapers := config.Scrapers
 	errs := make(chan error)
 	for i := 1; i <= scrapers; i++ {
 		go func() {
-			errs <- runOneScraper(name)
+			errs <- runOneScraper(name, config, store)
 		}()
 		go func() {
 			time.Sleep(15 * time.Minute)
@@ -0,0 +1,160 @@
+package scraper
+
+import (
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/antchfx/htmlquery"
+	"github.com/antchfx/xpath"
+	"golang.org/x/net/html"
+)
+
+//selector types of the pattern items, css is the default
+const (
+	cssSelector   = "css"
+	xpathSelector = "xpath"
+	regexSelector = "regex"
+)
+
+//compiled keeps the compiled xpaths and regexes by their pattern because every page uses the same patterns
+var compiled sync.Map
+
+//compileXPath returns the compiled xpath, nil if it is invalid
+func compileXPath(pattern string) *xpath.Expr {
+	if expr, ok := compiled.Load(xpathSelector + ":" + pattern); ok {
+		return expr.(*xpath.Expr)
+	}
+	expr, err := xpath.Compile(pattern)
+	if err != nil {
+		return nil
+	}
+	compiled.Store(xpathSelector+":"+pattern, expr)
+	return expr
+}
+
+//compileRegex returns the compiled regex, nil if it is invalid
+func compileRegex(pattern string) *regexp.Regexp {
+	if re, ok := compiled.Load(regexSelector + ":" + pattern); ok {
+		return re.(*regexp.Regexp)
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil
+	}
+	compiled.Store(regexSelector+":"+pattern, re)
+	return re
+}
+
+//selectNodes returns the nodes the css selector or the xpath matches in the selection,
+//an xpath starting with // searches the whole page and attributes such as //a/@href are selected as their text
+func selectNodes(s *goquery.Selection, selectorType, pattern string) *goquery.Selection {
+	if selectorType != xpathSelector {
+		return s.Find(pattern)
+	}
+	expr := compileXPath(pattern)
+	if expr == nil {
+		return &goquery.Selection{}
+	}
+	var nodes []*html.Node
+	seen := make(map[*html.Node]bool)
+	for _, node := range s.Nodes {
+		for _, match := range htmlquery.QuerySelectorAll(node, expr) {
+			if !seen[match] {
+				seen[match] = true
+				nodes = append(nodes, match)
+			}
+		}
+	}
+	return &goquery.Selection{Nodes: nodes}
+}
+
+//matchRegex returns the first group of the regex, or the whole match if it has no group, in the html of the selection,
+//the body is searched with the head so the data in inline scripts can be matched
+func matchRegex(s *goquery.Selection, pattern string, all bool) []string {
+	re := compileRegex(pattern)
+	if re == nil {
+		return nil
+	}
+	nodes := s.Nodes
+	if len(nodes) == 1 && nodes[0].Data == "body" && nodes[0].Parent != nil {
+		nodes = []*html.Node{nodes[0].Parent}
+	}
+	var content strings.Builder
+	for _, node := range nodes {
+		html.Render(&content, node)
+	}
+	limit := 1
+	if all {
+		limit = -1
+	}
+	var values []string
+	for _, match := range re.FindAllStringSubmatch(content.String(), limit) {
+		value := match[0]
+		if len(match) > 1 {
+			value = match[1]
+		}
+		values = append(values, strings.TrimSpace(value))
+	}
+	return values
+}
+
+//extractValues returns the value of the first node or of every node if all is true
+func extractValues(dom *goquery.Selection, extractor string, all bool) []string {
+	if !all {
+		return []string{extractValue(dom.First(), extractor)}
+	}
+	values := make([]string, 0, dom.Size())
+	dom.Each(func(index int, elem *goquery.Selection) {
+		values = append(values, extractValue(elem, extractor))
+	})
+	return values
+}
+
+//extractValue returns the text, the inner html, the outer html, the text of the node without its children
+//or the first attribute that is not empty of attr:name|fallback
+func extractValue(elem *goquery.Selection, extractor string) string {
+	var value string
+	switch {
+	case extractor == "text":
+		value = elem.Text()
+	case extractor == "html":
+		value, _ = elem.Html()
+	case extractor == "outerHtml":
+		value, _ = goquery.OuterHtml(elem)
+	case extractor == "ownText":
+		var text strings.Builder
+		for _, node := range elem.Nodes {
+			for child := node.FirstChild; child != nil; child = child.NextSibling {
+				if child.Type == html.TextNode {
+					text.WriteString(child.Data)
+				}
+			}
+		}
+		value = text.String()
+	case strings.HasPrefix(extractor, "attr:"):
+		for _, name := range strings.Split(strings.TrimPrefix(extractor, "attr:"), "|") {
+			if attr, ok := elem.Attr(name); ok && strings.TrimSpace(attr) != "" {
+				value = attr
+				break
+			}
+		}
+	}
+	return strings.TrimSpace(value)
+}
+
+//processValues post processes and validates the values, all values are kept as an array if all is true
+//and the invalid ones are dropped, it returns true if no value is valid
+func processValues(item map[string]interface{}, values []string, parentValue string, all bool) (interface{}, bool) {
+	if !all {
+		return processValue(item, values[0], parentValue)
+	}
+	valid := make([]string, 0, len(values))
+	for _, value := range values {
+		if value, invalid := processValue(item, value, parentValue); !invalid {
+			valid = append(valid, value)
+		}
+	}
+	return valid, len(valid) <= 0
+}
@@ -0,0 +1,230 @@
+package scraper
+
+import (
+	"encoding/json"
+	"strings"
+	"sync"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+
+	"github.com/sporule/grater/models"
+)
+
+//structured data sources of the pattern items
+const (
+	jsonLDSource    = "jsonld"
+	microdataSource = "microdata"
+	openGraphSource = "opengraph"
+)
+
+//parseStructuredPattern extracts the values of the item from the structured data of the page like parseJSONPattern,
+//the first block that has the path is used and the fallback item is parsed with its selector if no block has it
+func parseStructuredPattern(s *goquery.Selection, item map[string]interface{}, parentValue string, path string) (map[string]interface{}, bool, bool, []models.MissingPattern) {
+	source := item["source"].(string)
+	typeName, _ := item["type"].(string)
+	jsonItem := make(map[string]interface{}, len(item))
+	for key, value := range item {
+		if key != "source" && key != "type" && key != "fallback" {
+			jsonItem[key] = value
+		}
+	}
+	for _, block := range structuredData(s, source, typeName) {
+		if result, wrongPage, invalid, _ := parseJSONPattern(block, jsonItem, parentValue, path); !wrongPage {
+			return result, wrongPage, invalid, nil
+		}
+	}
+	if fallback, ok := item["fallback"].(map[string]interface{}); ok {
+		return parsePattern(s, fallback, parentValue, false, path)
+	}
+	//the source is reported with the path because the path is not a selector of the page
+	pattern, _ := item["pattern"].(string)
+	return make(map[string]interface{}), true, true, []models.MissingPattern{{Path: path, Selector: source + ":" + pattern}}
+}
+
+//structuredData returns the json-ld blocks, the top level microdata items or the opengraph meta of the page the selection is in,
+//the blocks are filtered by their schema.org type if typeName is set
+func structuredData(s *goquery.Selection, source, typeName string) []interface{} {
+	root := rootNode(s)
+	if root == nil {
+		return nil
+	}
+	blocks := pageBlocks(root, source)
+	if typeName == "" {
+		return blocks
+	}
+	filtered := make([]interface{}, 0, len(blocks))
+	for _, block := range blocks {
+		if object, ok := block.(map[string]interface{}); ok && hasType(object["@type"], typeName) {
+			filtered = append(filtered, object)
+		}
+	}
+	return filtered
+}
+
+//structuredPage keeps the blocks of every source of one page so the page is only parsed once for all of its items
+type structuredPage struct {
+	blocks map[string][]interface{}
+	mutex  sync.Mutex
+}
+
+//structuredPages are the parsed pages by their root node until forgetStructuredData is called
+var structuredPages sync.Map
+
+//pageBlocks returns the blocks of the source on the page, they are parsed on the first call
+func pageBlocks(root *html.Node, source string) []interface{} {
+	cached, _ := structuredPages.LoadOrStore(root, &structuredPage{blocks: make(map[string][]interface{})})
+	page := cached.(*structuredPage)
+	page.mutex.Lock()
+	defer page.mutex.Unlock()
+	if blocks, ok := page.blocks[source]; ok {
+		return blocks
+	}
+	document := goquery.NewDocumentFromNode(root).Selection
+	var blocks []interface{}
+	switch source {
+	case jsonLDSource:
+		blocks = jsonLDBlocks(document)
+	case microdataSource:
+		blocks = microdataItems(document)
+	case openGraphSource:
+		blocks = openGraph(document)
+	}
+	page.blocks[source] = blocks
+	return blocks
+}
+
+//forgetStructuredData drops the parsed blocks of the page the selection is in, it is called once the page is parsed
+func forgetStructuredData(s *goquery.Selection) {
+	if root := rootNode(s); root != nil {
+		structuredPages.Delete(root)
+	}
+}
+
+//rootNode returns the document node of the selection, it is nil for an empty selection
+func rootNode(s *goquery.Selection) *html.Node {
+	if len(s.Nodes) <= 0 {
+		return nil
+	}
+	root := s.Nodes[0]
+	for root.Parent != nil {
+		root = root.Parent
+	}
+	return root
+}
+
+//hasType checks the @type or one of the @types is the type name, schema.org prefixes such as https://schema.org/ are ignored
+func hasType(value interface{}, typeName string) bool {
+	switch value := value.(type) {
+	case string:
+		if index := strings.LastIndexAny(value, "/:"); index >= 0 {
+			value = value[index+1:]
+		}
+		return value == typeName
+	case []interface{}:
+		for _, item := range value {
+			if hasType(item, typeName) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+//jsonLDBlocks returns the objects of every application/ld+json script, arrays and @graph are flattened and the invalid scripts are skipped
+func jsonLDBlocks(page *goquery.Selection) []interface{} {
+	var blocks []interface{}
+	page.Find(`script[type="application/ld+json"]`).Each(func(index int, elem *goquery.Selection) {
+		var data interface{}
+		if err := json.Unmarshal([]byte(strings.TrimSpace(elem.Text())), &data); err != nil {
+			return
+		}
+		for _, block := range jsonItems(data) {
+			if object, ok := block.(map[string]interface{}); ok {
+				if graph, ok := object["@graph"].([]interface{}); ok {
+					blocks = append(blocks, graph...)
+					continue
+				}
+			}
+			blocks = append(blocks, block)
+		}
+	})
+	return blocks
+}
+
+//microdataItems returns the items that are not the property of another item, e.g. {"@type":"Product","name":"PS5","offers":{"@type":"Offer","price":"449.99"}}
+func microdataItems(page *goquery.Selection) []interface{} {
+	var items []interface{}
+	page.Find("[itemscope]").Not("[itemprop]").Each(func(index int, elem *goquery.Selection) {
+		items = append(items, microdataItem(elem))
+	})
+	return items
+}
+
+//microdataItem returns the properties of the item, a property that is repeated is an array
+func microdataItem(scope *goquery.Selection) map[string]interface{} {
+	item := make(map[string]interface{})
+	if itemType := strings.Fields(scope.AttrOr("itemtype", "")); len(itemType) > 0 {
+		item["@type"] = itemType[0]
+	}
+	scope.Find("[itemprop]").Each(func(index int, elem *goquery.Selection) {
+		//the properties of the nested items belong to the nested items
+		if elem.Parent().Closest("[itemscope]").Get(0) != scope.Get(0) {
+			return
+		}
+		var value interface{}
+		if _, ok := elem.Attr("itemscope"); ok {
+			value = microdataItem(elem)
+		} else {
+			value = microdataValue(elem)
+		}
+		for _, name := range strings.Fields(elem.AttrOr("itemprop", "")) {
+			switch existing := item[name].(type) {
+			case nil:
+				item[name] = value
+			case []interface{}:
+				item[name] = append(existing, value)
+			default:
+				item[name] = []interface{}{existing, value}
+			}
+		}
+	})
+	return item
+}
+
+//microdataValue returns the value of the property the way the html spec reads it, e.g. content of meta, href of a and datetime of time
+func microdataValue(elem *goquery.Selection) string {
+	attributes := map[string]string{
+		"meta": "content", "a": "href", "link": "href", "area": "href",
+		"img": "src", "audio": "src", "video": "src", "source": "src", "iframe": "src", "embed": "src",
+		"object": "data", "time": "datetime", "data": "value", "meter": "value",
+	}
+	if name, ok := attributes[goquery.NodeName(elem)]; ok {
+		if value, ok := elem.Attr(name); ok {
+			return strings.TrimSpace(value)
+		}
+	}
+	if value, ok := elem.Attr("content"); ok {
+		return strings.TrimSpace(value)
+	}
+	return strings.TrimSpace(elem.Text())
+}
+
+//openGraph returns the meta with a property such as og:title and product:price:amount and the twitter meta as one block,
+//the first value is kept if a property is repeated
+func openGraph(page *goquery.Selection) []interface{} {
+	meta := make(map[string]interface{})
+	page.Find("meta[content]").Each(func(index int, elem *goquery.Selection) {
+		name := elem.AttrOr("property", "")
+		if name == "" && strings.HasPrefix(elem.AttrOr("name", ""), "twitter:") {
+			name = elem.AttrOr("name", "")
+		}
+		if _, ok := meta[name]; name != "" && !ok {
+			meta[name] = strings.TrimSpace(elem.AttrOr("content", ""))
+		}
+	})
+	if len(meta) <= 0 {
+		return nil
+	}
+	return []interface{}{meta}
+}
@@ -0,0 +1,94 @@
+package scraper
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/sporule/grater/models"
+)
+
+func TestParseStructuredPattern(t *testing.T) {
+	page := `<html><head>
+		<meta property="og:title" content="PS5 Console">
+		<meta property="og:image" content="https://example.com/1.jpg">
+		<meta property="og:image" content="https://example.com/2.jpg">
+		<meta name="twitter:card" content="summary">
+		<script type="application/ld+json">{"@context":"https://schema.org","@type":"Organization","name":"Shop"}</script>
+		<script type="application/ld+json">{"@context":"https://schema.org","@graph":[{"@type":"BreadcrumbList"},{"@type":["Product","Thing"],"name":"PS5","offers":{"price":"449.99","availability":"https://schema.org/InStock"}}]}</script>
+		<script type="application/ld+json">{invalid</script>
+	</head><body>
+		<div itemscope itemtype="https://schema.org/Product">
+			<h1 itemprop="name">PS5 Digital</h1>
+			<div itemprop="offers" itemscope itemtype="https://schema.org/Offer">
+				<meta itemprop="priceCurrency" content="GBP"><span itemprop="price" content="359.99">£359.99</span>
+				<link itemprop="availability" href="https://schema.org/OutOfStock">
+			</div>
+			<span itemprop="color">white</span><span itemprop="color">black</span>
+		</div>
+		<span class="price">£449.99</span>
+	</body></html>`
+	dom, err := goquery.NewDocumentFromReader(strings.NewReader(page))
+	assert.Nil(t, err)
+	var pattern map[string]interface{}
+	assert.Nil(t, json.Unmarshal([]byte(`{
+		"name": {"source": "jsonld", "type": "Product", "pattern": "name", "value": "text"},
+		"shop": {"source": "jsonld", "pattern": "name", "value": "text"},
+		"availability": {"source": "jsonld", "pattern": "offers.availability", "value": "text", "postprocess": {"replace": "https://schema.org/,"}},
+		"currency": {"source": "microdata", "type": "Product", "pattern": "offers.priceCurrency", "value": "text"},
+		"microPrice": {"source": "microdata", "pattern": "offers.price", "value": "text", "validation": {"equation": "300 <= value", "targetValue": "value"}},
+		"colors": {"source": "microdata", "pattern": "color", "children": {"color": {"pattern": "@this", "value": "text"}}},
+		"title": {"source": "opengraph", "pattern": "og:title", "value": "text"},
+		"image": {"source": "opengraph", "pattern": "og:image", "value": "text"},
+		"sku": {"source": "jsonld", "pattern": "sku", "value": "text", "fallback": {"pattern": "span.price", "value": "text"}}
+	}`), &pattern))
+	value, wrongPage, invalid, missing := parsePattern(dom.Find("body"), pattern, "", true, "")
+	assert.False(t, wrongPage)
+	assert.False(t, invalid)
+	assert.Nil(t, missing)
+	valueOf := func(key string) interface{} {
+		return value[key].(map[string]interface{})["value"]
+	}
+	assert.Equal(t, "PS5", valueOf("name"), "the @graph should be flattened and filtered by the type")
+	assert.Equal(t, "Shop", valueOf("shop"), "the first block with the path should be used")
+	assert.Equal(t, "InStock", valueOf("availability"))
+	assert.Equal(t, "GBP", valueOf("currency"))
+	assert.Equal(t, "359.99", valueOf("microPrice"), "the content attribute should be read")
+	assert.Equal(t, "black", value["colors"].(map[string]interface{})["1"].(map[string]interface{})["color"].(map[string]interface{})["value"], "a repeated property should be an array")
+	assert.Equal(t, "PS5 Console", valueOf("title"))
+	assert.Equal(t, "https://example.com/1.jpg", valueOf("image"), "the first value of a repeated property should be kept")
+	assert.Equal(t, "£449.99", valueOf("sku"), "the fallback should be parsed if the path is missing")
+
+	pattern = map[string]interface{}{"sku": map[string]interface{}{"source": "microdata", "pattern": "sku", "value": "text"}}
+	_, wrongPage, _, missing = parsePattern(dom.Find("body"), pattern, "", true, "")
+	assert.True(t, wrongPage)
+	assert.Equal(t, []models.MissingPattern{{Path: "sku", Selector: "microdata:sku"}}, missing)
+
+	pattern = map[string]interface{}{"sku": map[string]interface{}{"source": "jsonld", "type": "Offer", "pattern": "sku", "value": "text"}}
+	_, wrongPage, _, missing = parsePattern(dom.Find("body"), pattern, "", true, "")
+	assert.True(t, wrongPage)
+	assert.Equal(t, []models.MissingPattern{{Path: "sku", Selector: "jsonld:sku"}}, missing, "the source should be reported if no block has the type")
+}
+
+func TestStructuredDataIsParsedOnce(t *testing.T) {
+	dom, err := goquery.NewDocumentFromReader(strings.NewReader(`<html><head>
+		<script type="application/ld+json">{"@type":"Product","name":"PS5","sku":"CFI-1016A"}</script>
+	</head><body><h1>PS5</h1></body></html>`))
+	assert.Nil(t, err)
+	body := dom.Find("body")
+	assert.Equal(t, 1, len(structuredData(body, jsonLDSource, "")))
+
+	//the blocks are kept for the other items of the page, so the page is not read again
+	dom.Find("script").Remove()
+	assert.Equal(t, 1, len(structuredData(dom.Find("h1"), jsonLDSource, "Product")))
+	assert.Empty(t, structuredData(body, jsonLDSource, "Offer"))
+
+	forgetStructuredData(body)
+	_, cached := structuredPages.Load(rootNode(body))
+	assert.False(t, cached, "the blocks should be dropped once the page is parsed")
+	assert.Empty(t, structuredData(body, jsonLDSource, ""))
+	forgetStructuredData(body)
+}
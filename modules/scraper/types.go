@@ -0,0 +1,262 @@
+package scraper
+
+import (
+	"errors"
+	"math"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/sporule/grater/models"
+)
+
+var (
+	decimalFormat = regexp.MustCompile(models.DecimalPattern)
+	currencyCode  = regexp.MustCompile(`\b[A-Z]{3}\b`)
+	//numberToken is a number with separators, a space only separates groups of three digits such as 1 299,00
+	numberToken = regexp.MustCompile(`-?[0-9](?:[0-9.,]|[ \x{00A0}\x{202F}][0-9]{3}\b)*`)
+	//currencyCodes are the active ISO 4217 codes, the other three letter words such as VAT are not currencies
+	currencyCodes = toSet(strings.Fields(`AED AFN ALL AMD ANG AOA ARS AUD AWG AZN BAM BBD BDT BGN BHD BIF BMD BND BOB BRL BSD BTN BWP BYN BZD
+		CAD CDF CHF CLP CNY COP CRC CUP CVE CZK DJF DKK DOP DZD EGP ERN ETB EUR FJD FKP GBP GEL GHS GIP GMD GNF GTQ GYD
+		HKD HNL HTG HUF IDR ILS INR IQD IRR ISK JMD JOD JPY KES KGS KHR KMF KPW KRW KWD KYD KZT LAK LBP LKR LRD LSL LYD
+		MAD MDL MGA MKD MMK MNT MOP MRU MUR MVR MWK MXN MYR MZN NAD NGN NIO NOK NPR NZD OMR PAB PEN PGK PHP PKR PLN PYG
+		QAR RON RSD RUB RWF SAR SBD SCR SDG SEK SGD SHP SLE SLL SOS SRD SSP STN SVC SYP SZL THB TJS TMT TND TOP TRY TTD
+		TWD TZS UAH UGX USD UYU UZS VES VND VUV WST XAF XCD XOF XPF YER ZAR ZMW ZWL`))
+	//currencySymbols are the symbols of the common currencies in order, the prefixed dollars come before $ which is taken as USD
+	currencySymbols = [][2]string{{"US$", "USD"}, {"C$", "CAD"}, {"CA$", "CAD"}, {"A$", "AUD"}, {"AU$", "AUD"}, {"NZ$", "NZD"}, {"HK$", "HKD"}, {"S$", "SGD"},
+		{"£", "GBP"}, {"€", "EUR"}, {"$", "USD"}, {"¥", "JPY"}, {"₹", "INR"}, {"₩", "KRW"}, {"₽", "RUB"}, {"₺", "TRY"}}
+	//dateTimeLayouts are tried in order for datetime values without a format
+	dateTimeLayouts = []string{time.RFC3339, "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02", time.RFC1123Z, time.RFC1123, "2 January 2006", "January 2, 2006", "2 Jan 2006", "Jan 2, 2006"}
+)
+
+//typeRecord converts the values of the record to the data types of the pattern and the children keyed by their index to real arrays,
+//relative urls are resolved against the page, it returns false if a value can't be converted
+func typeRecord(value map[string]interface{}, item map[string]interface{}, page *url.URL) (map[string]interface{}, bool) {
+	typed, ok := typeItem(value, item, page)
+	if !ok {
+		return nil, false
+	}
+	//the top level is a group so it stays an object
+	record, _ := typed.(map[string]interface{})
+	return record, record != nil
+}
+
+func typeItem(value map[string]interface{}, item map[string]interface{}, page *url.URL) (interface{}, bool) {
+	_, hasPattern := item["pattern"]
+	_, hasSource := item["source"]
+	if !hasPattern && !hasSource {
+		for key, child := range item {
+			childItem, ok := child.(map[string]interface{})
+			childValue, found := value[key].(map[string]interface{})
+			if !ok || !found {
+				continue
+			}
+			typed, ok := typeItem(childValue, childItem, page)
+			if !ok {
+				return nil, false
+			}
+			value[key] = typed
+		}
+		return value, true
+	}
+	dataType, _ := item["dataType"].(string)
+	if raw, ok := value["value"]; ok && dataType != "" && dataType != "string" {
+		typed, err := typeValues(raw, item, page)
+		if err != nil {
+			return nil, false
+		}
+		value["value"] = typed
+	}
+	children, hasChildren := item["children"].(map[string]interface{})
+	if !hasChildren {
+		return value, true
+	}
+	indexes := make([]int, 0, len(value))
+	for key, child := range value {
+		index, err := strconv.Atoi(key)
+		childValue, ok := child.(map[string]interface{})
+		if err != nil || !ok {
+			continue
+		}
+		typed, ok := typeItem(childValue, children, page)
+		if !ok {
+			return nil, false
+		}
+		value[key] = typed
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+	items := make([]interface{}, 0, len(indexes))
+	for _, index := range indexes {
+		items = append(items, value[strconv.Itoa(index)])
+	}
+	ownValue, hasValue := value["value"]
+	if !hasValue {
+		return items, true
+	}
+	//an item with a value keeps it next to the array of its children
+	return map[string]interface{}{"value": ownValue, "children": items}, true
+}
+
+//typeValues converts the value or every value of all
+func typeValues(raw interface{}, item map[string]interface{}, page *url.URL) (interface{}, error) {
+	switch raw := raw.(type) {
+	case string:
+		return typeValue(raw, item, page)
+	case []string:
+		values := make([]interface{}, 0, len(raw))
+		for _, value := range raw {
+			typed, err := typeValue(value, item, page)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, typed)
+		}
+		return values, nil
+	}
+	return raw, nil
+}
+
+//typeValue converts the text to the data type of the item
+func typeValue(text string, item map[string]interface{}, page *url.URL) (interface{}, error) {
+	dataType, _ := item["dataType"].(string)
+	switch dataType {
+	case "int":
+		number, err := parseNumber(text)
+		if err != nil {
+			return nil, err
+		}
+		if number != math.Trunc(number) {
+			return nil, errors.New("not an integer: " + text)
+		}
+		return int64(number), nil
+	case "float":
+		return parseNumber(text)
+	case "decimal":
+		return parseDecimal(text)
+	case "money":
+		amount, err := parseDecimal(text)
+		if err != nil {
+			return nil, err
+		}
+		money := map[string]interface{}{"amount": amount}
+		if currency := parseCurrency(text, item); currency != "" {
+			money["currency"] = currency
+		}
+		return money, nil
+	case "bool":
+		switch strings.ToLower(strings.TrimSpace(text)) {
+		case "true", "yes", "y", "1", "on":
+			return true, nil
+		case "false", "no", "n", "0", "off":
+			return false, nil
+		}
+		return nil, errors.New("not a boolean: " + text)
+	case "datetime":
+		return parseDateTime(text, item)
+	case "url":
+		link, err := url.Parse(strings.TrimSpace(text))
+		if err != nil {
+			return nil, err
+		}
+		if page != nil {
+			link = page.ResolveReference(link)
+		}
+		if !link.IsAbs() || link.Host == "" {
+			return nil, errors.New("not an absolute url: " + text)
+		}
+		return link.String(), nil
+	}
+	return text, nil
+}
+
+//parseDecimal returns the number in the text as a decimal string such as 1299.00, the separators are read like parseNumber
+func parseDecimal(text string) (string, error) {
+	number := cleanNumber(text)
+	if !decimalFormat.MatchString(number) {
+		return "", errors.New("not a number: " + text)
+	}
+	return number, nil
+}
+
+//parseNumber returns the number in the text such as £1,299.00 or 1.299,00 €
+func parseNumber(text string) (float64, error) {
+	number := cleanNumber(text)
+	if !decimalFormat.MatchString(number) {
+		return 0, errors.New("not a number: " + text)
+	}
+	return strconv.ParseFloat(number, 64)
+}
+
+//cleanNumber returns the first number in the text with . as the decimal separator so "2 for £10" is 2,
+//the last separator is the decimal separator if the text has both, a single comma followed by one or two digits is a decimal comma
+func cleanNumber(text string) string {
+	number := numberToken.FindString(text)
+	//spaces only separate the thousands and a separator at the end is punctuation such as "12."
+	number = strings.TrimRight(strings.Map(func(char rune) rune {
+		if unicode.IsSpace(char) {
+			return -1
+		}
+		return char
+	}, number), ".,")
+	lastComma := strings.LastIndex(number, ",")
+	lastDot := strings.LastIndex(number, ".")
+	switch {
+	case lastComma >= 0 && lastDot >= 0 && lastComma > lastDot:
+		//1.299,00
+		number = strings.Replace(strings.ReplaceAll(number, ".", ""), ",", ".", 1)
+	case lastComma >= 0 && lastDot < 0 && strings.Count(number, ",") == 1 && len(number)-lastComma-1 <= 2:
+		//12,50
+		number = strings.Replace(number, ",", ".", 1)
+	default:
+		//1,299.00
+		number = strings.ReplaceAll(number, ",", "")
+	}
+	return number
+}
+
+//parseCurrency returns the currency of the item, the currency of the symbol in the text or the ISO 4217 code in the text,
+//the currency of the item comes first so a rule can read $ as CAD and the symbols come before the codes so "£12 ALL IN" is GBP
+func parseCurrency(text string, item map[string]interface{}) string {
+	if currency, ok := item["currency"].(string); ok {
+		return currency
+	}
+	for _, symbol := range currencySymbols {
+		if strings.Contains(text, symbol[0]) {
+			return symbol[1]
+		}
+	}
+	for _, code := range currencyCode.FindAllString(text, -1) {
+		if currencyCodes[code] {
+			return code
+		}
+	}
+	return ""
+}
+
+func toSet(values []string) map[string]bool {
+	set := make(map[string]bool, len(values))
+	for _, value := range values {
+		set[value] = true
+	}
+	return set
+}
+
+//parseDateTime returns the time in RFC3339, the format of the item is the Go layout of the text
+func parseDateTime(text string, item map[string]interface{}) (string, error) {
+	text = strings.TrimSpace(text)
+	layouts := dateTimeLayouts
+	if format, ok := item["format"].(string); ok {
+		layouts = []string{format}
+	}
+	for _, layout := range layouts {
+		if t, err := time.Parse(layout, text); err == nil {
+			return t.Format(time.RFC3339), nil
+		}
+	}
+	return "", errors.New("not a datetime: " + text)
+}
@@ -0,0 +1,116 @@
+package scraper
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTypeRecord(t *testing.T) {
+	page := `<html><body>
+		<h1>PS5</h1><span class="price">£1,299.00</span><span class="euro">1.299,50 €</span><span class="cad">$15</span>
+		<span class="stock">Yes</span><span class="stars">4.5</span><span class="reviews">1,024</span>
+		<time datetime="2021-02-03T10:00:00Z"></time><span class="date">03/02/2021</span>
+		<a href="/items/1">item</a>
+		<ul><li><span>new</span></li><li><span>boxed</span></li></ul>
+	</body></html>`
+	dom, err := goquery.NewDocumentFromReader(strings.NewReader(page))
+	assert.Nil(t, err)
+	var pattern map[string]interface{}
+	assert.Nil(t, json.Unmarshal([]byte(`{
+		"name": {"pattern": "h1", "value": "text", "dataType": "string"},
+		"price": {"pattern": "span.price", "value": "text", "dataType": "money"},
+		"euro": {"pattern": "span.euro", "value": "text", "dataType": "money"},
+		"cad": {"pattern": "span.cad", "value": "text", "dataType": "money", "currency": "CAD"},
+		"decimal": {"pattern": "span.price", "value": "text", "dataType": "decimal"},
+		"inStock": {"pattern": "span.stock", "value": "text", "dataType": "bool"},
+		"stars": {"pattern": "span.stars", "value": "text", "dataType": "float"},
+		"reviews": {"pattern": "span.reviews", "value": "text", "dataType": "int"},
+		"updated": {"pattern": "time", "value": "attr:datetime", "dataType": "datetime"},
+		"date": {"pattern": "span.date", "value": "text", "dataType": "datetime", "format": "02/01/2006"},
+		"link": {"pattern": "a", "value": "attr:href", "dataType": "url"},
+		"tags": {"pattern": "li", "dataType": "array", "children": {"tag": {"pattern": "span", "value": "text"}}},
+		"items": {"pattern": "li", "children": {"tag": {"pattern": "span", "value": "text"}}},
+		"list": {"pattern": "ul", "value": "text", "children": {"tag": {"pattern": "span", "value": "text"}}},
+		"words": {"pattern": "li", "value": "text", "all": true, "dataType": "string"},
+		"counts": {"pattern": "span.reviews", "value": "text", "all": true, "dataType": "int"}
+	}`), &pattern))
+	value, wrongPage, invalid, _ := parsePattern(dom.Find("body"), pattern, "", true, "")
+	assert.False(t, wrongPage)
+	assert.False(t, invalid)
+	page1, _ := url.Parse("https://example.com/list?page=1")
+	record, ok := typeRecord(value, pattern, page1)
+	assert.True(t, ok)
+	valueOf := func(key string) interface{} {
+		return record[key].(map[string]interface{})["value"]
+	}
+	assert.Equal(t, "PS5", valueOf("name"))
+	assert.Equal(t, map[string]interface{}{"amount": "1299.00", "currency": "GBP"}, valueOf("price"))
+	assert.Equal(t, map[string]interface{}{"amount": "1299.50", "currency": "EUR"}, valueOf("euro"), "the last separator should be the decimal separator")
+	assert.Equal(t, map[string]interface{}{"amount": "15", "currency": "CAD"}, valueOf("cad"), "the currency of the item should come before the symbol")
+	assert.Equal(t, "1299.00", valueOf("decimal"))
+	assert.Equal(t, true, valueOf("inStock"))
+	assert.Equal(t, 4.5, valueOf("stars"))
+	assert.Equal(t, int64(1024), valueOf("reviews"))
+	assert.Equal(t, "2021-02-03T10:00:00Z", valueOf("updated"))
+	assert.Equal(t, "2021-02-03T00:00:00Z", valueOf("date"))
+	assert.Equal(t, "https://example.com/items/1", valueOf("link"), "a relative url should be resolved against the page")
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"tag": map[string]interface{}{"value": "new"}},
+		map[string]interface{}{"tag": map[string]interface{}{"value": "boxed"}},
+	}, record["tags"], "the children of an array should be a real array")
+	assert.Equal(t, record["tags"], record["items"], "children should be a real array without the array data type")
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"tag": map[string]interface{}{"value": "new"}},
+	}, record["list"].(map[string]interface{})["children"], "the children of an item with a value should be an array next to it")
+	assert.Equal(t, "newboxed", record["list"].(map[string]interface{})["value"])
+	assert.Equal(t, []string{"new", "boxed"}, valueOf("words"))
+	assert.Equal(t, []interface{}{int64(1024)}, valueOf("counts"))
+
+	content, err := json.Marshal(record)
+	assert.Nil(t, err)
+	assert.Contains(t, string(content), `"reviews":{"value":1024}`)
+
+	pattern = map[string]interface{}{"stars": map[string]interface{}{"pattern": "span.stars", "value": "text", "dataType": "int"}}
+	value, _, _, _ = parsePattern(dom.Find("body"), pattern, "", true, "")
+	_, ok = typeRecord(value, pattern, page1)
+	assert.False(t, ok, "4.5 is not an integer")
+}
+
+func TestCleanNumber(t *testing.T) {
+	for text, number := range map[string]string{
+		"£1,299.00":                 "1299.00",
+		"1.299,00 €":                "1299.00",
+		"12,50":                     "12.50",
+		"1,299":                     "1299",
+		"-3.5%":                     "-3.5",
+		"USD 15":                    "15",
+		"2 for £10":                 "2",
+		"1 299,00 €":                "1299.00",
+		"Price: 12.":                "12",
+		"£1,299.00 (was £1,499.00)": "1299.00",
+		"4.5 out of 5 stars":        "4.5",
+		"Sold out":                  "",
+	} {
+		assert.Equal(t, number, cleanNumber(text), text)
+	}
+}
+
+func TestParseCurrency(t *testing.T) {
+	for text, currency := range map[string]string{
+		"£12.00 inc VAT": "GBP",
+		"NEW $5":         "USD",
+		"£12 ALL IN":     "GBP",
+		"CA$15":          "CAD",
+		"AU$ 20":         "AUD",
+		"1.299,50 EUR":   "EUR",
+		"12 SALE":        "",
+	} {
+		assert.Equal(t, currency, parseCurrency(text, map[string]interface{}{}), text)
+	}
+	assert.Equal(t, "CAD", parseCurrency("15 USD", map[string]interface{}{"currency": "CAD"}), "the currency of the item should come first")
+}
@@ -1,13 +1,130 @@
 package timerjob
 
 import (
-	"log"
+	"sync"
+
+	"github.com/go-co-op/gocron"
 
 	"github.com/sporule/grater/models"
+	"github.com/sporule/grater/modules/config"
+	"github.com/sporule/grater/modules/logger"
+	"github.com/sporule/grater/modules/rulesync"
+	"github.com/sporule/grater/modules/utility"
 )
 
-//GenerateLinks refresh the links for the given rule
-func GenerateLinks(rule models.Rule) {
-	rule.GenerateAndInsertLinks()
-	log.Println("Generated links for rule:", rule.Name)
+//GenerateLinks refresh the links for the given rule, the latest rule is used so changes since the job was scheduled are picked up
+func GenerateLinks(log *logger.Logger, ruleID string) {
+	rule, err := models.GetRule(ruleID)
+	if err != nil {
+		log.Error("Failed to get the rule", "error", err)
+		return
+	}
+	//the rule may be paused or cancelled after the job is scheduled
+	if rule.Status == utility.Enums().Status.Paused || rule.Status == utility.Enums().Status.Cancelled {
+		log.Info("Skipped generating links for the rule", "name", rule.Name, "status", rule.Status)
+		return
+	}
+	if _, err := rule.GenerateAndInsertLinks(); err != nil {
+		log.Error("Failed to generate links", "error", err)
+		return
+	}
+	log.Info("Generated links for rule", "name", rule.Name)
+}
+
+//scheduledRule is the link generation job of a rule with the frequency it is scheduled with
+type scheduledRule struct {
+	job       *gocron.Job
+	frequency int
+	version   int
+}
+
+//Schedule keeps one link generation job for every rule with a frequency
+type Schedule struct {
+	scheduler *gocron.Scheduler
+	log       *logger.Logger
+	jobs      map[string]scheduledRule
+	versions  int
+	mutex     sync.Mutex
+}
+
+//NewSchedule is the constructor of Schedule
+func NewSchedule(scheduler *gocron.Scheduler, log *logger.Logger) *Schedule {
+	return &Schedule{scheduler: scheduler, log: log, jobs: make(map[string]scheduledRule)}
+}
+
+//Refresh schedules the new rules, reschedules the rules whose frequency changed and removes the cancelled rules,
+//so rules added by the api, the cli or the rule files don't need a restart
+func (schedule *Schedule) Refresh() error {
+	schedule.mutex.Lock()
+	defer schedule.mutex.Unlock()
+	rules, err := models.GetRules(nil, 0)
+	if err != nil {
+		schedule.log.Error("Can't get rules", "error", err)
+		return err
+	}
+	scheduled := make(map[string]bool, len(rules))
+	for _, rule := range rules {
+		if rule.Frequency <= 0 || rule.Status == utility.Enums().Status.Cancelled {
+			continue
+		}
+		scheduled[rule.ID] = true
+		if current, ok := schedule.jobs[rule.ID]; ok && current.frequency == rule.Frequency {
+			continue
+		}
+		schedule.remove(rule.ID)
+		schedule.versions++
+		job, err := schedule.scheduler.Every(uint64(rule.Frequency)).Seconds().Do(schedule.generateLinks, rule.ID, schedule.versions)
+		if err != nil {
+			schedule.log.Error("Can't schedule the rule", "rule", rule.ID, "error", err)
+			continue
+		}
+		schedule.jobs[rule.ID] = scheduledRule{job: job, frequency: rule.Frequency, version: schedule.versions}
+		schedule.log.Info("Scheduled link generation", "rule", rule.ID, "frequency", rule.Frequency)
+	}
+	for ruleID := range schedule.jobs {
+		if !scheduled[ruleID] {
+			schedule.remove(ruleID)
+			schedule.log.Info("Removed link generation", "rule", ruleID)
+		}
+	}
+	return nil
+}
+
+//Len returns the number of rules that are scheduled
+func (schedule *Schedule) Len() int {
+	schedule.mutex.Lock()
+	defer schedule.mutex.Unlock()
+	return len(schedule.jobs)
+}
+
+//generateLinks generates the links if the job is still the current job of the rule, the timer of a removed job keeps firing in gocron
+func (schedule *Schedule) generateLinks(ruleID string, version int) {
+	schedule.mutex.Lock()
+	current, ok := schedule.jobs[ruleID]
+	schedule.mutex.Unlock()
+	if !ok || current.version != version {
+		return
+	}
+	GenerateLinks(schedule.log.With("rule", ruleID), ruleID)
+}
+
+func (schedule *Schedule) remove(ruleID string) {
+	if current, ok := schedule.jobs[ruleID]; ok {
+		schedule.scheduler.RemoveByReference(current.job)
+		delete(schedule.jobs, ruleID)
+	}
+}
+
+//SyncRules syncs the rule files and logs the changes, in dry run the changes are only logged
+func SyncRules(log *logger.Logger, config config.Rules) error {
+	changes, err := rulesync.Sync(config.Dir, config.DryRun)
+	for _, change := range changes {
+		log.Info("Rule file change", "action", change.Action, "name", change.Name, "ruleID", change.RuleID, "source", change.Source, "fields", change.Fields, "dryRun", config.DryRun)
+	}
+	if err != nil {
+		log.Error("Failed to sync the rule files", "dir", config.Dir, "error", err)
+		return err
+	}
+	log.Debug("Rule files synced", "dir", config.Dir, "changes", len(changes))
+	return nil
 }
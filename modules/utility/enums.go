@@ -3,6 +3,9 @@ package utility
 //Enums is a enum collection
 var enumsInstance enum
 
+//APIKeyHeader is the header that the scraper nodes and the api client use to send the api key
+const APIKeyHeader = "X-API-Key"
+
 //Enums is the global enums
 func Enums() enum {
 	if enumsInstance.Others.Tester != "tester" {
@@ -21,6 +24,10 @@ type enum struct {
 	Roles role
 	//Status provides a list of queue status
 	Status status
+	//ErrorCodes provides a list of stable error codes returned by the api
+	ErrorCodes errorCode
+	//ResponseTypes provides a list of the responses a rule can parse
+	ResponseTypes responseType
 }
 
 //LoadEnums initiates all global variables
@@ -29,6 +36,17 @@ func (enums *enum) LoadEnums() {
 	enums.loadRoleEnums()
 	enums.loadOtherEnums()
 	enums.loadStatus()
+	enums.loadErrorCodes()
+	enums.loadResponseTypes()
+}
+
+func (enums *enum) loadErrorCodes() {
+	enums.ErrorCodes.Validation = "validation_failed"
+	enums.ErrorCodes.NotFound = "not_found"
+	enums.ErrorCodes.Conflict = "conflict"
+	enums.ErrorCodes.Unauthorized = "unauthorized"
+	enums.ErrorCodes.Forbidden = "forbidden"
+	enums.ErrorCodes.Internal = "internal_error"
 }
 
 func (enums *enum) loadStatus() {
@@ -36,12 +54,21 @@ func (enums *enum) loadStatus() {
 	enums.Status.Completed = "Completed"
 	enums.Status.Running = "Running"
 	enums.Status.Cancelled = "Cancelled"
+	enums.Status.Paused = "Paused"
+	enums.Status.Failed = "Failed"
 }
 
 //LoadOtherEnums assign values to enums
 func (enums *enum) loadOtherEnums() {
 	enums.Others.Tester = "test"
+	enums.Others.UserID = "userID"
+	enums.Others.Role = "role"
+
+}
 
+func (enums *enum) loadResponseTypes() {
+	enums.ResponseTypes.HTML = "html"
+	enums.ResponseTypes.JSON = "json"
 }
 
 //LoadErrorMessageEnums assign values to enums.ErrorMessages
@@ -54,6 +81,7 @@ func (enums *enum) loadErrorMessageEnums() {
 	enums.ErrorMessages.LackOfInfo = "Fail to add an item, please ensure you have provided necessary info"
 	enums.ErrorMessages.RecordExist = "Fail to add an item, the data is already exist"
 	enums.ErrorMessages.RecordNotFound = "Fail to find the record"
+	enums.ErrorMessages.PermissionDenied = "You don't have permission to access this resource."
 }
 
 //LoadRoleEnums loads a list of predefined roles
@@ -61,6 +89,7 @@ func (enums *enum) loadRoleEnums() {
 	enums.Roles.Admin = "Admin"
 	enums.Roles.Member = "Member"
 	enums.Roles.Test = "Test"
+	enums.Roles.Scraper = "Scraper"
 }
 
 //HTTPStatusStruct is the struct for http status
@@ -70,20 +99,32 @@ type hTTPStatusStruct struct {
 
 //ErrorMessage is the collection of error messages
 type errorMessage struct {
-	AuthFailed, PageNotFound, SystemError, LackOfRegInfo, UserExist, LackOfInfo, RecordExist, RecordNotFound string
+	AuthFailed, PageNotFound, SystemError, LackOfRegInfo, UserExist, LackOfInfo, RecordExist, RecordNotFound, PermissionDenied string
+}
+
+//errorCode is the collection of error codes
+type errorCode struct {
+	Validation, NotFound, Conflict, Unauthorized, Forbidden, Internal string
+}
+
+//responseType is the collection of response types
+type responseType struct {
+	HTML, JSON string
 }
 
 //Role is the collection of roles
 type role struct {
-	Admin, Member, Test string
+	Admin, Member, Test, Scraper string
 }
 
 //status is the collection of roles
 type status struct {
-	Active, Running, Completed, Cancelled string
+	Active, Running, Completed, Cancelled, Paused, Failed string
 }
 
 //Other is the struct of uncategorise enums
 type other struct {
 	Tester string
+	//UserID and Role are the keys where the authenticated identity stored in the context
+	UserID, Role string
 }
@@ -0,0 +1,88 @@
+package utility
+
+import (
+	"errors"
+	"net/http"
+)
+
+//AppError is the typed domain error, the code decides the http status
+type AppError struct {
+	Code    string      `json:"code"`
+	Message string      `json:"message"`
+	Details interface{} `json:"details,omitempty"`
+}
+
+//Error implements the error interface
+func (err *AppError) Error() string {
+	return err.Message
+}
+
+//Status returns the http status of the error code
+func (err *AppError) Status() int {
+	codes := Enums().ErrorCodes
+	switch err.Code {
+	case codes.Validation:
+		return http.StatusBadRequest
+	case codes.Unauthorized:
+		return http.StatusUnauthorized
+	case codes.Forbidden:
+		return http.StatusForbidden
+	case codes.NotFound:
+		return http.StatusNotFound
+	case codes.Conflict:
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+//ValidationError returns an error for invalid input, details can carry the field level errors
+func ValidationError(message string, details interface{}) *AppError {
+	return &AppError{Code: Enums().ErrorCodes.Validation, Message: message, Details: details}
+}
+
+//NotFoundError returns an error for missing records
+func NotFoundError(message string) *AppError {
+	return &AppError{Code: Enums().ErrorCodes.NotFound, Message: message}
+}
+
+//ConflictError returns an error for records that already exist
+func ConflictError(message string) *AppError {
+	return &AppError{Code: Enums().ErrorCodes.Conflict, Message: message}
+}
+
+//UnauthorizedError returns an error for failed authentication
+func UnauthorizedError() *AppError {
+	return &AppError{Code: Enums().ErrorCodes.Unauthorized, Message: Enums().ErrorMessages.AuthFailed}
+}
+
+//ForbiddenError returns an error for authenticated users without permission
+func ForbiddenError() *AppError {
+	return &AppError{Code: Enums().ErrorCodes.Forbidden, Message: Enums().ErrorMessages.PermissionDenied}
+}
+
+//InternalError returns an error for unexpected failures, the original error is not exposed
+func InternalError() *AppError {
+	return &AppError{Code: Enums().ErrorCodes.Internal, Message: Enums().ErrorMessages.SystemError}
+}
+
+//ToAppError converts any error to AppError, untyped errors are treated as internal errors
+func ToAppError(err error) *AppError {
+	var appErr *AppError
+	if errors.As(err, &appErr) {
+		return appErr
+	}
+	return InternalError()
+}
+
+//IsErrorCode checks if the error is an AppError with the given code
+func IsErrorCode(err error, code string) bool {
+	var appErr *AppError
+	return errors.As(err, &appErr) && appErr.Code == code
+}
+
+//ErrorResult converts the error to the result with the matching status and the error envelope
+func ErrorResult(err error) Result {
+	appErr := ToAppError(err)
+	return Result{Code: appErr.Status(), Obj: &Error{Error: appErr}}
+}
@@ -0,0 +1,34 @@
+package utility
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorResult(t *testing.T) {
+	code, obj := ErrorResult(NotFoundError("missing")).Expand()
+	assert.Equal(t, http.StatusNotFound, code, "not found error should return 404")
+	body, _ := json.Marshal(obj)
+	assert.Equal(t, `{"error":{"code":"not_found","message":"missing"}}`, string(body), "it should use the error envelope")
+
+	code, obj = ErrorResult(ValidationError("invalid", map[string]string{"name": "required"})).Expand()
+	assert.Equal(t, http.StatusBadRequest, code, "validation error should return 400")
+	body, _ = json.Marshal(obj)
+	assert.Equal(t, `{"error":{"code":"validation_failed","message":"invalid","details":{"name":"required"}}}`, string(body), "details should be included")
+
+	code, obj = ErrorResult(errors.New("connection refused")).Expand()
+	assert.Equal(t, http.StatusInternalServerError, code, "untyped error should return 500")
+	assert.Equal(t, Enums().ErrorMessages.SystemError, obj.(*Error).Error.Message, "untyped error should not be exposed")
+}
+
+func TestIsErrorCode(t *testing.T) {
+	err := fmt.Errorf("wrapped: %w", ConflictError("exists"))
+	assert.Equal(t, true, IsErrorCode(err, Enums().ErrorCodes.Conflict), "wrapped errors should be matched")
+	assert.Equal(t, false, IsErrorCode(err, Enums().ErrorCodes.NotFound), "other codes should not be matched")
+	assert.Equal(t, false, IsErrorCode(errors.New("plain"), Enums().ErrorCodes.Conflict), "plain errors should not be matched")
+}
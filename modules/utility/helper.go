@@ -1,10 +1,13 @@
 package utility
 
 import (
+	"bytes"
 	"encoding/json"
-	"io/ioutil"
+	"fmt"
 	"os"
 	"reflect"
+
+	"gopkg.in/yaml.v2"
 )
 
 //IsNil checks if all items are empty, it will return true if it is nil
@@ -55,26 +58,13 @@ type Result struct {
 }
 
 //Expand expands the result object to fit c.json
-func (result *Result) Expand() (int, interface{}) {
+func (result Result) Expand() (int, interface{}) {
 	return result.Code, result.Obj
 }
 
-//Error is the struct for c.json
+//Error is the error envelope for c.json
 type Error struct {
-	Error string `json:"error,omitempty"`
-}
-
-//Config returns the global config
-var Config map[string]string
-
-//LoadConfiguration loads json configuration
-func LoadConfiguration(filepath string) (err error) {
-	content, err := ioutil.ReadFile(filepath)
-	if err == nil {
-		err = json.Unmarshal(content, &Config)
-		return err
-	}
-	return err
+	Error *AppError `json:"error"`
 }
 
 //GetEnv returns environment variable by the given key. It will return fallback value if the environment variable not exist
@@ -84,3 +74,36 @@ func GetEnv(key, fallback string) string {
 	}
 	return fallback
 }
+
+//YAMLToJSON converts the yaml to json so the yaml files are decoded with the json names of the structs
+func YAMLToJSON(content []byte) ([]byte, error) {
+	var raw interface{}
+	if err := yaml.Unmarshal(content, &raw); err != nil {
+		return nil, err
+	}
+	return json.Marshal(stringKeys(raw))
+}
+
+//stringKeys converts the yaml maps to json compatible maps
+func stringKeys(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[interface{}]interface{}:
+		result := make(map[string]interface{}, len(value))
+		for key, item := range value {
+			result[fmt.Sprint(key)] = stringKeys(item)
+		}
+		return result
+	case []interface{}:
+		for i, item := range value {
+			value[i] = stringKeys(item)
+		}
+	}
+	return value
+}
+
+//DecodeJSONStrict decodes the json into the value, unknown keys are rejected so typos don't go unnoticed
+func DecodeJSONStrict(content []byte, value interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(value)
+}
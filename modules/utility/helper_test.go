@@ -24,3 +24,16 @@ func TestGetError(t *testing.T) {
 	assert.Equal(t, "testing error", GetError(errors.New("testing error")), "It should return the error with error message")
 	assert.Equal(t, "", GetError(nil), "It should return the empty error message")
 }
+
+func TestYAMLToJSON(t *testing.T) {
+	content, err := YAMLToJSON([]byte("pages:\n  1: list.html\nitems:\n  - {name: a}\n"))
+	assert.Nil(t, err)
+	assert.JSONEq(t, `{"pages":{"1":"list.html"},"items":[{"name":"a"}]}`, string(content), "the nested maps should have string keys")
+
+	var value struct {
+		Name string `json:"name"`
+	}
+	assert.Nil(t, DecodeJSONStrict([]byte(`{"name":"a"}`), &value))
+	assert.Equal(t, "a", value.Name)
+	assert.NotNil(t, DecodeJSONStrict([]byte(`{"nmae":"a"}`), &value), "unknown keys should be rejected")
+}
@@ -0,0 +1,233 @@
+package warc
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/sporule/grater/modules/config"
+)
+
+//store types of the archive config
+const (
+	Disk = "disk"
+	S3   = "s3"
+)
+
+//File is a WARC file in the store, the name is relative to the store and uses / as the separator
+type File struct {
+	Name       string
+	LastUpdate time.Time
+}
+
+//Store keeps the WARC files
+type Store interface {
+	Put(name string, content []byte) error
+	Get(name string) ([]byte, error)
+	//List returns the files whose names start with the prefix sorted by name
+	List(prefix string) ([]File, error)
+	Delete(name string) error
+}
+
+//NewStore returns the store of the archive config, it is nil if the archive is off
+func NewStore(archive config.Archive) (Store, error) {
+	switch archive.Store {
+	case "":
+		return nil, nil
+	case Disk:
+		store, err := NewDiskStore(archive.Dir)
+		if err != nil {
+			return nil, err
+		}
+		return store, nil
+	case S3:
+		store, err := NewS3Store(archive)
+		if err != nil {
+			return nil, err
+		}
+		return store, nil
+	}
+	return nil, errors.New("unknown archive store: " + archive.Store)
+}
+
+//Prune deletes the files that are older than the retention in days, nothing is deleted if the retention is 0
+func Prune(store Store, retention int) (deleted int, err error) {
+	if retention <= 0 {
+		return 0, nil
+	}
+	files, err := store.List("")
+	if err != nil {
+		return 0, err
+	}
+	before := time.Now().AddDate(0, 0, -retention)
+	for _, file := range files {
+		if !file.LastUpdate.Before(before) {
+			continue
+		}
+		if err := store.Delete(file.Name); err != nil {
+			return deleted, err
+		}
+		deleted++
+	}
+	return deleted, nil
+}
+
+//DiskStore keeps the files in the directory, the directories in the names are created
+type DiskStore struct {
+	dir string
+}
+
+//NewDiskStore creates the directory if it doesn't exist
+func NewDiskStore(dir string) (*DiskStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return &DiskStore{dir: dir}, nil
+}
+
+func (store *DiskStore) path(name string) (string, error) {
+	path := filepath.Join(store.dir, filepath.FromSlash(name))
+	if !strings.HasPrefix(path, filepath.Clean(store.dir)+string(filepath.Separator)) {
+		return "", errors.New("invalid archive file name: " + name)
+	}
+	return path, nil
+}
+
+//Put writes the file to a temporary file and renames it so a reader never sees half a file
+func (store *DiskStore) Put(name string, content []byte) error {
+	path, err := store.path(name)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	file, err := ioutil.TempFile(filepath.Dir(path), ".warc-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+	if _, err := file.Write(content); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), path)
+}
+
+//Get reads the file
+func (store *DiskStore) Get(name string) ([]byte, error) {
+	path, err := store.path(name)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadFile(path)
+}
+
+//List walks the directory, the temporary files are skipped
+func (store *DiskStore) List(prefix string) ([]File, error) {
+	var files []File
+	err := filepath.Walk(store.dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || strings.HasPrefix(info.Name(), ".warc-") {
+			return nil
+		}
+		name, err := filepath.Rel(store.dir, path)
+		if err != nil {
+			return err
+		}
+		name = filepath.ToSlash(name)
+		if strings.HasPrefix(name, prefix) {
+			files = append(files, File{Name: name, LastUpdate: info.ModTime()})
+		}
+		return nil
+	})
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Name < files[j].Name
+	})
+	return files, err
+}
+
+//Delete removes the file
+func (store *DiskStore) Delete(name string) error {
+	path, err := store.path(name)
+	if err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+//S3Store keeps the files in a bucket of AWS S3 or an S3 compatible store such as MinIO
+type S3Store struct {
+	client *s3.S3
+	bucket string
+}
+
+//NewS3Store connects to the bucket, the path style is used with a custom endpoint because MinIO doesn't have bucket subdomains
+func NewS3Store(archive config.Archive) (*S3Store, error) {
+	awsConfig := aws.NewConfig().WithRegion(archive.Region)
+	if archive.Endpoint != "" {
+		awsConfig = awsConfig.WithEndpoint(archive.Endpoint).WithS3ForcePathStyle(true)
+	}
+	if archive.AccessKey != "" {
+		awsConfig = awsConfig.WithCredentials(credentials.NewStaticCredentials(archive.AccessKey, archive.SecretKey, ""))
+	}
+	sess, err := session.NewSession(awsConfig)
+	if err != nil {
+		return nil, err
+	}
+	return &S3Store{client: s3.New(sess), bucket: archive.Bucket}, nil
+}
+
+//Put uploads the file
+func (store *S3Store) Put(name string, content []byte) error {
+	_, err := store.client.PutObject(&s3.PutObjectInput{
+		Bucket:      aws.String(store.bucket),
+		Key:         aws.String(name),
+		Body:        bytes.NewReader(content),
+		ContentType: aws.String("application/warc"),
+	})
+	return err
+}
+
+//Get downloads the file
+func (store *S3Store) Get(name string) ([]byte, error) {
+	output, err := store.client.GetObject(&s3.GetObjectInput{Bucket: aws.String(store.bucket), Key: aws.String(name)})
+	if err != nil {
+		return nil, err
+	}
+	defer output.Body.Close()
+	return ioutil.ReadAll(output.Body)
+}
+
+//List returns the objects of the prefix, S3 lists them in the order of the keys
+func (store *S3Store) List(prefix string) ([]File, error) {
+	var files []File
+	err := store.client.ListObjectsV2Pages(&s3.ListObjectsV2Input{Bucket: aws.String(store.bucket), Prefix: aws.String(prefix)},
+		func(page *s3.ListObjectsV2Output, lastPage bool) bool {
+			for _, object := range page.Contents {
+				files = append(files, File{Name: aws.StringValue(object.Key), LastUpdate: aws.TimeValue(object.LastModified)})
+			}
+			return true
+		})
+	return files, err
+}
+
+//Delete removes the object
+func (store *S3Store) Delete(name string) error {
+	_, err := store.client.DeleteObject(&s3.DeleteObjectInput{Bucket: aws.String(store.bucket), Key: aws.String(name)})
+	return err
+}
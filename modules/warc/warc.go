@@ -0,0 +1,238 @@
+package warc
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha1"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+//Version is the WARC version of the records
+const Version = "WARC/1.1"
+
+//record types
+const (
+	Info     = "warcinfo"
+	Request  = "request"
+	Response = "response"
+)
+
+//Record is a WARC record, the block is the http request or response for the request and response records
+type Record struct {
+	Type         string
+	ID           string
+	Date         time.Time
+	TargetURI    string
+	ConcurrentTo string
+	ContentType  string
+	Block        []byte
+}
+
+//NewRecordID returns a new record id in the <urn:uuid:...> format
+func NewRecordID() string {
+	id, _ := uuid.NewRandom()
+	return "<urn:uuid:" + id.String() + ">"
+}
+
+//InfoRecord returns the warcinfo record at the start of every file
+func InfoRecord() *Record {
+	return &Record{
+		Type:        Info,
+		ID:          NewRecordID(),
+		Date:        time.Now(),
+		ContentType: "application/warc-fields",
+		Block:       []byte("software: grater\r\nformat: WARC File Format 1.1\r\n"),
+	}
+}
+
+//sensitiveHeaders are not archived, like the cookies of the page layout errors
+var sensitiveHeaders = []string{"Cookie", "Set-Cookie", "Authorization", "Proxy-Authorization"}
+
+//RequestRecord returns the request record of the request, the sensitive headers are removed
+func RequestRecord(method string, target string, header http.Header) (*Record, error) {
+	link, err := http.NewRequest(method, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	var block bytes.Buffer
+	fmt.Fprintf(&block, "%s %s HTTP/1.1\r\nHost: %s\r\n", method, link.URL.RequestURI(), link.URL.Host)
+	if err := cleanHeader(header).Write(&block); err != nil {
+		return nil, err
+	}
+	block.WriteString("\r\n")
+	return &Record{
+		Type:        Request,
+		ID:          NewRecordID(),
+		Date:        time.Now(),
+		TargetURI:   target,
+		ContentType: "application/http;msgtype=request",
+		Block:       block.Bytes(),
+	}, nil
+}
+
+//ResponseRecord returns the response record of the decoded body, the length of the body replaces the transfer headers
+func ResponseRecord(target string, statusCode int, header http.Header, body []byte) (*Record, error) {
+	header = cleanHeader(header)
+	for _, name := range []string{"Content-Length", "Transfer-Encoding", "Content-Encoding"} {
+		header.Del(name)
+	}
+	header.Set("Content-Length", strconv.Itoa(len(body)))
+	var block bytes.Buffer
+	fmt.Fprintf(&block, "HTTP/1.1 %d %s\r\n", statusCode, http.StatusText(statusCode))
+	if err := header.Write(&block); err != nil {
+		return nil, err
+	}
+	block.WriteString("\r\n")
+	block.Write(body)
+	return &Record{
+		Type:        Response,
+		ID:          NewRecordID(),
+		Date:        time.Now(),
+		TargetURI:   target,
+		ContentType: "application/http;msgtype=response",
+		Block:       block.Bytes(),
+	}, nil
+}
+
+func cleanHeader(header http.Header) http.Header {
+	cleaned := header.Clone()
+	if cleaned == nil {
+		cleaned = http.Header{}
+	}
+	for _, name := range sensitiveHeaders {
+		cleaned.Del(name)
+	}
+	return cleaned
+}
+
+//HTTPResponse parses the block of the response record
+func (record *Record) HTTPResponse() (*http.Response, error) {
+	if record.Type != Response {
+		return nil, errors.New("not a response record: " + record.Type)
+	}
+	return http.ReadResponse(bufio.NewReader(bytes.NewReader(record.Block)), nil)
+}
+
+//Write writes the record as a gzip member, the files of gzip members can be read from any record
+func Write(w io.Writer, record *Record) error {
+	writer := gzip.NewWriter(w)
+	var header bytes.Buffer
+	header.WriteString(Version + "\r\n")
+	fields := [][2]string{
+		{"WARC-Type", record.Type},
+		{"WARC-Record-ID", record.ID},
+		{"WARC-Date", record.Date.UTC().Format(time.RFC3339)},
+		{"WARC-Target-URI", record.TargetURI},
+		{"WARC-Concurrent-To", record.ConcurrentTo},
+		{"WARC-Block-Digest", blockDigest(record.Block)},
+		{"Content-Type", record.ContentType},
+		{"Content-Length", strconv.Itoa(len(record.Block))},
+	}
+	for _, field := range fields {
+		if field[1] != "" {
+			header.WriteString(field[0] + ": " + field[1] + "\r\n")
+		}
+	}
+	header.WriteString("\r\n")
+	for _, content := range [][]byte{header.Bytes(), record.Block, []byte("\r\n\r\n")} {
+		if _, err := writer.Write(content); err != nil {
+			return err
+		}
+	}
+	return writer.Close()
+}
+
+//blockDigest is the base32 sha1 of the block like the other WARC tools
+func blockDigest(block []byte) string {
+	hash := sha1.Sum(block)
+	return "sha1:" + base32.StdEncoding.EncodeToString(hash[:])
+}
+
+//Reader reads the records of a WARC file, the file can be gzip compressed or not
+type Reader struct {
+	reader *bufio.Reader
+}
+
+//NewReader detects the gzip header and reads every gzip member
+func NewReader(r io.Reader) (*Reader, error) {
+	buffered := bufio.NewReader(r)
+	magic, err := buffered.Peek(2)
+	if err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(buffered)
+		if err != nil {
+			return nil, err
+		}
+		return &Reader{reader: bufio.NewReader(gz)}, nil
+	}
+	return &Reader{reader: buffered}, nil
+}
+
+//Next returns the next record, the error is io.EOF after the last record
+func (reader *Reader) Next() (*Record, error) {
+	var version string
+	for version == "" {
+		line, err := reader.reader.ReadString('\n')
+		if err != nil {
+			if err == io.EOF && strings.TrimSpace(line) == "" {
+				return nil, io.EOF
+			}
+			return nil, err
+		}
+		version = strings.TrimSpace(line)
+	}
+	if !strings.HasPrefix(version, "WARC/") {
+		return nil, errors.New("invalid WARC record: " + version)
+	}
+	header, err := textproto.NewReader(reader.reader).ReadMIMEHeader()
+	if err != nil {
+		return nil, err
+	}
+	length, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil {
+		return nil, errors.New("invalid WARC Content-Length: " + header.Get("Content-Length"))
+	}
+	block := make([]byte, length)
+	if _, err := io.ReadFull(reader.reader, block); err != nil {
+		return nil, err
+	}
+	date, _ := time.Parse(time.RFC3339, header.Get("WARC-Date"))
+	return &Record{
+		Type:         header.Get("WARC-Type"),
+		ID:           header.Get("WARC-Record-ID"),
+		Date:         date,
+		TargetURI:    header.Get("WARC-Target-URI"),
+		ConcurrentTo: header.Get("WARC-Concurrent-To"),
+		ContentType:  header.Get("Content-Type"),
+		Block:        block,
+	}, nil
+}
+
+//ReadAll returns every record of the file
+func ReadAll(content []byte) ([]*Record, error) {
+	reader, err := NewReader(bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+	var records []*Record
+	for {
+		record, err := reader.Next()
+		if err == io.EOF {
+			return records, nil
+		}
+		if err != nil {
+			return records, err
+		}
+		records = append(records, record)
+	}
+}
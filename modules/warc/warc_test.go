@@ -0,0 +1,92 @@
+package warc
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sporule/grater/modules/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteAndRead(t *testing.T) {
+	request, err := RequestRecord(http.MethodGet, "https://example.com/list?page=2", http.Header{"Authorization": {"secret"}, "Accept": {"text/html"}})
+	assert.Nil(t, err)
+	response, err := ResponseRecord("https://example.com/list?page=2", http.StatusOK, http.Header{
+		"Content-Type":      {"text/html"},
+		"Content-Encoding":  {"gzip"},
+		"Transfer-Encoding": {"chunked"},
+	}, []byte("<html>PS5</html>"))
+	assert.Nil(t, err)
+	request.ConcurrentTo = response.ID
+	var file bytes.Buffer
+	for _, record := range []*Record{InfoRecord(), request, response} {
+		assert.Nil(t, Write(&file, record))
+	}
+
+	records, err := ReadAll(file.Bytes())
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(records))
+	assert.Equal(t, Info, records[0].Type)
+	assert.Equal(t, request.ID, records[1].ID)
+	assert.Equal(t, response.ID, records[1].ConcurrentTo)
+	assert.Contains(t, string(records[1].Block), "GET /list?page=2 HTTP/1.1\r\nHost: example.com\r\n")
+	assert.NotContains(t, string(records[1].Block), "secret", "the credentials should not be archived")
+	assert.Equal(t, "https://example.com/list?page=2", records[2].TargetURI)
+	assert.Regexp(t, `^<urn:uuid:[0-9a-f-]{36}>$`, records[2].ID)
+
+	res, err := records[2].HTTPResponse()
+	assert.Nil(t, err)
+	body, err := ioutil.ReadAll(res.Body)
+	assert.Nil(t, err)
+	assert.Equal(t, "<html>PS5</html>", string(body))
+	assert.Equal(t, "", res.Header.Get("Content-Encoding"), "the body is archived decoded")
+	_, err = records[1].HTTPResponse()
+	assert.NotNil(t, err)
+
+	uncompressed := "WARC/1.1\r\nWARC-Type: resource\r\nWARC-Record-ID: <urn:uuid:1>\r\nContent-Length: 3\r\n\r\nabc\r\n\r\n"
+	records, err = ReadAll([]byte(uncompressed))
+	assert.Nil(t, err)
+	assert.Equal(t, "abc", string(records[0].Block), "uncompressed files should be read too")
+	_, err = ReadAll([]byte("HTTP/1.1 200 OK\r\n"))
+	assert.NotNil(t, err)
+}
+
+func TestDiskStore(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewStore(config.Archive{Store: Disk, Dir: dir})
+	assert.Nil(t, err)
+	assert.Nil(t, store.Put("rule1/b.warc.gz", []byte("b")))
+	assert.Nil(t, store.Put("rule1/a.warc.gz", []byte("a")))
+	assert.Nil(t, store.Put("rule2/c.warc.gz", []byte("c")))
+	assert.NotNil(t, store.Put("../outside.warc.gz", []byte("x")), "files should stay in the directory")
+
+	files, err := store.List("rule1/")
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(files))
+	assert.Equal(t, "rule1/a.warc.gz", files[0].Name)
+	content, err := store.Get("rule1/b.warc.gz")
+	assert.Nil(t, err)
+	assert.Equal(t, "b", string(content))
+
+	old := time.Now().AddDate(0, 0, -31)
+	assert.Nil(t, os.Chtimes(filepath.Join(dir, "rule1", "a.warc.gz"), old, old))
+	deleted, err := Prune(store, 0)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, deleted, "a retention of 0 keeps the files forever")
+	deleted, err = Prune(store, 30)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, deleted)
+	files, _ = store.List("")
+	assert.Equal(t, 2, len(files))
+
+	_, err = NewStore(config.Archive{Store: "ftp"})
+	assert.NotNil(t, err)
+	store, err = NewStore(config.Archive{})
+	assert.Nil(t, err)
+	assert.Nil(t, store, "the archive should be off by default")
+}